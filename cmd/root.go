@@ -18,6 +18,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/alsritter/middlebaby/pkg/caseprovider"
 	"github.com/alsritter/middlebaby/pkg/startup"
@@ -65,7 +66,10 @@ var (
 )
 
 func init() {
+	loadConfigFile(config)
 	rootCmd.AddCommand(CommandServe(Setup, config))
+	rootCmd.AddCommand(CommandRun(Run, config, &config.TaskService.UpdateSnapshots))
+	rootCmd.AddCommand(CommandValidate(Validate, config))
 	rootCmd.AddCommand(initCmd)
 }
 
@@ -91,6 +95,100 @@ func Setup(c context.Context) {
 	log.Info(nil, "Goodbye")
 }
 
+// Run execute all cases and return the process exit code.
+func Run(c context.Context) int {
+	log, err := logger.New(config.Log, "main")
+	if err != nil {
+		panic(err)
+	}
+
+	ctx := mbcontext.NewContext(c)
+	util.RegisterExitHandlers(log, ctx.GetCancelFunc())
+	defer ctx.CancelFunc()
+
+	if err := config.Validate(); err != nil {
+		log.Error(nil, "failed to validate config: %s", err)
+		return 2
+	}
+
+	summary, err := startup.RunCases(ctx, config, log, &caseprovider.BasicLoader{})
+	if err != nil {
+		log.Error(nil, "run cases fail: %s", err)
+		return 2
+	}
+
+	for _, r := range summary.Results {
+		if r.Skipped() {
+			log.Warn(map[string]interface{}{
+				"InterfaceName": r.ItfName,
+				"CaseName":      r.CaseName,
+			}, "case skipped: %s", r.SkipReason)
+		} else if r.FailedQuarantined() {
+			log.Warn(map[string]interface{}{
+				"InterfaceName": r.ItfName,
+				"CaseName":      r.CaseName,
+			}, "quarantined case failed: %s", r.FailedReason)
+		} else if !r.Passed() {
+			reason := r.FailedReason
+			// the diff of the failed assertions is coloured on the console.
+			if config.Log.Pretty && len(r.AssertErrors) > 0 && r.FailedReason == r.AssertErrors.Error() {
+				reason = r.AssertErrors.Render(true)
+			}
+			log.Error(map[string]interface{}{
+				"InterfaceName": r.ItfName,
+				"CaseName":      r.CaseName,
+			}, "case failed: %s", reason)
+		}
+	}
+
+	if !summary.Success() {
+		return 1
+	}
+	return 0
+}
+
+// Validate lint the case files and the mock files and return the process exit code.
+func Validate(c context.Context) int {
+	log, err := logger.New(config.Log, "main")
+	if err != nil {
+		panic(err)
+	}
+
+	// the target application is not needed to lint the files.
+	if err := config.CaseProvider.Validate(); err != nil {
+		log.Error(nil, "failed to validate config: %s", err)
+		return 2
+	}
+
+	diags, err := startup.Validate(config, log)
+	if err != nil {
+		log.Error(nil, "validate fail: %s", err)
+		return 2
+	}
+
+	for _, d := range diags {
+		fmt.Println(d.String())
+	}
+	if len(diags) > 0 {
+		fmt.Printf("%d problem(s) found\n", len(diags))
+		return 1
+	}
+	fmt.Println("all case files and mock files are valid")
+	return 0
+}
+
+// loadConfigFile load the file specified by --config.file into config.
+func loadConfigFile(config interface{}) {
+	configFile := util.ParseConfigFileParameter(os.Args[1:])
+	if configFile != "" {
+		fmt.Printf("start to load config file: %s \r\n", configFile)
+		if err := util.LoadConfig(configFile, config); err != nil {
+			fmt.Printf("error loading config from %s: %v\n", configFile, err)
+			os.Exit(1)
+		}
+	}
+}
+
 func Execute() {
 	cobra.CheckErr(rootCmd.Execute())
 }
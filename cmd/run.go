@@ -0,0 +1,42 @@
+/*
+Copyright © 2021 alsritter@outlook.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/alsritter/middlebaby/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// CommandRun execute every case without the web service, the process exits with
+// a non-zero code when any case fails. updateSnapshots is set by the --update-snapshots flag.
+func CommandRun(fn func(context.Context) int, config util.RegistrableConfig, updateSnapshots *bool) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "run",
+		Short: "run all cases and exit",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(fn(cmd.Context()))
+		},
+	}
+
+	flagSet := command.PersistentFlags()
+	util.IgnoredFlag(flagSet, "config.file", "config file to load")
+	config.RegisterFlagsWithPrefix("", flagSet)
+	flagSet.BoolVar(updateSnapshots, "update-snapshots", *updateSnapshots, "rewrite the response snapshots which do not match")
+	return command
+}
@@ -17,8 +17,6 @@ package cmd
 
 import (
 	"context"
-	"fmt"
-	"os"
 
 	"github.com/alsritter/middlebaby/pkg/util"
 	"github.com/spf13/cobra"
@@ -33,15 +31,6 @@ func CommandServe(fn func(context.Context), config util.RegistrableConfig) *cobr
 		},
 	}
 
-	configFile := util.ParseConfigFileParameter(os.Args[1:])
-	if configFile != "" {
-		fmt.Printf("start to load config file: %s \r\n", configFile)
-		if err := util.LoadConfig(configFile, config); err != nil {
-			fmt.Printf("error loading config from %s: %v\n", configFile, err)
-			os.Exit(1)
-		}
-	}
-
 	flagSet := command.PersistentFlags()
 	util.IgnoredFlag(flagSet, "config.file", "config file to load")
 	config.RegisterFlagsWithPrefix("", flagSet)
@@ -3,6 +3,8 @@ module github.com/alsritter/middlebaby
 go 1.16
 
 require (
+	github.com/PaesslerAG/jsonpath v0.1.1
+	github.com/dop251/goja v0.0.0-20221118162653-d4bf6fde1b86
 	github.com/flynn/json5 v0.0.0-20160717195620-7620272ed633
 	github.com/fsnotify/fsnotify v1.5.4 // indirect
 	github.com/gin-gonic/gin v1.8.1
@@ -15,7 +17,7 @@ require (
 	github.com/google/uuid v1.1.2
 	github.com/gorilla/handlers v1.5.1
 	github.com/gorilla/mux v1.8.0
-	github.com/gorilla/websocket v1.5.0 // indirect
+	github.com/gorilla/websocket v1.5.0
 	github.com/hashicorp/go-multierror v1.1.1
 	github.com/jhump/protoreflect v1.12.0
 	github.com/json-iterator/go v1.1.12
@@ -28,9 +30,12 @@ require (
 	github.com/radovskyb/watcher v1.0.7
 	github.com/robertkrimen/otto v0.0.0-20211024170158-b87d35c0b86f // indirect
 	github.com/rs/zerolog v1.27.0
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/spf13/cobra v1.5.0
 	github.com/spf13/pflag v1.0.5
+	github.com/tidwall/gjson v1.14.4
 	github.com/viki-org/dnscache v0.0.0-20130720023526-c70c1f23c5d8
+	go.etcd.io/bbolt v1.3.6
 	golang.org/x/crypto v0.0.0-20220829220503-c86fa9a7ed90 // indirect
 	golang.org/x/net v0.0.0-20220907135653-1e95f45603a7
 	golang.org/x/sys v0.0.0-20220908164124-27713097b956 // indirect
@@ -38,6 +43,7 @@ require (
 	google.golang.org/grpc v1.49.0
 	google.golang.org/protobuf v1.28.1
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/mysql v1.2.3
 	gorm.io/gorm v1.22.5
 	rogchap.com/v8go v0.7.0
@@ -19,16 +19,23 @@ package apimanager
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/textproto"
 	"net/url"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/alsritter/middlebaby/pkg/caseprovider"
 	"github.com/alsritter/middlebaby/pkg/util/assert"
+	"github.com/alsritter/middlebaby/pkg/util/common"
+	"github.com/alsritter/middlebaby/pkg/util/render"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
 
 	"github.com/alsritter/middlebaby/pkg/types/interact"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
@@ -55,20 +62,67 @@ func (c *Config) Validate() error {
 // RegisterFlagsWithPrefix is used to register flags
 func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {}
 
+// CorrelationHeader the header carrying the case environment id, the target service should forward it to
+// its dependencies so that mocked requests are matched against the environment of the case that caused them.
+const CorrelationHeader = "X-Middlebaby-Correlation-Id"
+
 type Provider interface {
-	// LoadCaseEnv Initialize the environment before executing the use case
-	LoadCaseEnv(itfName, caseName string)
+	// LoadCaseEnv Initialize the environment before executing the use case, returns the environment id.
+	// The mocks are rendered with the template data (e.g. the run variables).
+	LoadCaseEnv(itfName, caseName string, data map[string]interface{}) string
 	// MockResponse Mock Request.
 	MockResponse(ctx context.Context, request *interact.Request) (*interact.Response, error)
 	// ClearCaseEnv clear environment
-	ClearCaseEnv()
+	ClearCaseEnv(id string)
+	// GetCalls returns the requests received while the environment is loaded, in receiving order.
+	GetCalls(id string) []interact.Call
+	// GetScenarios returns the scenario states of every environment, key: environment id.
+	GetScenarios() map[string]map[string]string
+	// ResetScenarios reset the scenario of the environment to its initial state,
+	// an empty id resets all environments and an empty scenario resets all scenarios.
+	ResetScenarios(id, scenario string)
+}
+
+// caseEnv the mocks available while a case is running.
+type caseEnv struct {
+	// the template data of the case, it is also available to the response templates.
+	data map[string]interface{}
+	// the mocks in matching order.
+	apis []*interact.ImposterMockCase
+
+	// the current state of each scenario, a scenario not in the map is in the started state.
+	scenarios map[string]string
+	// the journal of received requests.
+	calls []interact.Call
+	lock  sync.Mutex
+}
+
+// inState whether the scenario of the mock is in its required state.
+func (e *caseEnv) inState(api *interact.ImposterMockCase) bool {
+	if api.Scenario == "" || api.RequiredState == "" {
+		return true
+	}
+	return e.state(api.Scenario) == api.RequiredState
+}
+
+func (e *caseEnv) state(scenario string) string {
+	if state, ok := e.scenarios[scenario]; ok {
+		return state
+	}
+	return interact.ScenarioStarted
+}
+
+// transit move the scenario of the matched mock to its new state.
+func (e *caseEnv) transit(api *interact.ImposterMockCase) {
+	if api.Scenario != "" && api.NewState != "" {
+		e.scenarios[api.Scenario] = api.NewState
+	}
 }
 
 type Manager struct {
-	caseApis   []*interact.ImposterMockCase
-	itfApis    []*interact.ImposterMockCase
-	globalApis []*interact.ImposterMockCase
-	cfg        *Config
+	// key: environment id.
+	envs map[string]*caseEnv
+	cfg  *Config
 	logger.Logger
 	lock         sync.RWMutex
 	caseProvider caseprovider.Provider
@@ -79,9 +133,7 @@ func New(log logger.Logger, cfg *Config, caseProvider caseprovider.Provider) Pro
 		cfg:          cfg,
 		caseProvider: caseProvider,
 		Logger:       log.NewLogger("proto"),
-		caseApis:     make([]*interact.ImposterMockCase, 0),
-		itfApis:      make([]*interact.ImposterMockCase, 0),
-		globalApis:   make([]*interact.ImposterMockCase, 0),
+		envs:         make(map[string]*caseEnv),
 	}
 }
 
@@ -91,61 +143,305 @@ func (m *Manager) MockResponse(ctx context.Context, request *interact.Request) (
 		return nil, fmt.Errorf("cannot mock http request: %v", request)
 	}
 
+	response := &api.Response
+	if response.Template {
+		var err error
+		if response, err = m.renderResponse(api, request); err != nil {
+			return nil, err
+		}
+	}
+
+	if response.Fault != nil {
+		response = applyFault(response, request.Protocol)
+	}
+
 	// block request.
-	if api.Response.Delay != nil {
-		time.Sleep(api.Response.Delay.GetDelay())
+	if response.Delay != nil {
+		time.Sleep(response.Delay.GetDelay())
 	}
 
-	return &api.Response, nil
+	return response, nil
 }
 
-// MatchAPI is used to match MockAPI
-func (m *Manager) MatchAPI(req *interact.Request) (*interact.ImposterMockCase, bool) {
-	m.lock.RLock()
-	caseApis := m.caseApis
-	itfApis := m.itfApis
-	globalApis := m.globalApis
-	m.lock.RUnlock()
+// applyFault returns the response to send on this call, the mock servers simulate the remaining fault.
+func applyFault(response *interact.Response, protocol interact.Protocol) *interact.Response {
+	out := *response
+	if !response.Fault.Hit() {
+		out.Fault = nil
+		return &out
+	}
 
-	// Matching Priority: case -> interface -> global
-	for _, api := range caseApis {
-		if m.match(req, &api.Request) {
-			return api, true
+	if response.Fault.Type != interact.FaultError {
+		return &out
+	}
+
+	out.Fault = nil
+	out.Body = ""
+	out.Status = response.Fault.Status
+	if out.Status == 0 {
+		if protocol == interact.ProtocolGRPC {
+			out.Status = int(codes.Unavailable)
+		} else {
+			out.Status = http.StatusServiceUnavailable
 		}
 	}
+	return &out
+}
 
-	for _, api := range itfApis {
-		if m.match(req, &api.Request) {
-			return api, true
+// renderResponse returns a copy of the mock response whose header values and body are rendered with the request.
+func (m *Manager) renderResponse(api *interact.ImposterMockCase, req *interact.Request) (*interact.Response, error) {
+	data := map[string]interface{}{
+		"request": requestTemplateData(req, &api.Request),
+	}
+	if env := m.getEnv(getCorrelationID(req.Header)); env != nil {
+		for k, v := range env.data {
+			data[k] = v
+		}
+	}
+
+	out := api.Response
+	out.Header = make(map[string][]string, len(api.Response.Header))
+	for k, values := range api.Response.Header {
+		rendered, err := render.Strings(values, data)
+		if err != nil {
+			return nil, fmt.Errorf("render mock response header [%s] error: %v", k, err)
+		}
+		out.Header[k] = rendered
+	}
+
+	// the files referenced by the body are sent as is.
+	if s, ok := out.Body.(string); !ok || !isFileBody(s) {
+		body, err := render.Value(api.Response.Body, data)
+		if err != nil {
+			return nil, fmt.Errorf("render mock response body error: %v", err)
+		}
+		out.Body = body
+	}
+	return &out, nil
+}
+
+// requestTemplateData the incoming request exposed to the response templates as .request,
+// a JSON body is parsed so that its fields can be used, e.g. {{ .request.body.id }}.
+func requestTemplateData(req, target *interact.Request) map[string]interface{} {
+	body := req.GetBodyString()
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(body), &parsed); err != nil {
+		parsed = body
+	}
+
+	params := map[string]string{}
+	if match, ok := routeMatch(req, target); ok {
+		params = match.Vars
+	}
+
+	return map[string]interface{}{
+		"method": req.Method,
+		"host":   req.Host,
+		"path":   req.Path,
+		"params": params,
+		"query":  req.Query,
+		"header": req.Header,
+		"body":   parsed,
+	}
+}
+
+func isFileBody(body string) bool {
+	return strings.HasPrefix(body, common.StreamFilePrefix) || strings.HasPrefix(body, common.MultiFilePrefix)
+}
+
+// MatchAPI is used to match MockAPI
+func (m *Manager) MatchAPI(req *interact.Request) (*interact.ImposterMockCase, bool) {
+	id := getCorrelationID(req.Header)
+	env := m.getEnv(id)
+	if env == nil {
+		m.lock.RLock()
+		active := len(m.envs)
+		m.lock.RUnlock()
+		if active > 1 {
+			m.Warn(map[string]interface{}{"correlationId": id, "activeCases": active},
+				"cannot tell the case of the request [%s %s], the target should forward the %s header",
+				req.Method, req.Path, CorrelationHeader)
 		}
+		return nil, false
 	}
 
-	for _, api := range globalApis {
-		if m.match(req, &api.Request) {
+	// the scenario state is checked and moved atomically, concurrent requests cannot skip a state.
+	env.lock.Lock()
+	defer env.lock.Unlock()
+
+	for _, api := range env.apis {
+		if env.inState(api) && m.match(req, &api.Request) {
+			env.transit(api)
+			env.record(req, true)
 			return api, true
 		}
 	}
 
+	env.record(req, false)
 	return nil, false
 }
 
-func (m *Manager) LoadCaseEnv(itfName, caseName string) {
+func (e *caseEnv) record(req *interact.Request, matched bool) {
+	call := interact.Call{Request: *req, Matched: matched, Time: time.Now()}
+	// keep the body readable, a []byte would be encoded as base64.
+	if b, ok := req.Body.([]byte); ok {
+		call.Request.Body = string(b)
+	}
+	e.calls = append(e.calls, call)
+}
+
+// MatchRoute whether the request matches the host, method and path pattern of the target,
+// an empty method matches any method.
+func MatchRoute(req, target *interact.Request) bool {
+	if target.Method == "" {
+		t := *target
+		t.Method = req.Method
+		target = &t
+	}
+	_, matched := routeMatch(req, target)
+	return matched
+}
+
+func (m *Manager) LoadCaseEnv(itfName, caseName string, data map[string]interface{}) string {
+	id := uuid.New().String()
+	// Matching Priority: priority, then case -> interface -> global
+	var apis []*interact.ImposterMockCase
+	apis = append(apis, m.renderMocks(m.caseProvider.GetMockCasesFromCase(itfName, caseName), data)...)
+	apis = append(apis, m.renderMocks(m.caseProvider.GetMockCasesFromItf(itfName), data)...)
+	apis = append(apis, m.renderMocks(m.caseProvider.GetMockCasesFromGlobals(), data)...)
+	sort.SliceStable(apis, func(i, j int) bool {
+		return apis[i].Priority > apis[j].Priority
+	})
+
+	env := &caseEnv{
+		data:      data,
+		scenarios: make(map[string]string),
+		apis:      apis,
+	}
+
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	m.globalApis = m.caseProvider.GetMockCasesFromGlobals()
-	m.caseApis = m.caseProvider.GetMockCasesFromCase(itfName, caseName)
-	m.itfApis = m.caseProvider.GetMockCasesFromItf(itfName)
+	m.envs[id] = env
+	return id
 }
 
-func (m *Manager) ClearCaseEnv() {
+func (m *Manager) ClearCaseEnv(id string) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	m.globalApis = make([]*interact.ImposterMockCase, 0)
-	m.caseApis = make([]*interact.ImposterMockCase, 0)
-	m.itfApis = make([]*interact.ImposterMockCase, 0)
+	delete(m.envs, id)
 }
 
-func (m *Manager) match(req, target *interact.Request) bool {
+func (m *Manager) GetCalls(id string) []interact.Call {
+	m.lock.RLock()
+	env, ok := m.envs[id]
+	m.lock.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	env.lock.Lock()
+	defer env.lock.Unlock()
+	return append([]interact.Call(nil), env.calls...)
+}
+
+func (m *Manager) GetScenarios() map[string]map[string]string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	out := make(map[string]map[string]string, len(m.envs))
+	for id, env := range m.envs {
+		env.lock.Lock()
+		states := make(map[string]string)
+		for _, api := range env.apis {
+			if api.Scenario != "" {
+				states[api.Scenario] = env.state(api.Scenario)
+			}
+		}
+		env.lock.Unlock()
+		out[id] = states
+	}
+	return out
+}
+
+func (m *Manager) ResetScenarios(id, scenario string) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for envID, env := range m.envs {
+		if id != "" && id != envID {
+			continue
+		}
+
+		env.lock.Lock()
+		if scenario == "" {
+			env.scenarios = make(map[string]string)
+		} else {
+			delete(env.scenarios, scenario)
+		}
+		env.lock.Unlock()
+	}
+}
+
+// renderMocks returns the mocks rendered with the data, a mock that fails to render is kept as is.
+func (m *Manager) renderMocks(mocks []*interact.ImposterMockCase, data map[string]interface{}) []*interact.ImposterMockCase {
+	out := make([]*interact.ImposterMockCase, 0, len(mocks))
+	for _, mock := range mocks {
+		if !render.HasAction(mock) {
+			out = append(out, mock)
+			continue
+		}
+
+		// the response of a template mock is rendered on each request, together with the request data.
+		var (
+			err      error
+			rendered = &interact.ImposterMockCase{}
+		)
+		if mock.Response.Template {
+			// keep the other fields of the mock (priority, scenario...), only the request is rendered now.
+			*rendered = *mock
+			rendered.Request = interact.Request{}
+			err = render.Into(mock.Request, &rendered.Request, data)
+		} else {
+			err = render.Into(mock, rendered, data)
+		}
+		if err != nil {
+			m.Warn(nil, "render mock [%s %s] failed: %v", mock.Request.Method, mock.Request.Path, err)
+			out = append(out, mock)
+			continue
+		}
+		out = append(out, rendered)
+	}
+	return out
+}
+
+// getEnv returns the environment of the id, a request without a known id uses the environment only when
+// a single case is running, otherwise the mocks and the calls of another case could be used.
+func (m *Manager) getEnv(id string) *caseEnv {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if env, ok := m.envs[id]; ok {
+		return env
+	}
+
+	if len(m.envs) == 1 {
+		for _, env := range m.envs {
+			return env
+		}
+	}
+	return nil
+}
+
+// the header keys of grpc metadata are lowercase, so the key is compared case-insensitively.
+func getCorrelationID(header map[string][]string) string {
+	for k, v := range header {
+		if strings.EqualFold(k, CorrelationHeader) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// routeMatch match the request against the host, method and path of the target,
+// the path parameters (e.g. /users/{id}) are captured into the route match.
+func routeMatch(req, target *interact.Request) (*mux.RouteMatch, bool) {
 	// use mux match single router
 	var match mux.RouteMatch
 	matched := mux.NewRouter().
@@ -157,7 +453,11 @@ func (m *Manager) match(req, target *interact.Request) bool {
 			URL:    &url.URL{Path: req.Path},
 			Host:   req.Host,
 		}, &match)
-	if !matched {
+	return &match, matched
+}
+
+func (m *Manager) match(req, target *interact.Request) bool {
+	if _, matched := routeMatch(req, target); !matched {
 		return false
 	}
 
@@ -171,6 +471,13 @@ func (m *Manager) match(req, target *interact.Request) bool {
 		return false
 	}
 
+	if target.Matchers != nil {
+		if err := matchFields(req, target.Matchers); err != nil {
+			m.Trace(nil, "mock matchers cannot hit: %v", err)
+			return false
+		}
+	}
+
 	if req.Body != nil && target.Body != nil {
 		ct := textproto.MIMEHeader(req.Header).Get("Content-Type")
 		if ct == "" {
@@ -189,6 +496,43 @@ func (m *Manager) match(req, target *interact.Request) bool {
 	return true
 }
 
+// matchFields match the request against the matchers of the mock.
+func matchFields(req *interact.Request, matchers *interact.RequestMatchers) error {
+	for name, mt := range matchers.Header {
+		v, ok := lookup(req.Header, name, true)
+		if err := mt.Match(v, ok); err != nil {
+			return fmt.Errorf("header [%s]: %v", name, err)
+		}
+	}
+
+	for name, mt := range matchers.Query {
+		v, ok := lookup(req.Query, name, false)
+		if err := mt.Match(v, ok); err != nil {
+			return fmt.Errorf("query [%s]: %v", name, err)
+		}
+	}
+
+	if len(matchers.Body) > 0 {
+		body := req.GetBodyString()
+		for _, mt := range matchers.Body {
+			if err := mt.MatchDocument(body); err != nil {
+				return fmt.Errorf("body %s: %v", mt.JSONPath, err)
+			}
+		}
+	}
+	return nil
+}
+
+// lookup returns the first value of the key, the header names are case-insensitive.
+func lookup(values map[string][]string, key string, foldCase bool) (string, bool) {
+	for k, v := range values {
+		if (k == key || foldCase && strings.EqualFold(k, key)) && len(v) > 0 {
+			return v[0], true
+		}
+	}
+	return "", false
+}
+
 func (m *Manager) urlEncodeCompare(targetBody, reqBody string) bool {
 	reqData, err := url.ParseQuery(reqBody)
 	if err != nil {
@@ -18,10 +18,14 @@
 package apimanager
 
 import (
+	"context"
+	"reflect"
 	"testing"
 
+	"github.com/alsritter/middlebaby/pkg/caseprovider"
 	"github.com/alsritter/middlebaby/pkg/types/interact"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/alsritter/middlebaby/pkg/util/matcher"
 )
 
 func TestManager_match(t *testing.T) {
@@ -167,3 +171,312 @@ func TestManager_match(t *testing.T) {
 		})
 	}
 }
+
+type fakeCaseProvider struct {
+	caseprovider.Provider
+}
+
+func (fakeCaseProvider) GetMockCasesFromGlobals() []*interact.ImposterMockCase {
+	return nil
+}
+
+func (fakeCaseProvider) GetMockCasesFromItf(string) []*interact.ImposterMockCase {
+	return nil
+}
+
+// every case returns a mock whose response status is the length of the case name.
+func (fakeCaseProvider) GetMockCasesFromCase(_, caseName string) []*interact.ImposterMockCase {
+	return []*interact.ImposterMockCase{{
+		Request:  interact.Request{Method: "GET", Path: "/path"},
+		Response: interact.Response{Status: len(caseName)},
+	}}
+}
+
+func TestManager_MatchAPI_CorrelationID(t *testing.T) {
+	m := New(logger.NewDefault("test"), NewConfig(), fakeCaseProvider{}).(*Manager)
+	first := m.LoadCaseEnv("itf", "a", nil)
+	second := m.LoadCaseEnv("itf", "bb", nil)
+
+	request := func(header map[string][]string) *interact.Request {
+		return &interact.Request{Method: "GET", Path: "/path", Header: header}
+	}
+
+	tests := []struct {
+		name   string
+		header map[string][]string
+		want   int
+	}{
+		{name: "the first environment", header: map[string][]string{CorrelationHeader: {first}}, want: 1},
+		{name: "the second environment", header: map[string][]string{CorrelationHeader: {second}}, want: 2},
+		{name: "grpc metadata keys are lowercase", header: map[string][]string{"x-middlebaby-correlation-id": {first}}, want: 1},
+		// the case of the request cannot be told while several cases are running.
+		{name: "without id", header: nil, want: 0},
+		{name: "unknown id", header: map[string][]string{CorrelationHeader: {"unknown"}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, ok := m.MatchAPI(request(tt.header))
+			if tt.want == 0 {
+				if ok {
+					t.Errorf("Manager.MatchAPI() = %v, want no match", api)
+				}
+				return
+			}
+			if !ok || api.Response.Status != tt.want {
+				t.Errorf("Manager.MatchAPI() = %v, %v, want status %d", api, ok, tt.want)
+			}
+		})
+	}
+
+	// a single running case receives the requests without id.
+	m.ClearCaseEnv(second)
+	if api, ok := m.MatchAPI(request(nil)); !ok || api.Response.Status != 1 {
+		t.Errorf("Manager.MatchAPI() after clear = %v, %v", api, ok)
+	}
+	m.ClearCaseEnv(first)
+	if _, ok := m.MatchAPI(request(nil)); ok {
+		t.Errorf("Manager.MatchAPI() matched without any environment")
+	}
+}
+
+type templateCaseProvider struct {
+	fakeCaseProvider
+}
+
+func (templateCaseProvider) GetMockCasesFromCase(_, _ string) []*interact.ImposterMockCase {
+	return []*interact.ImposterMockCase{{
+		Request: interact.Request{Method: "POST", Path: "/users/{id}"},
+		Response: interact.Response{
+			Status:   200,
+			Header:   map[string][]string{"X-Request-Id": {"{{ index .request.header \"X-Request-Id\" 0 }}"}},
+			Body:     `{"id":"{{ .request.params.id }}","name":"{{ .request.body.name }}","order":"{{ .vars.orderId }}"}`,
+			Template: true,
+		},
+	}}
+}
+
+func TestManager_MockResponse_Template(t *testing.T) {
+	m := New(logger.NewDefault("test"), NewConfig(), templateCaseProvider{}).(*Manager)
+	m.LoadCaseEnv("itf", "case", map[string]interface{}{"vars": map[string]interface{}{"orderId": "o-1"}})
+
+	got, err := m.MockResponse(context.TODO(), &interact.Request{
+		Method: "POST",
+		Path:   "/users/42",
+		Header: map[string][]string{"X-Request-Id": {"r-1"}},
+		Body:   []byte(`{"name":"John"}`),
+	})
+	if err != nil {
+		t.Fatalf("Manager.MockResponse() error = %v", err)
+	}
+
+	if want := `{"id":"42","name":"John","order":"o-1"}`; got.Body != want {
+		t.Errorf("Manager.MockResponse() body = %v, want %v", got.Body, want)
+	}
+	if want := []string{"r-1"}; !reflect.DeepEqual(got.Header["X-Request-Id"], want) {
+		t.Errorf("Manager.MockResponse() header = %v, want %v", got.Header, want)
+	}
+}
+
+type scenarioCaseProvider struct {
+	fakeCaseProvider
+}
+
+// the job is pending on the first two polls and done on the third.
+func (scenarioCaseProvider) GetMockCasesFromCase(_, _ string) []*interact.ImposterMockCase {
+	mock := func(required, next, body string) *interact.ImposterMockCase {
+		return &interact.ImposterMockCase{
+			Request:       interact.Request{Method: "GET", Path: "/job"},
+			Response:      interact.Response{Status: 200, Body: body},
+			Scenario:      "job",
+			RequiredState: required,
+			NewState:      next,
+		}
+	}
+	return []*interact.ImposterMockCase{
+		mock(interact.ScenarioStarted, "polled", "pending"),
+		mock("polled", "polled twice", "pending"),
+		mock("polled twice", "", "done"),
+	}
+}
+
+func TestManager_LoadCaseEnv_TemplateMockFields(t *testing.T) {
+	m := New(logger.NewDefault("test"), NewConfig(), templateFieldsCaseProvider{}).(*Manager)
+	id := m.LoadCaseEnv("itf", "case", map[string]interface{}{"vars": map[string]interface{}{"id": "42"}})
+
+	apis := m.getEnv(id).apis
+	if len(apis) != 2 {
+		t.Fatalf("Manager.LoadCaseEnv() loaded %d mocks, want 2", len(apis))
+	}
+
+	// the template mock has the higher priority, it is sorted first.
+	got := apis[0]
+	if got.Priority != 5 || got.Scenario != "job" || got.RequiredState != interact.ScenarioStarted ||
+		got.NewState != "done" || got.Request.Path != "/jobs/42" || !got.Response.Template {
+		t.Errorf("Manager.LoadCaseEnv() template mock = %+v, want its fields kept", got)
+	}
+}
+
+type templateFieldsCaseProvider struct {
+	fakeCaseProvider
+}
+
+func (templateFieldsCaseProvider) GetMockCasesFromCase(_, _ string) []*interact.ImposterMockCase {
+	return []*interact.ImposterMockCase{
+		{
+			Request:  interact.Request{Method: "GET", Path: "/jobs/{{ .vars.id }}"},
+			Response: interact.Response{Status: 200},
+			Priority: 1,
+		},
+		{
+			Request:       interact.Request{Method: "GET", Path: "/jobs/{{ .vars.id }}"},
+			Response:      interact.Response{Status: 200, Body: `{{ .request.path }}`, Template: true},
+			Priority:      5,
+			Scenario:      "job",
+			RequiredState: interact.ScenarioStarted,
+			NewState:      "done",
+		},
+	}
+}
+
+func TestManager_MatchAPI_Scenario(t *testing.T) {
+	m := New(logger.NewDefault("test"), NewConfig(), scenarioCaseProvider{}).(*Manager)
+	id := m.LoadCaseEnv("itf", "case", nil)
+	req := &interact.Request{Method: "GET", Path: "/job"}
+
+	poll := func() interface{} {
+		api, ok := m.MatchAPI(req)
+		if !ok {
+			t.Fatalf("Manager.MatchAPI() no mock matched")
+		}
+		return api.Response.Body
+	}
+
+	for i, want := range []string{"pending", "pending", "done", "done"} {
+		if got := poll(); got != want {
+			t.Errorf("Manager.MatchAPI() poll %d = %v, want %v", i, got, want)
+		}
+	}
+
+	if got := m.GetScenarios()[id]["job"]; got != "polled twice" {
+		t.Errorf("Manager.GetScenarios() = %v, want %v", got, "polled twice")
+	}
+
+	m.ResetScenarios(id, "")
+	if got := poll(); got != "pending" {
+		t.Errorf("Manager.MatchAPI() after reset = %v, want pending", got)
+	}
+
+	// a new environment starts from the initial state.
+	m.ClearCaseEnv(id)
+	m.LoadCaseEnv("itf", "case", nil)
+	if got := poll(); got != "pending" {
+		t.Errorf("Manager.MatchAPI() in new environment = %v, want pending", got)
+	}
+}
+
+func TestManager_GetCalls(t *testing.T) {
+	m := New(logger.NewDefault("test"), NewConfig(), fakeCaseProvider{}).(*Manager)
+	id := m.LoadCaseEnv("itf", "a", nil)
+
+	m.MatchAPI(&interact.Request{Method: "GET", Path: "/path", Body: []byte(`{"a":1}`)})
+	m.MatchAPI(&interact.Request{Method: "GET", Path: "/unknown"})
+
+	calls := m.GetCalls(id)
+	if len(calls) != 2 || !calls[0].Matched || calls[1].Matched {
+		t.Fatalf("Manager.GetCalls() = %+v", calls)
+	}
+	if calls[0].Request.Body != `{"a":1}` {
+		t.Errorf("Manager.GetCalls() body = %v", calls[0].Request.Body)
+	}
+
+	m.ClearCaseEnv(id)
+	if calls := m.GetCalls(id); calls != nil {
+		t.Errorf("Manager.GetCalls() after clear = %+v", calls)
+	}
+}
+
+func Test_applyFault(t *testing.T) {
+	response := &interact.Response{Status: 200, Body: "ok"}
+	tests := []struct {
+		name       string
+		fault      *interact.ResponseFault
+		protocol   interact.Protocol
+		wantStatus int
+		wantFault  bool
+	}{
+		{name: "http error", fault: &interact.ResponseFault{Type: interact.FaultError}, protocol: interact.ProtocolHTTP, wantStatus: 503},
+		{name: "grpc error", fault: &interact.ResponseFault{Type: interact.FaultError}, protocol: interact.ProtocolGRPC, wantStatus: 14},
+		{name: "custom status", fault: &interact.ResponseFault{Type: interact.FaultError, Status: 500}, protocol: interact.ProtocolHTTP, wantStatus: 500},
+		{name: "connection fault", fault: &interact.ResponseFault{Type: interact.FaultEmptyReply}, protocol: interact.ProtocolHTTP, wantStatus: 200, wantFault: true},
+		{name: "missed", fault: &interact.ResponseFault{Type: interact.FaultError, Percentage: 1e-9}, protocol: interact.ProtocolHTTP, wantStatus: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *response
+			r.Fault = tt.fault
+			got := applyFault(&r, tt.protocol)
+			if got.Status != tt.wantStatus || (got.Fault != nil) != tt.wantFault {
+				t.Errorf("applyFault() = %+v, want status %d, fault %v", got, tt.wantStatus, tt.wantFault)
+			}
+		})
+	}
+}
+
+type priorityCaseProvider struct {
+	fakeCaseProvider
+}
+
+func (priorityCaseProvider) GetMockCasesFromGlobals() []*interact.ImposterMockCase {
+	return []*interact.ImposterMockCase{{
+		Request:  interact.Request{Method: "POST", Path: "/orders"},
+		Response: interact.Response{Status: 500},
+		Priority: 10,
+	}}
+}
+
+func (priorityCaseProvider) GetMockCasesFromCase(_, _ string) []*interact.ImposterMockCase {
+	return []*interact.ImposterMockCase{
+		{
+			Request:  interact.Request{Method: "POST", Path: "/orders"},
+			Response: interact.Response{Status: 200},
+		},
+		{
+			Request: interact.Request{Method: "POST", Path: "/orders", Matchers: &interact.RequestMatchers{
+				Header: map[string]*matcher.Matcher{"x-token": {Contains: "admin"}},
+				Body:   []*matcher.Matcher{{JSONPath: "sku", OneOf: []interface{}{"A1", "B2"}}},
+			}},
+			Response: interact.Response{Status: 201},
+			Priority: 20,
+		},
+	}
+}
+
+func TestManager_MatchAPI_Priority(t *testing.T) {
+	m := New(logger.NewDefault("test"), NewConfig(), priorityCaseProvider{}).(*Manager)
+	m.LoadCaseEnv("itf", "case", nil)
+
+	tests := []struct {
+		name string
+		req  *interact.Request
+		want int
+	}{
+		{
+			name: "matchers of the highest priority",
+			req:  &interact.Request{Method: "POST", Path: "/orders", Header: map[string][]string{"X-Token": {"admin-1"}}, Body: []byte(`{"sku":"A1"}`)},
+			want: 201,
+		},
+		{
+			name: "global mock with a higher priority than the case",
+			req:  &interact.Request{Method: "POST", Path: "/orders", Body: []byte(`{"sku":"C3"}`)},
+			want: 500,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, ok := m.MatchAPI(tt.req)
+			if !ok || api.Response.Status != tt.want {
+				t.Errorf("Manager.MatchAPI() = %v, %v, want status %d", api, ok, tt.want)
+			}
+		})
+	}
+}
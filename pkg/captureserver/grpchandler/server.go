@@ -28,6 +28,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/alsritter/middlebaby/pkg/captureserver/recorder"
 	"github.com/alsritter/middlebaby/pkg/messagepush"
 	"github.com/alsritter/middlebaby/pkg/protomanager"
 	"github.com/alsritter/middlebaby/pkg/types/interact"
@@ -49,6 +50,7 @@ type captureServer struct {
 	curConnId    uint64
 	protoManager protomanager.Provider
 	msgPush      messagepush.Provider
+	recorder     recorder.Provider
 }
 
 type Provider interface {
@@ -56,11 +58,12 @@ type Provider interface {
 	GetServer() http.Handler
 }
 
-func New(log logger.Logger, protoManager protomanager.Provider, msgPush messagepush.Provider) Provider {
+func New(log logger.Logger, protoManager protomanager.Provider, msgPush messagepush.Provider, recorder recorder.Provider) Provider {
 	return &captureServer{
 		Logger:       log.NewLogger("grpc-capture"),
 		protoManager: protoManager,
 		msgPush:      msgPush,
+		recorder:     recorder,
 	}
 }
 
@@ -121,7 +124,7 @@ func (s *captureServer) handleStream(srv interface{}, stream grpc.ServerStream)
 	}
 
 	s.WithContext(stream.Context()).Debug(nil, "capture [%s] request [%+v]", fullMethodName, dto)
-	mds, trailer, responseStr, respStatus, err := ggrpcurl.NewInvokeGRpc(&dto).Invoke()
+	mds, trailer, responseStr, respStatus, err := ggrpcurl.NewInvokeGRpc(&dto).Invoke(stream.Context())
 	if err != nil {
 		return s.sendError(stream.Context(), err)
 	}
@@ -153,8 +156,13 @@ func (s *captureServer) handleStream(srv interface{}, stream grpc.ServerStream)
 		return s.sendError(stream.Context(), multierror.Prefix(err, "failed to marshal:"))
 	}
 
-	jsonData, err := json.Marshal(interact.GRPCConvert(md, dto, mds, trailer, responseStr, respStatus))
-	if err != nil {
+	mock := interact.GRPCConvert(md, dto, mds, trailer, responseStr, respStatus)
+	if err := s.recorder.Record(mock); err != nil {
+		s.Error(nil, "record grpc mock failed: [%v]", err)
+	}
+
+	jsonData, err := json.Marshal(mock)
+	if err == nil {
 		if err = s.msgPush.SendMessage(msgpush.PushMessage{
 			Extra:       time.Now().Format("2006-01-02T15:04:05Z07:00"),
 			ID:          atomic.AddUint64(&s.curConnId, 1),
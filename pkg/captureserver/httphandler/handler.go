@@ -28,6 +28,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/alsritter/middlebaby/pkg/captureserver/recorder"
 	"github.com/alsritter/middlebaby/pkg/messagepush"
 	"github.com/alsritter/middlebaby/pkg/types/interact"
 	"github.com/alsritter/middlebaby/pkg/types/msgpush"
@@ -39,7 +40,8 @@ import (
 type delegateHandler struct {
 	curConnId uint64
 	logger.Logger
-	msgPush messagepush.Provider
+	msgPush  messagepush.Provider
+	recorder recorder.Provider
 }
 
 // Connect check the request type.
@@ -69,6 +71,10 @@ func (e *delegateHandler) BeforeResponse(ctx *goproxy.Context, resp *http.Respon
 	if err != nil {
 		e.Error(nil, "request or response converter failed: [%v]", err)
 	} else {
+		if err := e.recorder.Record(dto); err != nil {
+			e.Error(nil, "record http mock failed: [%v]", err)
+		}
+
 		jsonData, err := json.Marshal(dto)
 		if err != nil {
 			e.Error(nil, "marshal http request failed: [%v]", err)
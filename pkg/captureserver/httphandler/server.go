@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/http/httptrace"
 
+	"github.com/alsritter/middlebaby/pkg/captureserver/recorder"
 	"github.com/alsritter/middlebaby/pkg/messagepush"
 	"github.com/alsritter/middlebaby/pkg/util/goproxy"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
@@ -36,13 +37,14 @@ type captureServer struct {
 	logger.Logger
 }
 
-func New(log logger.Logger, msgPush messagepush.Provider) Provider {
+func New(log logger.Logger, msgPush messagepush.Provider, recorder recorder.Provider) Provider {
 	l := log.NewLogger("http-capture")
 	return &captureServer{
 		Logger: l,
 		Proxy: goproxy.New(goproxy.WithDelegate(&delegateHandler{
-			Logger:  l,
-			msgPush: msgPush,
+			Logger:   l,
+			msgPush:  msgPush,
+			recorder: recorder,
 		}),
 			goproxy.WithDecryptHTTPS(&cache{}),
 			goproxy.WithClientTrace(&httptrace.ClientTrace{
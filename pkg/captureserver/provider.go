@@ -10,6 +10,7 @@ import (
 
 	"github.com/alsritter/middlebaby/pkg/captureserver/grpchandler"
 	"github.com/alsritter/middlebaby/pkg/captureserver/httphandler"
+	"github.com/alsritter/middlebaby/pkg/captureserver/recorder"
 	"github.com/alsritter/middlebaby/pkg/messagepush"
 	"github.com/alsritter/middlebaby/pkg/protomanager"
 	"github.com/alsritter/middlebaby/pkg/util"
@@ -21,12 +22,14 @@ import (
 )
 
 type Config struct {
-	CapturePort int `json:"capturePort" yaml:"capturePort"`
+	CapturePort int              `json:"capturePort" yaml:"capturePort"`
+	Record      *recorder.Config `json:"record" yaml:"record"`
 }
 
 func NewConfig() *Config {
 	return &Config{
 		CapturePort: 58321,
+		Record:      recorder.NewConfig(),
 	}
 }
 
@@ -34,11 +37,19 @@ func (c *Config) Validate() error {
 	if c.CapturePort == 0 {
 		return errors.New("[capture-server] capture server listener port cannot be empty")
 	}
+	if c.Record == nil {
+		return errors.New("[capture-server] record config cannot be empty")
+	}
+	if err := c.Record.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
 // RegisterFlagsWithPrefix is used to register flags
-func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {}
+func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {
+	c.Record.RegisterFlagsWithPrefix(prefix+"capture.", f)
+}
 
 type Provider interface {
 	GetPort() int
@@ -57,12 +68,13 @@ type captrueServer struct {
 }
 
 func New(log logger.Logger, cfg *Config, protoManager protomanager.Provider, msgPush messagepush.Provider) Provider {
+	rec := recorder.New(log, cfg.Record)
 	return &captrueServer{
 		Logger:       log.NewLogger("capture"),
 		cfg:          cfg,
 		server:       &http.Server{},
-		grpcProvider: grpchandler.New(log, protoManager, msgPush),
-		httpProvider: httphandler.New(log, msgPush),
+		grpcProvider: grpchandler.New(log, protoManager, msgPush, rec),
+		httpProvider: httphandler.New(log, msgPush, rec),
 	}
 }
 
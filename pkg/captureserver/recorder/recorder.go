@@ -0,0 +1,290 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package recorder writes the traffic captured by the capture server into mock files.
+package recorder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/flynn/json5"
+	"github.com/spf13/pflag"
+)
+
+// Config defines the config structure, the record mode is enabled when File or CaseFile is set.
+type Config struct {
+	// File the global mock file (*.mock.json) the captured mocks are written into.
+	File string `yaml:"file"`
+	// CaseFile and CaseName the case whose mocks the captured mocks are written into.
+	CaseFile string `yaml:"caseFile"`
+	CaseName string `yaml:"caseName"`
+	// AllowHeaders the request and response headers kept in the mocks, the others are dropped.
+	AllowHeaders []string `yaml:"allowHeaders"`
+	// IgnoreFields the dotted paths of the JSON body fields dropped from the mocks (e.g. "data.createdAt"),
+	// so that volatile values do not prevent the mocks from matching.
+	IgnoreFields []string `yaml:"ignoreFields"`
+}
+
+// NewConfig is used to init config with default values
+func NewConfig() *Config {
+	return &Config{
+		AllowHeaders: []string{"Content-Type"},
+	}
+}
+
+// Validate is used to validate config and returns error on failure
+func (c *Config) Validate() error {
+	if c.File != "" && c.CaseFile != "" {
+		return errors.New("[recorder] file and case file cannot be set at the same time")
+	}
+	if c.CaseFile != "" && c.CaseName == "" {
+		return errors.New("[recorder] case name is required to record into a case file")
+	}
+	for _, f := range []string{c.File, c.CaseFile} {
+		if strings.HasSuffix(f, ".yaml") || strings.HasSuffix(f, ".yml") {
+			return fmt.Errorf("[recorder] cannot record into the YAML file [%s], use a JSON file", f)
+		}
+	}
+	return nil
+}
+
+// RegisterFlagsWithPrefix is used to register flags
+func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {
+	f.StringVar(&c.File, prefix+"record.file", c.File, "record the captured traffic into this global mock file")
+	f.StringVar(&c.CaseFile, prefix+"record.case-file", c.CaseFile, "record the captured traffic into the mocks of a case of this file")
+	f.StringVar(&c.CaseName, prefix+"record.case-name", c.CaseName, "the case the captured traffic is recorded into")
+}
+
+// Enabled whether the record mode is on.
+func (c *Config) Enabled() bool {
+	return c.File != "" || c.CaseFile != ""
+}
+
+// Provider records captured exchanges.
+type Provider interface {
+	Record(mock *interact.ImposterMockCase) error
+}
+
+type recorder struct {
+	logger.Logger
+	cfg  *Config
+	lock sync.Mutex
+}
+
+// New returns a recorder, Record does nothing if the record mode is off.
+func New(log logger.Logger, cfg *Config) Provider {
+	return &recorder{
+		Logger: log.NewLogger("recorder"),
+		cfg:    cfg,
+	}
+}
+
+// Record implements Provider
+// The mock is normalized and appended to the target file unless an equivalent mock exists.
+func (r *recorder) Record(mock *interact.ImposterMockCase) error {
+	if !r.cfg.Enabled() {
+		return nil
+	}
+
+	mock = r.normalize(mock)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var err error
+	var added bool
+	if r.cfg.File != "" {
+		added, err = r.recordGlobal(mock)
+	} else {
+		added, err = r.recordCase(mock)
+	}
+	if err != nil {
+		return err
+	}
+
+	if added {
+		r.Info(nil, "recorded mock [%s %s%s]", mock.Request.Method, mock.Request.Host, mock.Request.Path)
+	} else {
+		r.Debug(nil, "mock [%s %s%s] has already been recorded", mock.Request.Method, mock.Request.Host, mock.Request.Path)
+	}
+	return nil
+}
+
+func (r *recorder) recordGlobal(mock *interact.ImposterMockCase) (bool, error) {
+	var mocks []*interact.ImposterMockCase
+	b, err := ioutil.ReadFile(r.cfg.File)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("read mock file [%s] error: [%v]", r.cfg.File, err)
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &mocks); err != nil {
+			return false, fmt.Errorf("unmarshal mock file [%s] error: [%v]", r.cfg.File, err)
+		}
+	}
+
+	if contains(mocks, mock) {
+		return false, nil
+	}
+	return true, writeJSON(r.cfg.File, append(mocks, mock))
+}
+
+func (r *recorder) recordCase(mock *interact.ImposterMockCase) (bool, error) {
+	b, err := ioutil.ReadFile(r.cfg.CaseFile)
+	if err != nil {
+		return false, fmt.Errorf("read case file [%s] error: [%v]", r.cfg.CaseFile, err)
+	}
+
+	var itf mbcase.ItfTask
+	if err := json5.Unmarshal(b, &itf); err != nil {
+		return false, fmt.Errorf("unmarshal case file [%s] error: [%v]", r.cfg.CaseFile, err)
+	}
+
+	for _, c := range itf.Cases {
+		if c.Name != r.cfg.CaseName {
+			continue
+		}
+		if contains(c.Mocks, mock) {
+			return false, nil
+		}
+		c.Mocks = append(c.Mocks, mock)
+		return true, writeJSON(r.cfg.CaseFile, &itf)
+	}
+	return false, fmt.Errorf("cannot find case [%s] in [%s]", r.cfg.CaseName, r.cfg.CaseFile)
+}
+
+// normalize keep the allowed headers only and parse the JSON bodies without the ignored fields.
+func (r *recorder) normalize(mock *interact.ImposterMockCase) *interact.ImposterMockCase {
+	out := *mock
+	out.Request.Header = r.filterHeader(mock.Request.Header)
+	out.Request.Body = r.normalizeBody(mock.Request.Body)
+	out.Response.Header = r.filterHeader(mock.Response.Header)
+	out.Response.Body = r.normalizeBody(mock.Response.Body)
+	if len(out.Response.Trailer) == 0 {
+		out.Response.Trailer = nil
+	}
+	out.Response.Delay = nil
+	return &out
+}
+
+func (r *recorder) filterHeader(header map[string][]string) map[string][]string {
+	out := make(map[string][]string)
+	for k, v := range header {
+		for _, allowed := range r.cfg.AllowHeaders {
+			if strings.EqualFold(k, allowed) {
+				out[k] = v
+				break
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func (r *recorder) normalizeBody(body interface{}) interface{} {
+	var s string
+	switch b := body.(type) {
+	case string:
+		s = b
+	case []byte:
+		s = string(b)
+	default:
+		return body
+	}
+
+	if s == "" {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	for _, field := range r.cfg.IgnoreFields {
+		removeField(v, strings.Split(field, "."))
+	}
+	return v
+}
+
+// removeField delete the field of the path, the path applies to every element of an array.
+func removeField(v interface{}, path []string) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(vv, path[0])
+			return
+		}
+		removeField(vv[path[0]], path[1:])
+	case []interface{}:
+		for _, item := range vv {
+			removeField(item, path)
+		}
+	}
+}
+
+// contains whether an existing mock matches the same request.
+func contains(mocks []*interact.ImposterMockCase, mock *interact.ImposterMockCase) bool {
+	key := requestKey(&mock.Request)
+	for _, m := range mocks {
+		if requestKey(&m.Request) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// requestKey identifies a request by its method, host, path, query and body.
+func requestKey(req *interact.Request) string {
+	// the JSON encoding sorts the map keys, so equivalent bodies have the same key.
+	body, _ := json.Marshal(req.Body)
+	return strings.Join([]string{
+		strings.ToUpper(req.Method),
+		req.Host,
+		req.Path,
+		url.Values(req.Query).Encode(),
+		string(body),
+	}, " ")
+}
+
+// writeJSON replace the file atomically so that the file watchers never read a partial file.
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create directory of [%s] error: [%v]", path, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("write file [%s] error: [%v]", tmp, err)
+	}
+	return os.Rename(tmp, path)
+}
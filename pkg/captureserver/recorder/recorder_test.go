@@ -0,0 +1,120 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package recorder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func captured(body string) *interact.ImposterMockCase {
+	return &interact.ImposterMockCase{
+		Request: interact.Request{
+			Method: "POST",
+			Host:   "example.org",
+			Path:   "/orders",
+			Header: map[string][]string{"Content-Type": {"application/json"}, "X-Middlebaby-Correlation-Id": {"abc"}},
+			Body:   body,
+		},
+		Response: interact.Response{
+			Status: 200,
+			Header: map[string][]string{"Content-Type": {"application/json"}, "Date": {"Mon, 01 Jan 2022"}},
+			Body:   `{"id":1,"createdAt":"2022-01-01"}`,
+			Delay:  &interact.ResponseDelay{},
+		},
+	}
+}
+
+func Test_recorder_Record_File(t *testing.T) {
+	cfg := NewConfig()
+	cfg.File = filepath.Join(t.TempDir(), "record.mock.json")
+	cfg.IgnoreFields = []string{"createdAt", "items.requestTime"}
+	r := New(logger.NewDefault("test"), cfg)
+
+	// the second request only differs in the key order and an ignored field.
+	for _, body := range []string{
+		`{"sku":"A1","items":[{"n":1,"requestTime":1}]}`,
+		`{"items":[{"requestTime":2,"n":1}],"sku":"A1"}`,
+		`{"sku":"B2"}`,
+	} {
+		if err := r.Record(captured(body)); err != nil {
+			t.Fatalf("recorder.Record() error = %v", err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(cfg.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mocks []*interact.ImposterMockCase
+	if err := json.Unmarshal(b, &mocks); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mocks) != 2 {
+		t.Fatalf("recorder.Record() recorded %d mocks, want 2", len(mocks))
+	}
+	if want := map[string][]string{"Content-Type": {"application/json"}}; !reflect.DeepEqual(mocks[0].Request.Header, want) {
+		t.Errorf("recorder.Record() request header = %v, want %v", mocks[0].Request.Header, want)
+	}
+	if want := map[string]interface{}{"id": float64(1)}; !reflect.DeepEqual(mocks[0].Response.Body, want) {
+		t.Errorf("recorder.Record() response body = %v, want %v", mocks[0].Response.Body, want)
+	}
+}
+
+func Test_recorder_Record_Case(t *testing.T) {
+	cfg := NewConfig()
+	cfg.CaseFile = filepath.Join(t.TempDir(), "order.case.json")
+	cfg.CaseName = "create"
+	if err := ioutil.WriteFile(cfg.CaseFile, []byte(`{
+		// json5 comments are allowed in case files.
+		"serviceName": "order",
+		"cases": [{"name": "list"}, {"name": "create"}],
+	}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New(logger.NewDefault("test"), cfg)
+
+	if err := r.Record(captured(`{"sku":"A1"}`)); err != nil {
+		t.Fatalf("recorder.Record() error = %v", err)
+	}
+
+	b, err := ioutil.ReadFile(cfg.CaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var itf mbcase.ItfTask
+	if err := json.Unmarshal(b, &itf); err != nil {
+		t.Fatal(err)
+	}
+	if len(itf.Cases) != 2 || len(itf.Cases[0].Mocks) != 0 || len(itf.Cases[1].Mocks) != 1 {
+		t.Errorf("recorder.Record() case file = %s", b)
+	}
+
+	cfg.CaseName = "unknown"
+	if err := r.Record(captured(`{"sku":"A1"}`)); err == nil {
+		t.Errorf("recorder.Record() expected an error for an unknown case")
+	}
+}
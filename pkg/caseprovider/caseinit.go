@@ -22,7 +22,6 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"strings"
 
 	"github.com/alsritter/middlebaby/pkg/types/interact"
 	"github.com/alsritter/middlebaby/pkg/types/mbcase"
@@ -82,7 +81,7 @@ func (b *basicProvider) loadFilePaths() error {
 			if _, ok := exists[absFilePath]; !ok {
 				exists[absFilePath] = struct{}{}
 				// check file suffix
-				if strings.HasSuffix(absFilePath, b.cfg.TaskFileSuffix) {
+				if b.cfg.IsTaskFile(absFilePath) {
 					b.taskFiles = append(b.taskFiles, absFilePath)
 				}
 			}
@@ -112,26 +111,49 @@ func (b *basicProvider) loadCaseFiles() error {
 	defer b.mux.Unlock()
 
 	for _, file := range b.taskFiles {
-		t, err := b.caseloader.LoadItf(file)
+		ts, err := b.caseloader.LoadItf(file)
 		if err != nil {
 			b.Error(nil, "[%s] loading failed, err: [%v]", file, err)
 			continue
 		}
-		if err := b.checkItfInfo(t.TaskInfo); err != nil {
+
+		n, err := b.addItfs(file, ts)
+		if err != nil {
 			return err
 		}
+		total += n
+	}
+
+	b.Info(nil, "loading all case, total: %d", total)
+	return nil
+}
+
+// addItfs add the interfaces of the file, returns the count of the cases.
+func (b *basicProvider) addItfs(file string, ts []*mbcase.ItfTask) (int, error) {
+	var total int
+	for _, t := range ts {
+		if err := b.checkItfInfo(t.TaskInfo); err != nil {
+			return 0, err
+		}
 
 		// check case name
 		for _, e := range t.Cases {
 			if err := b.checkCaseInfo(e, *t.TaskInfo); err != nil {
-				return err
+				return 0, err
+			}
+			if err := checkMocks(e.Mocks); err != nil {
+				return 0, fmt.Errorf("case [%s]: %v", e.Name, err)
 			}
 			b.mockCases[e.Name] = append(b.mockCases[e.Name], e.Mocks...)
 		}
 
+		if err := checkMocks(t.Mocks); err != nil {
+			return 0, fmt.Errorf("interface [%s]: %v", t.ServiceName, err)
+		}
+
 		total += len(t.Cases)
 		if _, ok := b.taskInterface[t.ServiceName]; ok {
-			return fmt.Errorf("the serviceName is exists in multiple files, duplicated service name: [%s]", t.ServiceName)
+			return 0, fmt.Errorf("the serviceName is exists in multiple files, duplicated service name: [%s]", t.ServiceName)
 		} else {
 			b.taskInterface[t.ServiceName] = t
 		}
@@ -148,9 +170,7 @@ func (b *basicProvider) loadCaseFiles() error {
 		// add interface mocks case
 		b.mockCases[t.ServiceName] = append(b.mockCases[t.ServiceName], t.Mocks...)
 	}
-
-	b.Info(nil, "loading all case, total: %d", total)
-	return nil
+	return total, nil
 }
 
 // Check whether the file is correct.
@@ -178,6 +198,19 @@ func (b *basicProvider) checkCaseInfo(e *mbcase.CaseTask, info mbcase.TaskInfo)
 	return nil
 }
 
+// checkMocks check the request matchers of the mocks, so that an invalid one is reported when it is loaded.
+func checkMocks(mocks []*interact.ImposterMockCase) error {
+	for _, m := range mocks {
+		if m.Request.Matchers == nil {
+			continue
+		}
+		if err := m.Request.Matchers.Validate(); err != nil {
+			return fmt.Errorf("mock [%s %s] %v", m.Request.Method, m.Request.Path, err)
+		}
+	}
+	return nil
+}
+
 // Listen for changes to the task server file
 func (b *basicProvider) watchCaseFiles() error {
 	var paths []string
@@ -193,7 +226,7 @@ func (b *basicProvider) watchCaseFiles() error {
 		b.Trace(nil, "listening file event is triggered: %v", event)
 		// If it is a file creation event, It is added to the listener
 		if event.Op == watcher.Create {
-			if strings.HasSuffix(event.Name(), b.cfg.TaskFileSuffix) {
+			if b.cfg.IsTaskFile(event.Name()) {
 				fi, err := os.Stat(event.Name())
 				// if you created a directory.
 				if err == nil && fi.IsDir() {
@@ -260,8 +293,12 @@ func (b *basicProvider) loadSingleImposter(filePath string) {
 	defer b.mux.Unlock()
 
 	imposter, err := b.caseloader.LoadGlobalMockCase(filePath)
+	if err == nil {
+		err = checkMocks(imposter)
+	}
 	if err != nil {
 		b.Error(nil, "[%s]: load failed, err: [%v]", filePath, err)
+		return
 	}
 
 	b.mockCases[globalCaseID] = append(b.mockCases[globalCaseID], imposter...)
@@ -19,6 +19,7 @@ package caseprovider
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/alsritter/middlebaby/pkg/types/interact"
@@ -63,6 +64,15 @@ func (c *Config) Validate() error {
 // RegisterFlagsWithPrefix is used to register flags
 func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {}
 
+// IsTaskFile whether the file is a case file, the YAML variants of the suffix (e.g. ".case.yaml") are accepted too.
+func (c *Config) IsTaskFile(filePath string) bool {
+	if strings.HasSuffix(filePath, c.TaskFileSuffix) {
+		return true
+	}
+	base := strings.TrimSuffix(c.TaskFileSuffix, ".json")
+	return strings.HasSuffix(filePath, base+".yaml") || strings.HasSuffix(filePath, base+".yml")
+}
+
 type basicProvider struct {
 	cfg *Config
 	logger.Logger
@@ -129,6 +139,13 @@ func (b *basicProvider) GetItfInfoFromItfName(serviceName string) *mbcase.TaskIn
 	return nil
 }
 
+// GetItfFromItfName implements Provider
+func (b *basicProvider) GetItfFromItfName(serviceName string) *mbcase.ItfTask {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.taskInterface[serviceName]
+}
+
 // GetAllCaseFromCaseName implements Provider
 func (b *basicProvider) GetAllCaseFromCaseName(serviceName, caseName string) *mbcase.CaseTask {
 	b.mux.RLock()
@@ -7,15 +7,18 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 
 	"github.com/alsritter/middlebaby/pkg/types/interact"
 	"github.com/alsritter/middlebaby/pkg/types/mbcase"
 	"github.com/flynn/json5"
+	"gopkg.in/yaml.v3"
 )
 
 type CaseLoader interface {
 	LoadGlobalMockCase(filePath string) ([]*interact.ImposterMockCase, error)
-	LoadItf(filePath string) (*mbcase.ItfTask, error)
+	// LoadItf returns the interfaces of the file, a YAML file may hold one interface per document.
+	LoadItf(filePath string) ([]*mbcase.ItfTask, error)
 }
 
 type BasicLoader struct{}
@@ -38,6 +41,32 @@ func (l *BasicLoader) LoadGlobalMockCase(filePath string) ([]*interact.ImposterM
 	bytes, _ := ioutil.ReadAll(file)
 
 	var imposter []*interact.ImposterMockCase
+	if isYAMLFile(filePath) {
+		return imposter, decodeYAMLDocuments(filePath, bytes, func(node *yaml.Node) reflect.Type {
+			// a document is either a list of mocks or a single mock.
+			if node.Kind == yaml.SequenceNode {
+				return reflect.TypeOf(imposter)
+			}
+			return reflect.TypeOf(interact.ImposterMockCase{})
+		}, func(node *yaml.Node) error {
+			if node.Content[0].Kind == yaml.SequenceNode {
+				var mocks []*interact.ImposterMockCase
+				if err := node.Decode(&mocks); err != nil {
+					return err
+				}
+				imposter = append(imposter, mocks...)
+				return nil
+			}
+
+			mock := new(interact.ImposterMockCase)
+			if err := node.Decode(mock); err != nil {
+				return err
+			}
+			imposter = append(imposter, mock)
+			return nil
+		})
+	}
+
 	if err := json.Unmarshal(bytes, &imposter); err != nil {
 		return nil, fmt.Errorf("%v: error while unmarshal configFile file %s", err, filePath)
 	}
@@ -45,7 +74,7 @@ func (l *BasicLoader) LoadGlobalMockCase(filePath string) ([]*interact.ImposterM
 	return imposter, nil
 }
 
-func (l *BasicLoader) LoadItf(filePath string) (*mbcase.ItfTask, error) {
+func (l *BasicLoader) LoadItf(filePath string) ([]*mbcase.ItfTask, error) {
 	fb, err := ioutil.ReadFile(filePath)
 	if errors.Is(err, os.ErrNotExist) {
 		return nil, err
@@ -59,10 +88,36 @@ func (l *BasicLoader) LoadItf(filePath string) (*mbcase.ItfTask, error) {
 		return nil, fmt.Errorf("gets the taskserver file %s service type error: [%v]", filePath, err)
 	}
 
-	var t mbcase.ItfTask
-	if err := json5.Unmarshal(fb, &t); err != nil {
+	// the interfaces are decoded through their generic representation, so that the parameterized cases are
+	// expanded before they are decoded.
+	dir := filepath.Dir(filePath)
+	if isYAMLFile(filePath) {
+		var ts []*mbcase.ItfTask
+		err := decodeYAMLDocuments(filePath, fb, func(*yaml.Node) reflect.Type {
+			return reflect.TypeOf(mbcase.ItfTask{})
+		}, func(node *yaml.Node) error {
+			var v interface{}
+			if err := node.Decode(&v); err != nil {
+				return err
+			}
+			t, err := decodeItf(dir, v)
+			if err != nil {
+				return err
+			}
+			ts = append(ts, t)
+			return nil
+		})
+		return ts, err
+	}
+
+	var v interface{}
+	if err := json5.Unmarshal(fb, &v); err != nil {
+		return nil, fmt.Errorf("serialization %s file error: %v", filePath, err)
+	}
+	t, err := decodeItf(dir, v)
+	if err != nil {
 		return nil, fmt.Errorf("serialization %s file error: %v", filePath, err)
 	}
 
-	return &t, nil
+	return []*mbcase.ItfTask{t}, nil
 }
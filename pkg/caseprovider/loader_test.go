@@ -0,0 +1,172 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package caseprovider
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestBasicLoader_LoadItf_YAML(t *testing.T) {
+	p := writeFile(t, t.TempDir(), "user.case.yaml", `
+protocol: http
+serviceName: getUser
+serviceMethod: GET
+servicePath: /users/1
+x-login: &login
+  - typeName: http
+    commands: ["POST /login"]
+setup: *login
+cases:
+  - name: ok
+    setup: *login
+    request:
+      header: {X-Token: t1}
+    assert:
+      response: &okResponse
+        statusCode: 200
+        data: {id: 1}
+  - name: ok-again
+    assert:
+      response:
+        <<: *okResponse
+        header: {Content-Type: application/json}
+---
+protocol: grpc
+serviceName: hello
+servicePath: /examples.greeter.proto.Greeter/Hello
+serviceProtoFile: ./greeter.proto
+`)
+
+	var l BasicLoader
+	ts, err := l.LoadItf(p)
+	if err != nil {
+		t.Fatalf("LoadItf() error = %v", err)
+	}
+	if len(ts) != 2 {
+		t.Fatalf("LoadItf() got %d interfaces, want 2", len(ts))
+	}
+
+	user := ts[0]
+	if user.ServiceName != "getUser" || len(user.SetUp) != 1 || len(user.Cases) != 2 {
+		t.Fatalf("LoadItf() got unexpected interface %+v", user)
+	}
+	if got := user.Cases[0].SetUp[0].TypeName; got != "http" {
+		t.Errorf("anchor setup typeName = %s, want http", got)
+	}
+	if got := user.Cases[1].Assert.Response.StatusCode; got != 200 {
+		t.Errorf("merged statusCode = %d, want 200", got)
+	}
+	if got := user.Cases[1].Assert.Response.Header["Content-Type"]; got != "application/json" {
+		t.Errorf("merged header = %s, want application/json", got)
+	}
+	if got := ts[1].ServiceProtoFile; got != "./greeter.proto" {
+		t.Errorf("serviceProtoFile = %s, want ./greeter.proto", got)
+	}
+}
+
+func TestBasicLoader_LoadItf_YAMLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "unknown field",
+			content: `serviceName: getUser
+cases:
+  - name: ok
+    asert: {}
+`,
+			want: []string{"user.case.yaml:4:5", `unknown field "asert" in [cases[0]]`},
+		},
+		{
+			name: "unknown field in a merged anchor",
+			content: `x-ok: &ok
+  statusCodee: 200
+serviceName: getUser
+cases:
+  - name: ok
+    assert:
+      response:
+        <<: *ok
+`,
+			want: []string{"user.case.yaml:2:3", `unknown field "statusCodee" in [cases[0].assert.response]`},
+		},
+		{
+			name: "wrong kind",
+			content: `serviceName: getUser
+cases:
+  name: ok
+`,
+			want: []string{"user.case.yaml:3:3", "[cases] should be a sequence"},
+		},
+		{
+			name:    "syntax error",
+			content: "serviceName: [getUser\n",
+			want:    []string{"user.case.yaml", "line"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l BasicLoader
+			_, err := l.LoadItf(writeFile(t, t.TempDir(), "user.case.yaml", tt.content))
+			if err == nil {
+				t.Fatal("LoadItf() expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadItf() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBasicLoader_LoadGlobalMockCase_YAML(t *testing.T) {
+	p := writeFile(t, t.TempDir(), "global.mock.yaml", `
+- request: {method: GET, host: api.example.com, path: /users/1}
+  response: {status: 200, body: {id: 1}}
+---
+request: {method: GET, host: api.example.com, path: /users/2}
+response: {status: 404}
+`)
+
+	var l BasicLoader
+	mocks, err := l.LoadGlobalMockCase(p)
+	if err != nil {
+		t.Fatalf("LoadGlobalMockCase() error = %v", err)
+	}
+	if len(mocks) != 2 {
+		t.Fatalf("LoadGlobalMockCase() got %d mocks, want 2", len(mocks))
+	}
+	if mocks[0].Response.Status != 200 || mocks[1].Request.Path != "/users/2" {
+		t.Errorf("LoadGlobalMockCase() got unexpected mocks %+v %+v", mocks[0], mocks[1])
+	}
+}
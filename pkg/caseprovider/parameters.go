@@ -0,0 +1,268 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package caseprovider
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"gopkg.in/yaml.v3"
+)
+
+// paramPattern the placeholder of a parameter, e.g. "${userId}".
+var paramPattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// IsPlaceholder whether the text is only a parameter placeholder, which is replaced by the value itself.
+func IsPlaceholder(s string) bool {
+	m := paramPattern.FindString(s)
+	return m != "" && m == s
+}
+
+// ExpandCase decode the case (a generic JSON value), returns the cases of its parameter rows or the case itself
+// if it has no parameters. dir is the directory of the case file.
+// The case is expanded before it is decoded, so that the placeholders can fill the non-string fields too.
+func ExpandCase(dir string, raw interface{}) ([]*mbcase.CaseTask, error) {
+	m, ok := raw.(map[string]interface{})
+	if !ok || m["parameters"] == nil {
+		var c mbcase.CaseTask
+		if err := convert(raw, &c); err != nil {
+			return nil, err
+		}
+		return []*mbcase.CaseTask{&c}, nil
+	}
+
+	var params mbcase.CaseParameters
+	if err := convert(m["parameters"], &params); err != nil {
+		return nil, fmt.Errorf("case [%v] parameters: %v", m["name"], err)
+	}
+	name := fmt.Sprint(m["name"])
+	rows, err := parameterRows(dir, &params)
+	if err != nil {
+		return nil, fmt.Errorf("case [%s]: %v", name, err)
+	}
+
+	tpl := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != "parameters" {
+			tpl[k] = v
+		}
+	}
+
+	cases := make([]*mbcase.CaseTask, 0, len(rows))
+	for i, row := range rows {
+		// the YAML decoding converts the scalars, e.g. a number of a row can fill a string field.
+		b, err := yaml.Marshal(substituteParams(tpl, row))
+		if err != nil {
+			return nil, err
+		}
+		var expanded mbcase.CaseTask
+		if err := yaml.Unmarshal(b, &expanded); err != nil {
+			return nil, fmt.Errorf("case [%s] row %d: %v", name, i+1, err)
+		}
+
+		suffix := "#" + strconv.Itoa(i+1)
+		if params.Name != "" {
+			v, ok := row[params.Name]
+			if !ok {
+				return nil, fmt.Errorf("case [%s] row %d: parameter [%s] not found", name, i+1, params.Name)
+			}
+			suffix = paramString(v)
+		}
+		expanded.Name = fmt.Sprintf("%s [%s]", name, suffix)
+		cases = append(cases, &expanded)
+	}
+	return cases, nil
+}
+
+// decodeItf decode the interface (a generic JSON value) and expand its cases.
+func decodeItf(dir string, raw interface{}) (*mbcase.ItfTask, error) {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("an interface should be an object")
+	}
+
+	info := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != "cases" {
+			info[k] = v
+		}
+	}
+	var t mbcase.ItfTask
+	if err := convert(info, &t); err != nil {
+		return nil, err
+	}
+	if t.TaskInfo == nil {
+		t.TaskInfo = new(mbcase.TaskInfo)
+	}
+
+	rawCases, _ := m["cases"].([]interface{})
+	for _, c := range rawCases {
+		cases, err := ExpandCase(dir, c)
+		if err != nil {
+			return nil, err
+		}
+		t.Cases = append(t.Cases, cases...)
+	}
+	return &t, nil
+}
+
+// convert decode the generic JSON value into out.
+func convert(v interface{}, out interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func parameterRows(dir string, p *mbcase.CaseParameters) ([]map[string]interface{}, error) {
+	if p.File == "" {
+		return p.Rows, nil
+	}
+	if len(p.Rows) > 0 {
+		return nil, fmt.Errorf("the parameter rows and file cannot be set at the same time")
+	}
+
+	file := p.File
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+
+	if strings.HasSuffix(file, ".csv") {
+		return csvRows(file)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read parameter file error: %v", err)
+	}
+	var rows []map[string]interface{}
+	if isYAMLFile(file) {
+		err = yaml.Unmarshal(b, &rows)
+	} else {
+		err = json.Unmarshal(b, &rows)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parameter file %s should be a list of objects: %v", file, err)
+	}
+	return rows, nil
+}
+
+// csvRows read the rows of the CSV file, the numbers and the booleans are converted.
+func csvRows(file string) ([]map[string]interface{}, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("read parameter file error: %v", err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse parameter file %s error: %v", file, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = csvValue(record[i])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func csvValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	// only the canonical numbers, e.g. "007" stays a string.
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == s {
+		return f
+	}
+	return s
+}
+
+// substituteParams replace the placeholders of the row parameters in every string (including map keys),
+// a string that is only a placeholder is replaced by the value itself. The unknown placeholders are kept.
+func substituteParams(v interface{}, row map[string]interface{}) interface{} {
+	switch vv := v.(type) {
+	case string:
+		if IsPlaceholder(vv) {
+			if p, ok := row[vv[2:len(vv)-1]]; ok {
+				return p
+			}
+		}
+		return substituteString(vv, row)
+	case []interface{}:
+		out := make([]interface{}, len(vv))
+		for i, item := range vv {
+			out[i] = substituteParams(item, row)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(vv))
+		for k, item := range vv {
+			out[substituteString(k, row)] = substituteParams(item, row)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// substituteString replace the placeholders by the text of the values.
+func substituteString(s string, row map[string]interface{}) string {
+	return paramPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		if p, ok := row[placeholder[2:len(placeholder)-1]]; ok {
+			return paramString(p)
+		}
+		return placeholder
+	})
+}
+
+func paramString(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case float64:
+		return strconv.FormatFloat(vv, 'f', -1, 64)
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(vv)
+		return string(b)
+	default:
+		return fmt.Sprint(vv)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package caseprovider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+)
+
+func TestExpandCase(t *testing.T) {
+	tpl := func(p *mbcase.CaseParameters) *mbcase.CaseTask {
+		return &mbcase.CaseTask{
+			Name:  "get user",
+			SetUp: []*mbcase.Command{{TypeName: "mysql", Commands: []string{"INSERT INTO users VALUES (${id}, '${name}')"}}},
+			Mocks: []*interact.ImposterMockCase{{
+				Request:  interact.Request{Path: "/profiles/${id}"},
+				Response: interact.Response{Status: 200, Body: map[string]interface{}{"nickname": "${name}"}},
+			}},
+			Request: &mbcase.CaseRequest{
+				Query:  map[string][]string{"id": {"${id}"}},
+				Header: map[string]string{"X-Token": "{{ .vars.token }}"},
+			},
+			Assert: &mbcase.Assert{Response: mbcase.Response{StatusCode: 200, Data: map[string]interface{}{
+				"id": "${id}", "name": "${name}", "home": "${HOME}",
+			}}},
+			Parameters: p,
+		}
+	}
+
+	dir := t.TempDir()
+	csvFile := writeFile(t, dir, "users.csv", "id,name\n1,alice\n007,bob\n")
+
+	tests := []struct {
+		name      string
+		params    *mbcase.CaseParameters
+		wantNames []string
+		wantIDs   []interface{} // the asserted id of each case.
+		wantErr   bool
+	}{
+		{
+			name:      "inline rows",
+			params:    &mbcase.CaseParameters{Rows: []map[string]interface{}{{"id": float64(1), "name": "alice"}, {"id": float64(2), "name": "bob"}}},
+			wantNames: []string{"get user [#1]", "get user [#2]"},
+			wantIDs:   []interface{}{1, 2},
+		},
+		{
+			name:      "csv file named by a parameter",
+			params:    &mbcase.CaseParameters{File: csvFile, Name: "name"},
+			wantNames: []string{"get user [alice]", "get user [bob]"},
+			wantIDs:   []interface{}{1, "007"},
+		},
+		{
+			name:    "unknown name parameter",
+			params:  &mbcase.CaseParameters{Rows: []map[string]interface{}{{"id": float64(1)}}, Name: "name"},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			params:  &mbcase.CaseParameters{File: "missing.json"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw interface{}
+			if err := convert(tpl(tt.params), &raw); err != nil {
+				t.Fatal(err)
+			}
+			cases, err := ExpandCase(dir, raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandCase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var names []string
+			for i, c := range cases {
+				names = append(names, c.Name)
+				data := c.Assert.Response.Data.(map[string]interface{})
+				if !reflect.DeepEqual(data["id"], tt.wantIDs[i]) {
+					t.Errorf("case %d asserted id = %#v, want %#v", i, data["id"], tt.wantIDs[i])
+				}
+				if data["home"] != "${HOME}" || c.Request.Header["X-Token"] != "{{ .vars.token }}" {
+					t.Errorf("case %d unknown placeholders should be kept, got %v %v", i, data["home"], c.Request.Header)
+				}
+				if c.Parameters != nil {
+					t.Errorf("case %d parameters should be removed", i)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("ExpandCase() names = %v, want %v", names, tt.wantNames)
+			}
+
+			first := cases[0]
+			if got := first.SetUp[0].Commands[0]; got != "INSERT INTO users VALUES (1, 'alice')" {
+				t.Errorf("setup command = %s", got)
+			}
+			if got := first.Mocks[0].Request.Path; got != "/profiles/1" {
+				t.Errorf("mock path = %s", got)
+			}
+			if got := first.Request.Query["id"]; !reflect.DeepEqual(got, []string{"1"}) {
+				t.Errorf("request query = %v", got)
+			}
+		})
+	}
+}
+
+func TestBasicLoader_LoadItf_Parameters(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, "user.case.json", `{
+  "protocol": "http",
+  "serviceName": "getUser",
+  "servicePath": "http://127.0.0.1:8011/users",
+  "cases": [
+    {"name": "plain"},
+    {
+      // the placeholders fill the numeric fields too.
+      "name": "get user",
+      "parameters": {"file": "users.json", "name": "user"},
+      "request": {"query": {"id": ["${id}"]}},
+      "assert": {"response": {"statusCode": "${status}"}}
+    }
+  ]
+}`)
+	writeFile(t, dir, "users.json", `[{"user": "alice", "id": 1, "status": 200}, {"user": "nobody", "id": 2, "status": 404}]`)
+
+	var l BasicLoader
+	ts, err := l.LoadItf(p)
+	if err != nil {
+		t.Fatalf("LoadItf() error = %v", err)
+	}
+
+	var names []string
+	var statuses []int
+	for _, c := range ts[0].Cases {
+		names = append(names, c.Name)
+		if c.Assert != nil {
+			statuses = append(statuses, c.Assert.Response.StatusCode)
+		}
+	}
+	if want := []string{"plain", "get user [alice]", "get user [nobody]"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("LoadItf() case names = %v, want %v", names, want)
+	}
+	if want := []int{200, 404}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("LoadItf() statuses = %v, want %v", statuses, want)
+	}
+}
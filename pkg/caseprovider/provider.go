@@ -28,6 +28,8 @@ type Provider interface {
 	GetAllCaseFromCaseName(serviceName, caseName string) *mbcase.CaseTask
 
 	GetItfInfoFromItfName(serviceName string) *mbcase.TaskInfo
+	// GetItfFromItfName Get the interface of the serviceName.
+	GetItfFromItfName(serviceName string) *mbcase.ItfTask
 	// GetAllItfInfo Get all interface info.
 	GetAllItfInfo() []*mbcase.TaskInfo
 	// GetAllItf Get all interface.
@@ -0,0 +1,219 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package caseprovider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+)
+
+// isYAMLFile whether the file is decoded as YAML, the other files are decoded as JSON (JSON5 for case files).
+func isYAMLFile(filePath string) bool {
+	return strings.HasSuffix(filePath, ".yaml") || strings.HasSuffix(filePath, ".yml")
+}
+
+// decodeYAMLDocuments validate every document of the YAML file against the yaml tags of the type returned by
+// typeOf, so that misspelled fields are reported with their position instead of being silently ignored,
+// then decode the document with decode.
+func decodeYAMLDocuments(filePath string, data []byte, typeOf func(node *yaml.Node) reflect.Type,
+	decode func(node *yaml.Node) error) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("%s: %v", filePath, err)
+		}
+
+		// an empty document (e.g. a trailing "---").
+		if len(node.Content) == 0 {
+			continue
+		}
+
+		if err := validateYAML(filePath, &node, typeOf(node.Content[0])); err != nil {
+			return err
+		}
+		if err := decode(&node); err != nil {
+			return fmt.Errorf("%s:%d:%d: %v", filePath, node.Content[0].Line, node.Content[0].Column, err)
+		}
+	}
+}
+
+// validateYAML check the keys and the node kinds of the document against the type.
+func validateYAML(filePath string, node *yaml.Node, t reflect.Type) error {
+	var result error
+	v := yamlValidator{file: filePath, errs: func(err error) { result = multierror.Append(result, err) }}
+	v.validate(node, t, "")
+	return result
+}
+
+type yamlValidator struct {
+	file string
+	errs func(error)
+}
+
+func (v *yamlValidator) errorf(node *yaml.Node, format string, args ...interface{}) {
+	v.errs(fmt.Errorf("%s:%d:%d: %s", v.file, node.Line, node.Column, fmt.Sprintf(format, args...)))
+}
+
+func (v *yamlValidator) validate(node *yaml.Node, t reflect.Type, path string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			v.validate(n, t, path)
+		}
+		return
+	case yaml.AliasNode:
+		v.validate(node.Alias, t, path)
+		return
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "%s should be a mapping", pathName(path))
+			return
+		}
+		fields := yamlFields(t)
+		v.mapping(node, func(key, value *yaml.Node) {
+			// the "x-" keys hold the shared blocks referenced by anchors, e.g. "x-login: &login".
+			if strings.HasPrefix(key.Value, "x-") {
+				return
+			}
+			ft, ok := fields[key.Value]
+			if !ok {
+				v.errorf(key, "unknown field %q in %s", key.Value, pathName(path))
+				return
+			}
+			v.validate(value, ft, joinPath(path, key.Value))
+		})
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "%s should be a mapping", pathName(path))
+			return
+		}
+		v.mapping(node, func(key, value *yaml.Node) {
+			v.validate(value, t.Elem(), joinPath(path, key.Value))
+		})
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			v.errorf(node, "%s should be a sequence", pathName(path))
+			return
+		}
+		for i, n := range node.Content {
+			v.validate(n, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			v.errorf(node, "%s should be a %s", pathName(path), t.Kind())
+		}
+	}
+}
+
+// mapping call fn with each key and value of the mapping, the merge keys (<<: *anchor) are expanded.
+func (v *yamlValidator) mapping(node *yaml.Node, fn func(key, value *yaml.Node)) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Tag != "!!merge" {
+			fn(key, value)
+			continue
+		}
+
+		merged := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			merged = value.Content
+		}
+		for _, m := range merged {
+			for m.Kind == yaml.AliasNode {
+				m = m.Alias
+			}
+			if m.Kind == yaml.MappingNode {
+				v.mapping(m, fn)
+			}
+		}
+	}
+}
+
+// yamlFields returns the type of the fields by their yaml name, the inline fields are flattened.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx:]
+		}
+
+		if strings.Contains(opts, "inline") {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			for k, v := range yamlFields(ft) {
+				fields[k] = v
+			}
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func pathName(path string) string {
+	if path == "" {
+		return "the document"
+	}
+	return "[" + path + "]"
+}
@@ -0,0 +1,270 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package casevalidator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/flynn/json5"
+	"gopkg.in/yaml.v3"
+)
+
+// document a decoded document of a file, the node locates the values of the document.
+type document struct {
+	value interface{}
+	node  *yaml.Node // nil if the positions of the file are unknown.
+}
+
+// parseDocuments decode the documents of the file, a JSON file has a single document.
+// The JSON files are parsed as YAML too (JSON is a subset of YAML) to know the positions of their values.
+func parseDocuments(filePath string, data []byte, isJSON5 bool) ([]*document, *Diagnostic) {
+	if !isYAMLFile(filePath) {
+		var v interface{}
+		var err error
+		if isJSON5 {
+			err = json5.Unmarshal(data, &v)
+		} else {
+			err = json.Unmarshal(data, &v)
+		}
+		if err != nil {
+			return nil, syntaxDiagnostic(filePath, data, err)
+		}
+
+		doc := &document{value: v}
+		var node yaml.Node
+		if err := yaml.Unmarshal(stripComments(data), &node); err == nil {
+			doc.node = &node
+		}
+		return []*document{doc}, nil
+	}
+
+	var docs []*document
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, &Diagnostic{File: filePath, Message: err.Error()}
+		}
+		// the empty documents are skipped by the loader too.
+		if len(node.Content) == 0 {
+			continue
+		}
+
+		var v interface{}
+		if err := node.Decode(&v); err != nil {
+			return nil, &Diagnostic{File: filePath, Line: node.Line, Column: node.Column, Message: err.Error()}
+		}
+		// the schema validator expects the JSON types. (e.g. float64 instead of int)
+		b, err := json.Marshal(v)
+		if err == nil {
+			err = json.Unmarshal(b, &v)
+		}
+		if err != nil {
+			return nil, &Diagnostic{File: filePath, Line: node.Line, Column: node.Column, Message: err.Error()}
+		}
+		docs = append(docs, &document{value: v, node: &node})
+	}
+}
+
+func syntaxDiagnostic(filePath string, data []byte, err error) *Diagnostic {
+	var offset int64 = -1
+	var jsonErr *json.SyntaxError
+	var json5Err *json5.SyntaxError
+	if errors.As(err, &jsonErr) {
+		offset = jsonErr.Offset
+	} else if errors.As(err, &json5Err) {
+		offset = json5Err.Offset
+	}
+
+	d := &Diagnostic{File: filePath, Message: err.Error()}
+	if offset >= 0 {
+		d.Line, d.Column = 1, 1
+		for _, c := range data[:min(int(offset), len(data))] {
+			if c == '\n' {
+				d.Line++
+				d.Column = 1
+			} else {
+				d.Column++
+			}
+		}
+	}
+	return d
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// stripComments replace the JSON5 comments by spaces, so that the positions are kept.
+func stripComments(data []byte) []byte {
+	out := append([]byte(nil), data...)
+	var quote byte
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			for ; i < len(out) && !(out[i] == '*' && i+1 < len(out) && out[i+1] == '/'); i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			if i+1 < len(out) {
+				out[i], out[i+1] = ' ', ' '
+				i++
+			}
+		}
+	}
+	return out
+}
+
+// locate returns the node of the JSON pointer (e.g. "/cases/0/name"), key whether to return the node of
+// the key of the last token instead of its value. It returns the deepest node found.
+func (d *document) locate(pointer string, key bool) *yaml.Node {
+	if d.node == nil {
+		return nil
+	}
+
+	n := resolve(d.node)
+	if pointer == "" {
+		return n
+	}
+
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		last := i == len(tokens)-1
+
+		switch n.Kind {
+		case yaml.MappingNode:
+			k, v := mappingValue(n, token)
+			if k == nil {
+				return n
+			}
+			if last && key {
+				return k
+			}
+			n = resolve(v)
+		case yaml.SequenceNode:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(n.Content) {
+				return n
+			}
+			n = resolve(n.Content[idx])
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// resolve follow the documents and the aliases.
+func resolve(n *yaml.Node) *yaml.Node {
+	for {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			if len(n.Content) == 0 {
+				return n
+			}
+			n = n.Content[0]
+		case yaml.AliasNode:
+			n = n.Alias
+		default:
+			return n
+		}
+	}
+}
+
+// mappingValue returns the key and the value of the mapping, the merged mappings (<<: *anchor) are searched too.
+func mappingValue(n *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	var merges []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.Tag == "!!merge" {
+			merges = append(merges, v)
+			continue
+		}
+		if k.Value == key {
+			return k, v
+		}
+	}
+
+	for _, m := range merges {
+		m = resolve(m)
+		candidates := []*yaml.Node{m}
+		if m.Kind == yaml.SequenceNode {
+			candidates = m.Content
+		}
+		for _, c := range candidates {
+			if c = resolve(c); c.Kind == yaml.MappingNode {
+				if k, v := mappingValue(c, key); k != nil {
+					return k, v
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func isYAMLFile(filePath string) bool {
+	return strings.HasSuffix(filePath, ".yaml") || strings.HasSuffix(filePath, ".yml")
+}
+
+// Diagnostic a problem of a file.
+type Diagnostic struct {
+	File   string
+	Line   int // 0 if unknown.
+	Column int
+	// Pointer the JSON pointer of the value. (e.g. /cases/0/name)
+	Pointer string
+	Message string
+}
+
+func (d *Diagnostic) String() string {
+	msg := d.Message
+	if d.Pointer != "" {
+		msg = fmt.Sprintf("[%s] %s", d.Pointer, msg)
+	}
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.File, msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, msg)
+}
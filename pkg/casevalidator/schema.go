@@ -0,0 +1,183 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package casevalidator lints the case files and the mock files without running them.
+package casevalidator
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+)
+
+const (
+	// CaseSchemaID and MockSchemaID the ids of the published schemas (schema/*.schema.json).
+	CaseSchemaID = "https://github.com/alsritter/middlebaby/schema/case.schema.json"
+	MockSchemaID = "https://github.com/alsritter/middlebaby/schema/mock.schema.json"
+)
+
+// the enums and the required properties the Go types cannot express, key: the type name.
+var (
+	schemaEnums = map[string][]interface{}{
+		"mbcase.Protocol": {mbcase.ProtocolHTTP, mbcase.ProtocolGRPC},
+		"interact.FaultType": {
+			interact.FaultError, interact.FaultConnectionReset, interact.FaultEmptyReply, interact.FaultTruncatedBody,
+			interact.FaultThrottle, interact.FaultStreamReset, interact.FaultDeadlineExceeded,
+		},
+	}
+
+	schemaRequired = map[string][]string{
+		"mbcase.TaskInfo":           {"protocol", "serviceName", "servicePath"},
+		"mbcase.CaseTask":           {"name"},
+		"mbcase.Command":            {"typeName"},
+		"mbcase.CommonAssert":       {"typeName"},
+		"interact.ImposterMockCase": {"request"},
+		"interact.ResponseFault":    {"type"},
+	}
+)
+
+// CaseSchema returns the JSON Schema of a case file (an interface).
+func CaseSchema() []byte {
+	g := newSchemaGenerator()
+	root := g.schema(reflect.TypeOf(mbcase.ItfTask{}))
+	return g.document(CaseSchemaID, "middlebaby case file", root)
+}
+
+// MockSchema returns the JSON Schema of a mock file, a list of mocks or a single mock (YAML documents only).
+func MockSchema() []byte {
+	g := newSchemaGenerator()
+	mock := g.schema(reflect.TypeOf(interact.ImposterMockCase{}))
+	root := map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "array", "items": mock},
+			mock,
+		},
+	}
+	return g.document(MockSchemaID, "middlebaby mock file", root)
+}
+
+// schemaGenerator builds a JSON Schema from the json tags of the types,
+// the named struct types are written into the definitions.
+type schemaGenerator struct {
+	definitions map[string]interface{}
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{definitions: make(map[string]interface{})}
+}
+
+func (g *schemaGenerator) document(id, title string, root map[string]interface{}) []byte {
+	doc := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         id,
+		"title":       title,
+		"definitions": g.definitions,
+	}
+	for k, v := range root {
+		doc[k] = v
+	}
+	// the map keys are sorted by the encoder, so the output is stable.
+	b, _ := json.MarshalIndent(doc, "", "  ")
+	return append(b, '\n')
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if enum, ok := schemaEnums[t.String()]; ok {
+		return map[string]interface{}{"enum": enum}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		name := t.String()
+		ref := map[string]interface{}{"$ref": "#/definitions/" + name}
+		if _, ok := g.definitions[name]; ok {
+			return ref
+		}
+		// registered before the fields, so that the recursive types terminate.
+		g.definitions[name] = nil
+		properties, required := g.fields(t)
+		def := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			def["required"] = required
+		}
+		g.definitions[name] = def
+		return ref
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		// interface{}: any value.
+		return map[string]interface{}{}
+	}
+}
+
+// fields returns the properties of the struct, the embedded structs without a json name are flattened
+// the same way encoding/json does.
+func (g *schemaGenerator) fields(t reflect.Type) (map[string]interface{}, []string) {
+	properties := make(map[string]interface{})
+	required := append([]string(nil), schemaRequired[t.String()]...)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			p, r := g.fields(ft)
+			for k, v := range p {
+				properties[k] = v
+			}
+			required = append(required, r...)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.schema(f.Type)
+	}
+	sort.Strings(required)
+	return properties, required
+}
@@ -0,0 +1,573 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package casevalidator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alsritter/middlebaby/pkg/caseprovider"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry"
+	"github.com/alsritter/middlebaby/pkg/protomanager"
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/common"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/alsritter/middlebaby/pkg/util/tagexpr"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Provider lints the configured case files and mock files.
+type Provider interface {
+	// Validate returns the problems of the files, empty if all files are valid.
+	Validate() []*Diagnostic
+}
+
+type validator struct {
+	logger.Logger
+	cfg           *caseprovider.Config
+	protoProvider protomanager.Provider
+	registry      pluginregistry.Registry
+
+	caseSchema     *jsonschema.Schema
+	mockSchema     *jsonschema.Schema
+	mockListSchema *jsonschema.Schema
+}
+
+// New returns a validator of the files of the case provider config, the proto methods and the plugins
+// are resolved against the providers.
+func New(log logger.Logger, cfg *caseprovider.Config, protoProvider protomanager.Provider,
+	registry pluginregistry.Registry) (Provider, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(CaseSchemaID, bytes.NewReader(CaseSchema())); err != nil {
+		return nil, err
+	}
+	if err := compiler.AddResource(MockSchemaID, bytes.NewReader(MockSchema())); err != nil {
+		return nil, err
+	}
+
+	v := &validator{
+		Logger:        log.NewLogger("validator"),
+		cfg:           cfg,
+		protoProvider: protoProvider,
+		registry:      registry,
+	}
+	var err error
+	if v.caseSchema, err = compiler.Compile(CaseSchemaID); err != nil {
+		return nil, err
+	}
+	if v.mockSchema, err = compiler.Compile(MockSchemaID + "#/definitions/interact.ImposterMockCase"); err != nil {
+		return nil, err
+	}
+	if v.mockListSchema, err = compiler.Compile(MockSchemaID + "#/anyOf/0"); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// fileContext collects the diagnostics of a file.
+type fileContext struct {
+	path  string
+	docs  []*document
+	diags []*Diagnostic
+}
+
+// report add a diagnostic at the value of the pointer in the document,
+// key whether to point at the key of the value instead.
+func (f *fileContext) report(doc int, pointer string, key bool, format string, args ...interface{}) {
+	d := &Diagnostic{File: f.path, Pointer: pointer, Message: fmt.Sprintf(format, args...)}
+	if doc < len(f.docs) {
+		if n := f.docs[doc].locate(pointer, key); n != nil {
+			d.Line, d.Column = n.Line, n.Column
+		}
+	}
+	f.diags = append(f.diags, d)
+}
+
+// Validate implements Provider
+func (v *validator) Validate() []*Diagnostic {
+	var diags []*Diagnostic
+	files, fileDiags := v.caseFiles()
+	diags = append(diags, fileDiags...)
+
+	services := make(map[string]string)
+	for _, file := range files {
+		v.Debug(nil, "validating case file [%s]", file)
+		diags = append(diags, v.validateCaseFile(file, services)...)
+	}
+
+	for _, file := range v.cfg.MockFiles {
+		v.Debug(nil, "validating mock file [%s]", file)
+		diags = append(diags, v.validateMockFile(file)...)
+	}
+	return diags
+}
+
+// caseFiles returns the case files matching the CaseFiles patterns, the same way the case provider finds them.
+func (v *validator) caseFiles() ([]string, []*Diagnostic) {
+	var (
+		files  []string
+		diags  []*Diagnostic
+		exists = make(map[string]struct{})
+	)
+	for _, pattern := range v.cfg.CaseFiles {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			diags = append(diags, &Diagnostic{File: pattern, Message: err.Error()})
+			continue
+		}
+		if len(matches) == 0 {
+			diags = append(diags, &Diagnostic{File: pattern, Message: "no file matches the pattern"})
+			continue
+		}
+
+		for _, m := range matches {
+			if _, ok := exists[m]; ok || !v.cfg.IsTaskFile(m) {
+				continue
+			}
+			exists[m] = struct{}{}
+			files = append(files, m)
+		}
+	}
+	sort.Strings(files)
+	return files, diags
+}
+
+func (v *validator) validateCaseFile(path string, services map[string]string) []*Diagnostic {
+	f, ok := v.parseFile(path, true)
+	if !ok {
+		return f.diags
+	}
+
+	for i, doc := range f.docs {
+		if !v.validateSchema(f, i, v.caseSchema) {
+			continue
+		}
+
+		// the cases are decoded by the case provider, which expands their parameters.
+		raw, _ := doc.value.(map[string]interface{})
+		info := make(map[string]interface{}, len(raw))
+		for k, e := range raw {
+			if k != "cases" {
+				info[k] = e
+			}
+		}
+		var itf mbcase.ItfTask
+		if err := convert(info, &itf); err != nil {
+			f.report(i, "", false, "%v", err)
+			continue
+		}
+		cases, _ := raw["cases"].([]interface{})
+		v.checkItf(f, i, &itf, cases, services)
+	}
+	return f.diags
+}
+
+func (v *validator) validateMockFile(path string) []*Diagnostic {
+	f, ok := v.parseFile(path, false)
+	if !ok {
+		return f.diags
+	}
+
+	for i, doc := range f.docs {
+		var mocks []*interact.ImposterMockCase
+		if _, isList := doc.value.([]interface{}); isList {
+			if !v.validateSchema(f, i, v.mockListSchema) {
+				continue
+			}
+			if err := convert(doc.value, &mocks); err != nil {
+				f.report(i, "", false, "%v", err)
+				continue
+			}
+			for j, m := range mocks {
+				v.checkMock(f, i, fmt.Sprintf("/%d", j), m)
+			}
+			continue
+		}
+
+		if !isYAMLFile(path) {
+			f.report(i, "", false, "a JSON mock file should be a list of mocks")
+			continue
+		}
+		if !v.validateSchema(f, i, v.mockSchema) {
+			continue
+		}
+		var mock interact.ImposterMockCase
+		if err := convert(doc.value, &mock); err != nil {
+			f.report(i, "", false, "%v", err)
+			continue
+		}
+		v.checkMock(f, i, "", &mock)
+	}
+	return f.diags
+}
+
+// parseFile read and parse the documents of the file, returns false if the file cannot be parsed.
+func (v *validator) parseFile(path string, isJSON5 bool) (*fileContext, bool) {
+	f := &fileContext{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		f.diags = append(f.diags, &Diagnostic{File: path, Message: err.Error()})
+		return f, false
+	}
+
+	docs, d := parseDocuments(path, data, isJSON5)
+	if d != nil {
+		f.diags = append(f.diags, d)
+		return f, false
+	}
+	f.docs = docs
+	return f, true
+}
+
+// validateSchema validate the document against the schema, returns false if it is invalid.
+func (v *validator) validateSchema(f *fileContext, doc int, schema *jsonschema.Schema) bool {
+	// a null value is ignored by the decoder, the schema does not need to allow it.
+	err := schema.Validate(removeNulls(f.docs[doc].value))
+	if err == nil {
+		return true
+	}
+
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		f.report(doc, "", false, "%v", err)
+		return false
+	}
+	start := len(f.diags)
+	for _, leaf := range leaves(ve) {
+		// a placeholder of a parameterized case can fill any field, it is checked once the case is expanded.
+		if isPlaceholder(f.docs[doc].value, leaf.InstanceLocation) {
+			continue
+		}
+
+		pointer, key := leaf.InstanceLocation, false
+		// point at the unknown property instead of its object.
+		if strings.HasPrefix(leaf.Message, "additionalProperties") {
+			if parts := strings.Split(leaf.Message, "'"); len(parts) > 1 {
+				pointer, key = pointer+"/"+parts[1], true
+			}
+		}
+		f.report(doc, pointer, key, "%s", leaf.Message)
+	}
+
+	// the order of the schema errors is not stable, list them in the order of the file.
+	reported := f.diags[start:]
+	sort.SliceStable(reported, func(i, j int) bool {
+		if reported[i].Line != reported[j].Line {
+			return reported[i].Line < reported[j].Line
+		}
+		return reported[i].Column < reported[j].Column
+	})
+	return len(reported) == 0
+}
+
+func (v *validator) checkItf(f *fileContext, doc int, itf *mbcase.ItfTask, rawCases []interface{},
+	services map[string]string) {
+	if itf.TaskInfo == nil {
+		return
+	}
+
+	if file, ok := services[itf.ServiceName]; ok {
+		f.report(doc, "/serviceName", false, "the serviceName [%s] is already used by [%s]", itf.ServiceName, file)
+	} else {
+		services[itf.ServiceName] = f.path
+	}
+
+	switch itf.Protocol {
+	case mbcase.ProtocolGRPC:
+		v.checkGRPC(f, doc, itf.TaskInfo)
+	case mbcase.ProtocolHTTP:
+		if u, err := url.Parse(itf.ServicePath); err != nil || u.Scheme == "" || u.Host == "" {
+			f.report(doc, "/servicePath", false, "the servicePath of an http interface should be an absolute URL")
+		}
+	}
+
+	checkTags(f, doc, "/tags", itf.Tags)
+	v.checkCommands(f, doc, "/setup", itf.SetUp)
+	v.checkCommands(f, doc, "/teardown", itf.TearDown)
+	v.checkHooks(f, doc, "/beforeRequest", itf.BeforeRequest)
+	v.checkHooks(f, doc, "/afterResponse", itf.AfterResponse)
+	for i, m := range itf.Mocks {
+		v.checkMock(f, doc, fmt.Sprintf("/mocks/%d", i), m)
+	}
+
+	names := make(map[string]struct{})
+	for i, raw := range rawCases {
+		pointer := fmt.Sprintf("/cases/%d", i)
+		namePointer := pointer + "/name"
+		if isParameterized(raw) {
+			namePointer = pointer + "/parameters"
+		}
+
+		cases, err := caseprovider.ExpandCase(filepath.Dir(f.path), raw)
+		if err != nil {
+			f.report(doc, namePointer, false, "%v", err)
+			continue
+		}
+		if len(cases) == 0 {
+			f.report(doc, namePointer, false, "the parameters have no row")
+			continue
+		}
+
+		for _, c := range cases {
+			if _, ok := names[c.Name]; ok {
+				f.report(doc, namePointer, false, "duplicated case name [%s]", c.Name)
+			}
+			names[c.Name] = struct{}{}
+			if c.Name == itf.ServiceName {
+				f.report(doc, namePointer, false, "case name cannot be the same as the serviceName")
+			}
+		}
+
+		// the rows share the structure of the case, the first one is checked.
+		c := cases[0]
+		checkTags(f, doc, pointer+"/tags", c.Tags)
+		v.checkCommands(f, doc, pointer+"/setup", c.SetUp)
+		v.checkCommands(f, doc, pointer+"/teardown", c.TearDown)
+		v.checkHooks(f, doc, pointer+"/beforeRequest", c.BeforeRequest)
+		v.checkHooks(f, doc, pointer+"/afterResponse", c.AfterResponse)
+		for j, m := range c.Mocks {
+			v.checkMock(f, doc, fmt.Sprintf("%s/mocks/%d", pointer, j), m)
+		}
+		if c.Assert != nil {
+			for j, a := range c.Assert.OtherAsserts {
+				if !v.hasAssertPlugin(a.TypeName) {
+					f.report(doc, fmt.Sprintf("%s/assert/otherAsserts/%d/typeName", pointer, j), false,
+						"unknown assert plugin [%s], registered: %s", a.TypeName, strings.Join(v.assertPluginNames(), ", "))
+				}
+			}
+		}
+	}
+}
+
+// checkTags check that the tags can be selected by a filter expression.
+func checkTags(f *fileContext, doc int, pointer string, tags []string) {
+	for i, tag := range tags {
+		if !tagexpr.ValidTag(tag) {
+			f.report(doc, fmt.Sprintf("%s/%d", pointer, i), false,
+				"the tag [%s] cannot contain spaces, parentheses, \"!\", \"&&\" or \"||\"", tag)
+		}
+	}
+}
+
+// checkGRPC check the proto file and the method of the interface against the proto manager.
+func (v *validator) checkGRPC(f *fileContext, doc int, info *mbcase.TaskInfo) {
+	if info.ServiceProtoFile == "" {
+		f.report(doc, "", false, "serviceProtoFile is required by the grpc interfaces")
+	} else if !v.protoFileExists(info.ServiceProtoFile) {
+		f.report(doc, "/serviceProtoFile", false, "cannot find the proto file [%s] in the import paths %v",
+			info.ServiceProtoFile, v.protoProvider.GetImportPaths())
+	}
+
+	name := info.ServicePath
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	if _, ok := v.protoProvider.GetMethod(name); !ok {
+		f.report(doc, "/servicePath", false, "cannot find the grpc method [%s] in the loaded proto files", name)
+	}
+}
+
+// protoFileExists the proto file is relative to an import path, or to the working directory without import paths.
+func (v *validator) protoFileExists(file string) bool {
+	importPaths := v.protoProvider.GetImportPaths()
+	if len(importPaths) == 0 || filepath.IsAbs(file) {
+		return fileExists(file)
+	}
+	for _, p := range importPaths {
+		if fileExists(filepath.Join(p, file)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) checkCommands(f *fileContext, doc int, pointer string, commands []*mbcase.Command) {
+	for i, c := range commands {
+		// the commands of an unknown type would never run.
+		if c != nil && len(c.Commands) > 0 && !v.hasEnvPlugin(c.TypeName) {
+			f.report(doc, fmt.Sprintf("%s/%d/typeName", pointer, i), false,
+				"unknown env plugin [%s], registered: %s", c.TypeName, strings.Join(v.envPluginNames(), ", "))
+		}
+	}
+}
+
+func (v *validator) checkHooks(f *fileContext, doc int, pointer string, hooks []*mbcase.Command) {
+	for i, c := range hooks {
+		// the hooks of an unknown type would never run.
+		if c != nil && len(c.Commands) > 0 && !v.hasHookPlugin(c.TypeName) {
+			f.report(doc, fmt.Sprintf("%s/%d/typeName", pointer, i), false,
+				"unknown hook plugin [%s], registered: %s", c.TypeName, strings.Join(v.hookPluginNames(), ", "))
+		}
+	}
+}
+
+// checkMock check that the files sent by the mock exist.
+func (v *validator) checkMock(f *fileContext, doc int, pointer string, mock *interact.ImposterMockCase) {
+	if mock == nil {
+		return
+	}
+	body, ok := mock.Response.Body.(string)
+	if !ok || !strings.HasPrefix(body, common.StreamFilePrefix) {
+		return
+	}
+	if file := strings.TrimPrefix(body, common.StreamFilePrefix); !fileExists(file) {
+		f.report(doc, pointer+"/response/body", false, "the file [%s] does not exist", file)
+	}
+}
+
+func (v *validator) hasEnvPlugin(typeName string) bool {
+	for _, name := range v.envPluginNames() {
+		if name == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) envPluginNames() (names []string) {
+	for _, p := range v.registry.EnvPlugins() {
+		names = append(names, p.GetTypeName())
+	}
+	return
+}
+
+func (v *validator) hasAssertPlugin(typeName string) bool {
+	for _, name := range v.assertPluginNames() {
+		if name == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) assertPluginNames() (names []string) {
+	for _, p := range v.registry.AssertPlugins() {
+		names = append(names, p.GetTypeName())
+	}
+	return
+}
+
+func (v *validator) hasHookPlugin(typeName string) bool {
+	for _, name := range v.hookPluginNames() {
+		if name == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) hookPluginNames() (names []string) {
+	for _, p := range v.registry.HookPlugins() {
+		names = append(names, p.GetTypeName())
+	}
+	return
+}
+
+// isParameterized whether the raw case has parameters.
+func isParameterized(raw interface{}) bool {
+	m, ok := raw.(map[string]interface{})
+	return ok && m["parameters"] != nil
+}
+
+// isPlaceholder whether the value of the pointer is a parameter placeholder in a parameterized case.
+func isPlaceholder(value interface{}, pointer string) bool {
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	if len(tokens) < 3 || tokens[0] != "cases" {
+		return false
+	}
+
+	var parameterized bool
+	for i, token := range tokens {
+		switch vv := value.(type) {
+		case map[string]interface{}:
+			value = vv[strings.NewReplacer("~1", "/", "~0", "~").Replace(token)]
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(vv) {
+				return false
+			}
+			value = vv[idx]
+		default:
+			return false
+		}
+		// tokens[1] is the index of the case.
+		if i == 1 {
+			parameterized = isParameterized(value)
+		}
+	}
+
+	s, ok := value.(string)
+	return ok && parameterized && caseprovider.IsPlaceholder(s)
+}
+
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+// leaves returns the errors without causes, which describe the actual problems.
+func leaves(ve *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(ve.Causes) == 0 {
+		return []*jsonschema.ValidationError{ve}
+	}
+	var out []*jsonschema.ValidationError
+	for _, c := range ve.Causes {
+		out = append(out, leaves(c)...)
+	}
+	return out
+}
+
+// removeNulls returns the value without the null properties.
+func removeNulls(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(vv))
+		for k, e := range vv {
+			if e != nil {
+				out[k] = removeNulls(e)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(vv))
+		for i, e := range vv {
+			out[i] = removeNulls(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// convert decode the JSON value into out.
+func convert(value interface{}, out interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,249 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package casevalidator
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/caseprovider"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry"
+	"github.com/alsritter/middlebaby/pkg/protomanager"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/jhump/protoreflect/desc"
+)
+
+// the published schemas are regenerated with UPDATE_SCHEMA=1 go test ./pkg/casevalidator/
+func TestPublishedSchemas(t *testing.T) {
+	for file, schema := range map[string][]byte{
+		"../../schema/case.schema.json": CaseSchema(),
+		"../../schema/mock.schema.json": MockSchema(),
+	} {
+		if os.Getenv("UPDATE_SCHEMA") != "" {
+			if err := ioutil.WriteFile(file, schema, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		published, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(published) != string(schema) {
+			t.Errorf("%s is outdated, run UPDATE_SCHEMA=1 go test ./pkg/casevalidator/", file)
+		}
+	}
+}
+
+type fakeProtoProvider struct {
+	protomanager.Provider
+	methods map[string]bool
+}
+
+func (f *fakeProtoProvider) GetMethod(name string) (*desc.MethodDescriptor, bool) {
+	return nil, f.methods[name]
+}
+
+func (f *fakeProtoProvider) GetImportPaths() []string {
+	return nil
+}
+
+type fakeEnvPlugin struct{}
+
+func (fakeEnvPlugin) Name() string                        { return "fakeEnvPlugin" }
+func (fakeEnvPlugin) GetTypeName() string                 { return "mysql" }
+func (fakeEnvPlugin) Run(context.Context, []string) error { return nil }
+
+type fakeHookPlugin struct {
+	pluginregistry.HookPlugin
+}
+
+func (fakeHookPlugin) GetTypeName() string { return "js" }
+
+func TestValidator_Validate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"user.case.json": `{
+  // JSON5 comments are allowed
+  "protocol": "http",
+  "serviceName": "getUser",
+  "servicePath": "http://127.0.0.1:8011/users/1",
+  "setup": [{"typeName": "mysql", "commands": ["DELETE FROM users"]}],
+  "cases": [
+    {
+      "name": "ok",
+      "setup": [{"typeName": "mongo", "commands": ["db.users.drop()"]}],
+      "asert": {}
+    },
+    {"name": "ok", "tags": ["smoke test"], "beforeRequest": [{"typeName": "lua", "commands": ["x = 1"]}], "mocks": [{"request": {"path": "/a"}, "response": {"body": "@file:./missing.txt"}}]}
+  ]
+}`,
+		"hello.case.yaml": `protocol: grpc
+serviceName: hello
+servicePath: /examples.Greeter/Hello
+serviceProtoFile: ./greeter.proto
+---
+protocol: ftp
+serviceName: getUser
+servicePath: /users
+`,
+		"global.mock.json": `[{"request": {"path": "/a", "params": {}}, "response": {"status": "200"}}]`,
+		"broken.mock.json": "[\n  {\"request\": }\n]",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry, _ := pluginregistry.New(logger.NewDefault("test"), pluginregistry.NewConfig())
+	registry.RegisterEnvPlugin(fakeEnvPlugin{})
+	registry.RegisterHookPlugin(fakeHookPlugin{})
+
+	cfg := caseprovider.NewConfig()
+	cfg.CaseFiles = []string{filepath.Join(dir, "*"), filepath.Join(dir, "none", "*.case.json")}
+	cfg.MockFiles = []string{filepath.Join(dir, "global.mock.json"), filepath.Join(dir, "broken.mock.json")}
+
+	v, err := New(logger.NewDefault("test"), cfg, &fakeProtoProvider{}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range v.Validate() {
+		got = append(got, strings.TrimPrefix(d.String(), dir+string(filepath.Separator)))
+	}
+
+	want := []string{
+		"no file matches the pattern",
+		"hello.case.yaml:4:19: [/serviceProtoFile] cannot find the proto file [./greeter.proto]",
+		"hello.case.yaml:3:14: [/servicePath] cannot find the grpc method [/examples.Greeter/Hello]",
+		`hello.case.yaml:6:11: [/protocol] value must be one of "http", "grpc"`,
+		"user.case.json:11:7: [/cases/0/asert] additionalProperties 'asert' not allowed",
+		"global.mock.json:1:29: [/0/request/params] additionalProperties 'params' not allowed",
+		"global.mock.json:1:67: [/0/response/status] expected integer, but got string",
+		"broken.mock.json:2:16: invalid character '}'",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Validate() got %d diagnostics, want %d:\n%s", len(got), len(want), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.Contains(got[i], want[i]) {
+			t.Errorf("Validate() diagnostic %d = %s, want it to contain %s", i, got[i], want[i])
+		}
+	}
+
+	// the semantic checks run once the schema is valid.
+	if err := ioutil.WriteFile(filepath.Join(dir, "user.case.json"),
+		[]byte(strings.Replace(files["user.case.json"], `"asert": {}`, `"assert": {}`, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got = got[:0]
+	for _, d := range v.Validate() {
+		if strings.Contains(d.File, "user.case.json") {
+			got = append(got, d.String())
+		}
+	}
+	want = []string{
+		"[/cases/0/setup/0/typeName] unknown env plugin [mongo], registered: mysql",
+		"[/cases/1/name] duplicated case name [ok]",
+		"[/cases/1/tags/0] the tag [smoke test] cannot contain spaces",
+		"[/cases/1/beforeRequest/0/typeName] unknown hook plugin [lua], registered: js",
+		"[/cases/1/mocks/0/response/body] the file [./missing.txt] does not exist",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Validate() got %d diagnostics, want %d:\n%s", len(got), len(want), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.Contains(got[i], want[i]) {
+			t.Errorf("Validate() diagnostic %d = %s, want it to contain %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestValidator_Validate_Parameters(t *testing.T) {
+	dir := t.TempDir()
+	content := `{
+  "protocol": "http",
+  "serviceName": "getUser",
+  "servicePath": "http://127.0.0.1:8011/users",
+  "cases": [
+    {
+      "name": "get user",
+      "parameters": {"rows": [{"status": 200}, {"status": 404}]},
+      "assert": {"response": {"statusCode": "${status}"}}
+    },
+    {
+      "name": "missing rows",
+      "parameters": {"file": "missing.csv"},
+      "assert": {"response": {"statusCode": "${status}"}}
+    },
+    {"name": "get user [#1]", "assert": {"response": {"statusCode": "${status}"}}}
+  ]
+}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "user.case.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, _ := pluginregistry.New(logger.NewDefault("test"), pluginregistry.NewConfig())
+	cfg := caseprovider.NewConfig()
+	cfg.CaseFiles = []string{filepath.Join(dir, "*.case.json")}
+	v, err := New(logger.NewDefault("test"), cfg, &fakeProtoProvider{}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range v.Validate() {
+		got = append(got, strings.TrimPrefix(d.String(), dir+string(filepath.Separator)))
+	}
+	// the placeholders are only allowed in the parameterized cases.
+	want := []string{
+		"user.case.json:16:69: [/cases/2/assert/response/statusCode] expected integer, but got string",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Validate() = %v, want %v", got, want)
+	}
+
+	content = strings.Replace(content, `"statusCode": "${status}"}}}`, `"statusCode": 200}}}`, 1)
+	if err := ioutil.WriteFile(filepath.Join(dir, "user.case.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got = got[:0]
+	for _, d := range v.Validate() {
+		got = append(got, strings.TrimPrefix(d.String(), dir+string(filepath.Separator)))
+	}
+	want = []string{
+		"user.case.json:13:21: [/cases/1/parameters] case [missing rows]: read parameter file error",
+		"user.case.json:16:14: [/cases/2/name] duplicated case name [get user [#1]]",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Validate() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("Validate() diagnostic %d = %s, want prefix %s", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package fault simulates the failures of the mocked dependencies at the connection level.
+package fault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+)
+
+// ErrTruncated is returned by the truncated body, the proxy drops the connection on a body read error.
+var ErrTruncated = errors.New("mock fault: body truncated")
+
+type connKey struct{}
+
+type streamKey struct{}
+
+// ConnContext keeps the connection of the requests in their context, it is used as http.Server.ConnContext.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// ResetConn reset the connection of the request (TCP RST), it returns false if the connection is unknown.
+func ResetConn(ctx context.Context) bool {
+	c, ok := ctx.Value(connKey{}).(net.Conn)
+	if !ok {
+		return false
+	}
+	if tcp, ok := c.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = c.Close()
+	return true
+}
+
+// Body returns the body reader simulating the fault.
+// The emptyReply and connectionReset faults abort the handler before anything is written.
+func Body(ctx context.Context, f *interact.ResponseFault, data []byte) io.Reader {
+	switch f.Type {
+	case interact.FaultEmptyReply:
+		return readerFunc(func([]byte) (int, error) {
+			panic(http.ErrAbortHandler)
+		})
+	case interact.FaultConnectionReset:
+		return readerFunc(func([]byte) (int, error) {
+			ResetConn(ctx)
+			panic(http.ErrAbortHandler)
+		})
+	case interact.FaultTruncatedBody:
+		n := f.TruncateAt
+		if n <= 0 || n > len(data) {
+			n = len(data) / 2
+		}
+		return io.MultiReader(bytes.NewReader(data[:n]), readerFunc(func([]byte) (int, error) {
+			return 0, ErrTruncated
+		}))
+	case interact.FaultThrottle:
+		return &throttledReader{r: bytes.NewReader(data), bytesPerSecond: f.BytesPerSecond}
+	default:
+		return bytes.NewReader(data)
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+// throttledReader reads at most the bandwidth, in slices of 100 milliseconds.
+type throttledReader struct {
+	r              io.Reader
+	bytesPerSecond int
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.bytesPerSecond <= 0 {
+		return t.r.Read(p)
+	}
+
+	chunk := t.bytesPerSecond / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := t.r.Read(p)
+	time.Sleep(time.Duration(n) * time.Second / time.Duration(t.bytesPerSecond))
+	return n, err
+}
+
+// StreamWriter a response writer whose stream can be reset (RST_STREAM) by the handler.
+// The gRPC server writes the response in the goroutine serving the HTTP request,
+// so the abort panic is recovered by the HTTP server, which resets the stream.
+type StreamWriter struct {
+	http.ResponseWriter
+	reset int32
+}
+
+// WithStreamWriter wraps the response writer and keeps it in the context of the request.
+func WithStreamWriter(w http.ResponseWriter, r *http.Request) (*StreamWriter, *http.Request) {
+	sw := &StreamWriter{ResponseWriter: w}
+	return sw, r.WithContext(context.WithValue(r.Context(), streamKey{}, sw))
+}
+
+// ResetStream reset the stream of the request on its next write, it returns false if the stream is unknown.
+func ResetStream(ctx context.Context) bool {
+	sw, ok := ctx.Value(streamKey{}).(*StreamWriter)
+	if !ok {
+		return false
+	}
+	atomic.StoreInt32(&sw.reset, 1)
+	return true
+}
+
+func (w *StreamWriter) abortIfReset() {
+	if atomic.LoadInt32(&w.reset) == 1 {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (w *StreamWriter) WriteHeader(code int) {
+	w.abortIfReset()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *StreamWriter) Write(b []byte) (int, error) {
+	w.abortIfReset()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, which is required by the gRPC server.
+func (w *StreamWriter) Flush() {
+	w.abortIfReset()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
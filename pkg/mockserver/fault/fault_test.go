@@ -0,0 +1,104 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package fault
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+)
+
+// newServer serves the body of the fault like the http mock server.
+func newServer(t *testing.T, f *interact.ResponseFault, data []byte) *httptest.Server {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(w, Body(r.Context(), f, data)); err != nil {
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+	}))
+	s.Config.ConnContext = ConnContext
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestBody(t *testing.T) {
+	data := []byte(`{"name":"John","color":"Purples"}`)
+	tests := []struct {
+		name    string
+		fault   *interact.ResponseFault
+		want    string
+		wantErr bool
+	}{
+		{name: "empty reply", fault: &interact.ResponseFault{Type: interact.FaultEmptyReply}, wantErr: true},
+		{name: "connection reset", fault: &interact.ResponseFault{Type: interact.FaultConnectionReset}, wantErr: true},
+		{name: "truncated body", fault: &interact.ResponseFault{Type: interact.FaultTruncatedBody, TruncateAt: 5}, wantErr: true},
+		{name: "throttle", fault: &interact.ResponseFault{Type: interact.FaultThrottle, BytesPerSecond: 1 << 20}, want: string(data)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer(t, tt.fault, data)
+			resp, err := http.Get(s.URL)
+			if err == nil {
+				var b []byte
+				b, err = ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err == nil && string(b) != tt.want {
+					t.Errorf("Body() = %s, want %s", b, tt.want)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Body() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_throttledReader_Read(t *testing.T) {
+	start := time.Now()
+	r := Body(context.Background(), &interact.ResponseFault{Type: interact.FaultThrottle, BytesPerSecond: 100}, make([]byte, 20))
+	b, err := ioutil.ReadAll(r)
+	if err != nil || len(b) != 20 {
+		t.Fatalf("throttledReader.Read() = %d, %v", len(b), err)
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("throttledReader.Read() took %v, want about 200ms", d)
+	}
+}
+
+func TestResetStream(t *testing.T) {
+	sw, r := WithStreamWriter(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	sw.WriteHeader(http.StatusOK)
+	if !ResetStream(r.Context()) {
+		t.Fatalf("ResetStream() = false")
+	}
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("StreamWriter.Write() panic = %v, want %v", err, http.ErrAbortHandler)
+		}
+	}()
+	_, _ = sw.Write([]byte("data"))
+}
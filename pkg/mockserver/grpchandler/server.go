@@ -21,8 +21,10 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/alsritter/middlebaby/pkg/apimanager"
+	"github.com/alsritter/middlebaby/pkg/mockserver/fault"
 	"github.com/alsritter/middlebaby/pkg/protomanager"
 	"github.com/alsritter/middlebaby/pkg/types/interact"
 	"github.com/alsritter/middlebaby/pkg/util"
@@ -104,6 +106,12 @@ func (s *mockServer) handleStream(srv interface{}, stream grpc.ServerStream) err
 		return s.sendError(err)
 	}
 
+	if response.Fault != nil {
+		if ok, err := s.applyFault(stream.Context(), response.Fault); ok {
+			return s.sendError(err)
+		}
+	}
+
 	s.Debug(nil, "mock [%v] request successful", fullMethodName)
 	stream.SetTrailer(metadata.New(util.SliceMapToStringMap(response.Trailer)))
 	if len(response.Header) > 0 {
@@ -137,6 +145,10 @@ func (s *mockServer) handleStream(srv interface{}, stream grpc.ServerStream) err
 		return s.sendError(multierror.Prefix(err, "failed to marshal:"))
 	}
 
+	if response.Fault != nil && response.Fault.Type == interact.FaultThrottle && response.Fault.BytesPerSecond > 0 {
+		time.Sleep(time.Duration(len(binaryData)) * time.Second / time.Duration(response.Fault.BytesPerSecond))
+	}
+
 	// send the response
 	if err := stream.SendMsg(interact.NewBytesMessage(binaryData)); err != nil {
 		return s.sendError(status.Errorf(codes.Internal, "failed to send message: %s", err))
@@ -144,6 +156,32 @@ func (s *mockServer) handleStream(srv interface{}, stream grpc.ServerStream) err
 	return nil
 }
 
+// applyFault simulate the fault of the mock, it returns false if the fault does not end the call.
+func (s *mockServer) applyFault(ctx context.Context, f *interact.ResponseFault) (bool, error) {
+	s.Info(nil, "mock grpc request with fault [%s]", f.Type)
+	switch f.Type {
+	case interact.FaultDeadlineExceeded:
+		// a call without deadline would wait forever.
+		if _, ok := ctx.Deadline(); ok {
+			<-ctx.Done()
+		}
+		return true, status.Error(codes.DeadlineExceeded, "mock fault: deadline exceeded")
+	case interact.FaultStreamReset, interact.FaultEmptyReply:
+		if !fault.ResetStream(ctx) {
+			s.Warn(nil, "the stream cannot be reset, respond UNAVAILABLE instead")
+		}
+		return true, status.Error(codes.Unavailable, "mock fault: stream reset")
+	case interact.FaultConnectionReset:
+		if !fault.ResetConn(ctx) {
+			s.Warn(nil, "the connection cannot be reset, respond UNAVAILABLE instead")
+		}
+		return true, status.Error(codes.Unavailable, "mock fault: connection reset")
+	case interact.FaultTruncatedBody:
+		s.Warn(nil, "the fault [%s] is not supported by grpc", f.Type)
+	}
+	return false, nil
+}
+
 func (s *mockServer) sendError(err error) error {
 	s.Error(nil, "%v", err)
 	return err
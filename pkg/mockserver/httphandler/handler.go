@@ -21,12 +21,14 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"sync"
 
 	"github.com/alsritter/middlebaby/pkg/apimanager"
+	"github.com/alsritter/middlebaby/pkg/mockserver/fault"
 	"github.com/alsritter/middlebaby/pkg/types/interact"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
 
@@ -86,6 +88,12 @@ func (e *delegateHandler) BeforeRequest(ctx *goproxy.Context) {
 		bd = []byte("")
 	}
 
+	var respBody io.Reader = bytes.NewReader(bd)
+	if resp.Fault != nil {
+		e.Info(nil, "mock [%v] request with fault [%s]", ctx.Req.URL, resp.Fault.Type)
+		respBody = fault.Body(ctx.Req.Context(), resp.Fault, bd)
+	}
+
 	e.Debug(nil, "mock [%v] request successful", ctx.Req.URL)
 	ctx.IsNeedMock()
 	ctx.Resp = &http.Response{
@@ -95,7 +103,7 @@ func (e *delegateHandler) BeforeRequest(ctx *goproxy.Context) {
 		ProtoMajor: ctx.Req.ProtoMajor,
 		ProtoMinor: ctx.Req.ProtoMinor,
 		Header:     resp.Header,
-		Body:       ioutil.NopCloser(bytes.NewReader(bd)),
+		Body:       ioutil.NopCloser(respBody),
 	}
 }
 
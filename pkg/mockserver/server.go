@@ -26,6 +26,7 @@ import (
 	"strings"
 
 	"github.com/alsritter/middlebaby/pkg/apimanager"
+	"github.com/alsritter/middlebaby/pkg/mockserver/fault"
 	"github.com/alsritter/middlebaby/pkg/mockserver/grpchandler"
 	"github.com/alsritter/middlebaby/pkg/mockserver/httphandler"
 	"github.com/alsritter/middlebaby/pkg/protomanager"
@@ -121,12 +122,15 @@ func (m *MockServe) start() error {
 	m.server.Handler = h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.ProtoMajor == 2 && strings.HasPrefix(
 			r.Header.Get("Content-Type"), "application/grpc") {
-			m.grpcServer.ServeHTTP(w, r)
+			// the stream can be reset by the fault of the mock.
+			m.grpcServer.ServeHTTP(fault.WithStreamWriter(w, r))
 		} else {
 			m.httpServer.ServeHTTP(w, r)
 		}
 	}), &http2.Server{})
 
+	// the connection can be reset by the fault of the mock.
+	m.server.ConnContext = fault.ConnContext
 	if err := http2.ConfigureServer(m.server, &http2.Server{}); err != nil {
 		return fmt.Errorf("proxy http2 error: %v", err)
 	}
@@ -18,6 +18,8 @@
 package pluginregistry
 
 import (
+	"context"
+
 	"github.com/alsritter/middlebaby/pkg/types/mbcase"
 )
 
@@ -26,6 +28,6 @@ type AssertPlugin interface {
 	// GetTypeName the plugin type
 	GetTypeName() string
 
-	// Assert response and other assert commands
-	Assert(*mbcase.Response, []mbcase.CommonAssert) error
+	// Assert response and other assert commands, the assert is abandoned when the ctx is done.
+	Assert(context.Context, *mbcase.Response, []mbcase.CommonAssert) error
 }
@@ -0,0 +1,140 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package javascript
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/pluginregistry"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+// engine is a JavaScript runtime holding the globals of one Assert or hook call.
+type engine interface {
+	// define defines a global function, its arguments are converted to strings
+	// and its error is thrown as the message.
+	define(name string, fn func(args []string) (string, error)) error
+	// run runs the script and returns its completion value as JSON,
+	// the script is terminated when the ctx is done.
+	run(ctx context.Context, script string) ([]byte, error)
+	// close releases the runtime.
+	close()
+}
+
+// scriptRunner creates the engines and runs the scripts of the js plugins.
+type scriptRunner struct {
+	cfg *pluginregistry.JSConfig
+	logger.Logger
+}
+
+// helpers the global functions of the scripts.
+var helpers = map[string]func(args []string) (string, error){
+	// hmacSHA256(key, message) returns the hex HMAC-SHA256 of the message.
+	"hmacSHA256": func(args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("hmacSHA256 expects 2 arguments (key, message), got %d", len(args))
+		}
+		mac := hmac.New(sha256.New, []byte(args[0]))
+		mac.Write([]byte(args[1]))
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	},
+	// sha256(message) returns the hex SHA-256 of the message.
+	"sha256": func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("sha256 expects 1 argument (message), got %d", len(args))
+		}
+		sum := sha256.Sum256([]byte(args[0]))
+		return hex.EncodeToString(sum[:]), nil
+	},
+	"base64Encode": func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("base64Encode expects 1 argument, got %d", len(args))
+		}
+		return base64.StdEncoding.EncodeToString([]byte(args[0])), nil
+	},
+	// base64Decode(s) accepts the standard and the URL alphabet, with or without padding.
+	"base64Decode": func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("base64Decode expects 1 argument, got %d", len(args))
+		}
+		s := strings.NewReplacer("-", "+", "_", "/").Replace(strings.TrimRight(args[0], "="))
+		b, err := base64.RawStdEncoding.DecodeString(s)
+		if err != nil {
+			return "", fmt.Errorf("base64Decode: %v", err)
+		}
+		return string(b), nil
+	},
+}
+
+// helpersPrelude defines the helpers written in JavaScript.
+const helpersPrelude = `function decodeJWT(token) {
+	var parts = String(token).split('.');
+	if (parts.length !== 3) {
+		throw 'decodeJWT: the token has ' + parts.length + ' parts instead of 3';
+	}
+	return {header: JSON.parse(base64Decode(parts[0])), payload: JSON.parse(base64Decode(parts[1]))};
+}`
+
+// newEngine returns the configured engine with the helpers defined.
+func (s *scriptRunner) newEngine(ctx context.Context) (engine, error) {
+	var (
+		vm  engine
+		err error
+	)
+	if s.cfg.Engine == pluginregistry.JSEngineGoja {
+		vm = newGojaEngine()
+	} else if vm, err = newV8Engine(s); err != nil {
+		return nil, err
+	}
+
+	for name, fn := range helpers {
+		if err := vm.define(name, fn); err != nil {
+			vm.close()
+			return nil, fmt.Errorf("define js helper [%s] error: %v", name, err)
+		}
+	}
+	if _, err := s.runScript(ctx, vm, helpersPrelude); err != nil {
+		vm.close()
+		return nil, err
+	}
+	return vm, nil
+}
+
+// runScript runs the script within the configured timeout.
+func (s *scriptRunner) runScript(ctx context.Context, vm engine, script string) ([]byte, error) {
+	if s.cfg.Timeout > 0 {
+		timeout := time.Duration(s.cfg.Timeout) * time.Millisecond
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		value, err := vm.run(ctx, script)
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("the script timed out after %s: [%s]", timeout, script)
+		}
+		return value, err
+	}
+	return vm.run(ctx, script)
+}
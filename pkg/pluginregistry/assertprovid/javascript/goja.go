@@ -0,0 +1,83 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package javascript
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/dop251/goja"
+)
+
+// gojaEngine runs the scripts on goja, a pure Go engine.
+type gojaEngine struct {
+	vm *goja.Runtime
+}
+
+func newGojaEngine() engine {
+	return &gojaEngine{vm: goja.New()}
+}
+
+func (e *gojaEngine) define(name string, fn func(args []string) (string, error)) error {
+	return e.vm.Set(name, func(call goja.FunctionCall) goja.Value {
+		args := make([]string, len(call.Arguments))
+		for i, a := range call.Arguments {
+			args[i] = a.String()
+		}
+		res, err := fn(args)
+		if err != nil {
+			panic(e.vm.ToValue(err.Error()))
+		}
+		return e.vm.ToValue(res)
+	})
+}
+
+func (e *gojaEngine) run(ctx context.Context, script string) ([]byte, error) {
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			e.vm.Interrupt(ctx.Err())
+		case <-stop:
+		}
+	}()
+
+	val, err := e.vm.RunScript("main.js", script)
+	// make sure a late interrupt cannot leak into the next script.
+	close(stop)
+	<-stopped
+	e.vm.ClearInterrupt()
+
+	if err != nil {
+		var ex *goja.Exception
+		if errors.As(err, &ex) {
+			// e.g. "AssertionError: expected 200, actual 404"
+			return nil, errors.New(ex.Value().String())
+		}
+		return nil, err
+	}
+
+	if val == nil || goja.IsUndefined(val) {
+		return nil, nil
+	}
+	return json.Marshal(val.Export())
+}
+
+func (e *gojaEngine) close() {}
@@ -0,0 +1,237 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package javascript
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/alsritter/middlebaby/pkg/pluginregistry"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+type jsHookPlugin struct {
+	scriptRunner
+}
+
+// NewHook returns the plugin running the js hooks of the cases, the commands of a hook
+// share the globals "request", "response" (afterResponse only) and "vars".
+func NewHook(log logger.Logger, cfg *pluginregistry.JSConfig) pluginregistry.HookPlugin {
+	return &jsHookPlugin{scriptRunner{cfg: cfg, Logger: log.NewLogger("js-hook")}}
+}
+
+// Name implements pluginregistry.HookPlugin
+func (*jsHookPlugin) Name() string {
+	return "jsHookPlugin"
+}
+
+// GetTypeName implements pluginregistry.HookPlugin
+func (*jsHookPlugin) GetTypeName() string {
+	return "js"
+}
+
+// hookState the globals read and written by the hooks.
+type hookState struct {
+	Request  json.RawMessage            `json:"request"`
+	Response json.RawMessage            `json:"response"`
+	Vars     map[string]json.RawMessage `json:"vars"`
+}
+
+// BeforeRequest implements pluginregistry.HookPlugin
+// e.g. "request.header['X-Timestamp'] = String(Date.now())"
+func (h *jsHookPlugin) BeforeRequest(ctx context.Context, commands []string, req *mbcase.CaseRequest,
+	vars *task.Vars) (*mbcase.CaseRequest, error) {
+	if len(commands) == 0 {
+		return req, nil
+	}
+
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	before, after, err := h.runHook(ctx, commands, in, nil, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed, err := jsonChanged(before.Request, after.Request); err != nil || !changed {
+		return req, err
+	}
+
+	modified := &mbcase.CaseRequest{}
+	if err := decodeJSON(after.Request, modified); err != nil {
+		return nil, fmt.Errorf("js hook request error: %v", err)
+	}
+	return modified, nil
+}
+
+// AfterResponse implements pluginregistry.HookPlugin
+// e.g. "vars.userId = decodeJWT(response.data.token).payload.sub"
+func (h *jsHookPlugin) AfterResponse(ctx context.Context, commands []string, req *mbcase.CaseRequest,
+	resp *mbcase.Response, vars *task.Vars) (*mbcase.Response, error) {
+	if len(commands) == 0 {
+		return resp, nil
+	}
+
+	decoded := *resp
+	if canToJson, data := toJsonInterface(resp.Data); canToJson {
+		decoded.Data = data
+	}
+
+	reqRaw, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := json.Marshal(&decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	before, after, err := h.runHook(ctx, commands, reqRaw, in, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if changed, err := jsonChanged(before.Response, after.Response); err != nil || !changed {
+		return resp, err
+	}
+
+	modified := &mbcase.Response{}
+	if err := decodeJSON(after.Response, modified); err != nil {
+		return nil, fmt.Errorf("js hook response error: %v", err)
+	}
+
+	// keep the body a string like the responses of the target.
+	if _, isString := resp.Data.(string); isString {
+		if _, stillString := modified.Data.(string); !stillString {
+			body, err := json.Marshal(modified.Data)
+			if err != nil {
+				return nil, err
+			}
+			modified.Data = string(body)
+		}
+	}
+	return modified, nil
+}
+
+// runHook runs the commands in an engine holding the request, the response and the vars,
+// and returns the globals before and after the commands, the vars changed by the commands are saved.
+// the values are compared after they have been through JavaScript, which loses the precision of large numbers.
+func (h *jsHookPlugin) runHook(ctx context.Context, commands []string, req, resp json.RawMessage,
+	vars *task.Vars) (before, after *hookState, err error) {
+	varsRaw, err := json.Marshal(vars.All())
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp == nil {
+		resp = json.RawMessage("null")
+	}
+
+	vm, err := h.newEngine(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer vm.close()
+
+	globals := fmt.Sprintf("var request = %s; var response = %s; var vars = %s;", req, resp, varsRaw)
+	if _, err := h.runScript(ctx, vm, globals); err != nil {
+		return nil, nil, err
+	}
+
+	if before, err = h.globals(ctx, vm); err != nil {
+		return nil, nil, err
+	}
+
+	for _, c := range commands {
+		if _, err := h.runScript(ctx, vm, c); err != nil {
+			return nil, nil, fmt.Errorf("js hook [%s] error: %v", c, err)
+		}
+	}
+
+	if after, err = h.globals(ctx, vm); err != nil {
+		return nil, nil, err
+	}
+
+	for name, value := range after.Vars {
+		if old, exists := before.Vars[name]; exists {
+			changed, err := jsonChanged(old, value)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !changed {
+				continue
+			}
+		}
+
+		var v interface{}
+		if err := decodeJSON(value, &v); err != nil {
+			return nil, nil, fmt.Errorf("js hook variable [%s] error: %v", name, err)
+		}
+		h.Debug(nil, "js hook set variable [%s]", name)
+		vars.Set(name, v)
+	}
+	return before, after, nil
+}
+
+// globals returns the current globals of the hooks.
+func (h *jsHookPlugin) globals(ctx context.Context, vm engine) (*hookState, error) {
+	raw, err := h.runScript(ctx, vm, "({request: request, response: response, vars: vars})")
+	if err != nil {
+		return nil, err
+	}
+
+	state := &hookState{}
+	if err := json.Unmarshal(raw, state); err != nil {
+		return nil, fmt.Errorf("js hook globals error: %v", err)
+	}
+	return state, nil
+}
+
+// jsonChanged whether the JSON values differ, regardless of the order of the object keys.
+func jsonChanged(a, b []byte) (bool, error) {
+	var av, bv interface{}
+	if err := decodeJSON(a, &av); err != nil {
+		return false, err
+	}
+	if err := decodeJSON(b, &bv); err != nil {
+		return false, err
+	}
+
+	ac, err := json.Marshal(av)
+	if err != nil {
+		return false, err
+	}
+	bc, err := json.Marshal(bv)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(ac, bc), nil
+}
+
+// decodeJSON decodes the numbers as json.Number, so that large integers keep their literal.
+func decodeJSON(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
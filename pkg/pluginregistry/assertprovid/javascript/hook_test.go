@@ -0,0 +1,119 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package javascript
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/pluginregistry"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func Test_jsHookPlugin_BeforeRequest(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`{"id":1}`))
+	sign := hex.EncodeToString(mac.Sum(nil))
+
+	for _, engine := range []string{pluginregistry.JSEngineV8, pluginregistry.JSEngineGoja} {
+		t.Run(engine, func(t *testing.T) {
+			h := NewHook(logger.NewDefault("test"), &pluginregistry.JSConfig{Engine: engine, Timeout: 1000})
+			vars := task.NewVars()
+			vars.Set("secret", "secret")
+			vars.Set("big", json.Number("9007199254740993"))
+
+			req := &mbcase.CaseRequest{Header: map[string]string{"A": "1"}, Data: map[string]interface{}{"id": 1}}
+			got, err := h.BeforeRequest(context.Background(), []string{
+				"request.data = JSON.stringify(request.data)",
+				"request.header['X-Sign'] = hmacSHA256(vars.secret, request.data)",
+				"vars.signed = true",
+			}, req, vars)
+			if err != nil {
+				t.Fatalf("BeforeRequest() error = %v", err)
+			}
+
+			if got.Data != `{"id":1}` || got.Header["X-Sign"] != sign || got.Header["A"] != "1" {
+				t.Errorf("BeforeRequest() = %+v, want the signed request", got)
+			}
+			if v, _ := vars.Get("signed"); v != true {
+				t.Errorf("BeforeRequest() vars.signed = %v, want true", v)
+			}
+			// the unchanged variables keep their value.
+			if v, _ := vars.Get("big"); v != json.Number("9007199254740993") {
+				t.Errorf("BeforeRequest() vars.big = %v, want it unchanged", v)
+			}
+
+			// a request the hooks do not change is returned as is.
+			same, err := h.BeforeRequest(context.Background(), []string{"var ts = Date.now()"}, req, vars)
+			if err != nil || same != req {
+				t.Errorf("BeforeRequest() = %v, %v, want the same request", same, err)
+			}
+		})
+	}
+}
+
+func Test_jsHookPlugin_AfterResponse(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"u-42"}`))
+	token := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." + payload + ".sig"
+
+	for _, engine := range []string{pluginregistry.JSEngineV8, pluginregistry.JSEngineGoja} {
+		t.Run(engine, func(t *testing.T) {
+			h := NewHook(logger.NewDefault("test"), &pluginregistry.JSConfig{Engine: engine, Timeout: 1000})
+			vars := task.NewVars()
+			resp := &mbcase.Response{
+				Header:     map[string]string{"Content-Type": "application/json"},
+				Data:       `{"token":"` + token + `"}`,
+				StatusCode: 200,
+			}
+
+			got, err := h.AfterResponse(context.Background(), []string{
+				"vars.userId = decodeJWT(response.data.token).payload.sub",
+				"response.data.user = vars.userId",
+			}, &mbcase.CaseRequest{}, resp, vars)
+			if err != nil {
+				t.Fatalf("AfterResponse() error = %v", err)
+			}
+
+			if v, _ := vars.Get("userId"); v != "u-42" {
+				t.Errorf("AfterResponse() vars.userId = %v, want u-42", v)
+			}
+			body, ok := got.Data.(string)
+			if !ok || !strings.Contains(body, `"user":"u-42"`) || got.StatusCode != 200 {
+				t.Errorf("AfterResponse() = %+v, want the body string with the user", got)
+			}
+
+			_, err = h.AfterResponse(context.Background(), []string{"decodeJWT('nope')"}, &mbcase.CaseRequest{}, resp, vars)
+			if err == nil || !strings.Contains(err.Error(), "decodeJWT: the token has 1 parts instead of 3") {
+				t.Errorf("AfterResponse() error = %v, want the decodeJWT error", err)
+			}
+
+			_, err = h.AfterResponse(context.Background(), []string{"base64Decode('!')"}, &mbcase.CaseRequest{}, resp, vars)
+			if err == nil || !strings.Contains(err.Error(), "base64Decode") {
+				t.Errorf("AfterResponse() error = %v, want the base64Decode error", err)
+			}
+		})
+	}
+}
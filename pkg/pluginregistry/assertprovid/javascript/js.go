@@ -27,15 +27,14 @@ import (
 	"github.com/alsritter/middlebaby/pkg/util/assert"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
 	jsoniter "github.com/json-iterator/go"
-	"rogchap.com/v8go"
 )
 
 type jsAssertPlugin struct {
-	logger.Logger
+	scriptRunner
 }
 
-func New(log logger.Logger) pluginregistry.AssertPlugin {
-	return &jsAssertPlugin{Logger: log.NewLogger("js-assert")}
+func New(log logger.Logger, cfg *pluginregistry.JSConfig) pluginregistry.AssertPlugin {
+	return &jsAssertPlugin{scriptRunner{cfg: cfg, Logger: log.NewLogger("js-assert")}}
 }
 
 // Name implements pluginregistry.AssertPlugin
@@ -43,15 +42,59 @@ func (*jsAssertPlugin) Name() string {
 	return "jsAssertPlugin"
 }
 
+// prelude defines the assert object of the scripts, a thrown AssertionError
+// fails the assertion with its message.
+const prelude = `var assert = (function (response) {
+	function AssertionError(message) {
+		this.name = 'AssertionError';
+		this.message = message;
+	}
+	AssertionError.prototype = Object.create(Error.prototype);
+	AssertionError.prototype.constructor = AssertionError;
+
+	function fail(message) {
+		throw new AssertionError(message);
+	}
+
+	var headers = response.header || {};
+	return {
+		data: response,
+		statusCode: response.statusCode,
+		headers: headers,
+		body: response.data,
+		header: function (name) {
+			for (var key in headers) {
+				if (key.toLowerCase() === String(name).toLowerCase()) {
+					return headers[key];
+				}
+			}
+			return undefined;
+		},
+		fail: fail,
+		ok: function (value, message) {
+			if (!value) {
+				fail(message || 'expected a truthy value, actual ' + JSON.stringify(value));
+			}
+			return true;
+		},
+		equal: function (actual, expected, message) {
+			var a = JSON.stringify(actual), e = JSON.stringify(expected);
+			if (a !== e) {
+				fail((message ? message + ': ' : '') + 'expected ' + e + ', actual ' + a);
+			}
+			return true;
+		}
+	};
+})(%s);`
+
 // Assert CommonAssert e.g.
 // "assert.data.activityList.length==3",
-// "assert.data.activityList[0].activityBase.activityId==1"
-func (j *jsAssertPlugin) Assert(resp *mbcase.Response, asserts []mbcase.CommonAssert) error {
-	ctx := context.Background()
-	vm := v8go.NewContext()
-
+// "assert.body.activityList[0].activityBase.activityId==1",
+// "assert.equal(assert.header('content-type'), 'application/json')"
+// every script is terminated when the ctx is done or its timeout expires.
+func (j *jsAssertPlugin) Assert(ctx context.Context, resp *mbcase.Response, asserts []mbcase.CommonAssert) error {
 	// try converting to JSON
-	if canToJson, actualInterface := j.toJsonInterface(resp.Data); canToJson {
+	if canToJson, actualInterface := toJsonInterface(resp.Data); canToJson {
 		resp.Data = actualInterface
 	}
 
@@ -62,29 +105,36 @@ func (j *jsAssertPlugin) Assert(resp *mbcase.Response, asserts []mbcase.CommonAs
 		return err
 	}
 
-	// init js params.
-	_, err = j.runScript(ctx, vm, fmt.Sprintf("const assert = {data: %s}", respRaw))
+	vm, err := j.newEngine(ctx)
 	if err != nil {
 		return err
 	}
+	defer vm.close()
+
+	// init js params.
+	if _, err = j.runScript(ctx, vm, fmt.Sprintf(prelude, respRaw)); err != nil {
+		return err
+	}
 
+	var errs assert.Errors
 	for _, a := range asserts {
-		value, err := j.runScript(ctx, vm, a.Actual)
-		if err != nil {
-			return err
+		if errs = errs.Append(assertType, j.assert(ctx, vm, a)); len(errs) > 0 && !assert.IsSoft(ctx) {
+			break
 		}
+	}
 
-		str, err := value.MarshalJSON()
-		if err != nil {
-			return fmt.Errorf("js assert result marshal error: [%v]", err)
-		}
+	return errs.Err()
+}
 
-		if err := assert.So(j, "javascript assert", str, a.Expected); err != nil {
-			return err
-		}
+const assertType = "javascript assert"
+
+func (j *jsAssertPlugin) assert(ctx context.Context, vm engine, a mbcase.CommonAssert) error {
+	str, err := j.runScript(ctx, vm, a.Actual)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return assert.SoContext(ctx, j, assertType, str, a.Expected)
 }
 
 // GetTypeName implements pluginregistry.AssertPlugin
@@ -92,7 +142,7 @@ func (*jsAssertPlugin) GetTypeName() string {
 	return "js"
 }
 
-func (*jsAssertPlugin) toJsonInterface(ifc interface{}) (bool, interface{}) {
+func toJsonInterface(ifc interface{}) (bool, interface{}) {
 	if sb, ok := ifc.([]byte); ok {
 		var i interface{}
 		if err := json.Unmarshal(sb, &i); err != nil {
@@ -111,30 +161,3 @@ func (*jsAssertPlugin) toJsonInterface(ifc interface{}) (bool, interface{}) {
 
 	return false, nil
 }
-
-// RunScript is used to run javascript with context
-func (j *jsAssertPlugin) runScript(ctx context.Context, v8Context *v8go.Context, script string) (*v8go.Value, error) {
-	valCh := make(chan *v8go.Value, 1)
-	errCh := make(chan error, 1)
-	go func() {
-		val, err := v8Context.RunScript(script, "main.js")
-		if err != nil {
-			errCh <- err
-			return
-		}
-		valCh <- val
-	}()
-	var terminateFunc = func() error {
-		vm := v8Context.Isolate()
-		vm.TerminateExecution()
-		return <-errCh
-	}
-	select {
-	case val := <-valCh:
-		return val, nil
-	case err := <-errCh:
-		return nil, err
-	case <-ctx.Done():
-		return nil, terminateFunc()
-	}
-}
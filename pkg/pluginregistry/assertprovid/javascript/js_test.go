@@ -18,8 +18,11 @@
 package javascript
 
 import (
+	"context"
+	"strings"
 	"testing"
 
+	"github.com/alsritter/middlebaby/pkg/pluginregistry"
 	"github.com/alsritter/middlebaby/pkg/types/mbcase"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
 )
@@ -37,58 +40,100 @@ e.g.
 }]
 */
 
-func Test_jsAssertPlugin_Assert(t *testing.T) {
-	type fields struct {
-		Logger logger.Logger
-	}
-	type args struct {
-		resp    *mbcase.Response
-		asserts []mbcase.CommonAssert
+func newResponse() *mbcase.Response {
+	return &mbcase.Response{
+		Header: map[string]string{
+			"Date":           "Sun, 11 Sep 2022 01:42:38 GMT",
+			"Content-Length": "42",
+			"Content-Type":   "text/plain; charset=utf-8",
+		},
+		Data:       "{\"name\":\"John\",\"color\":\"Purples\",\"age\":55}",
+		StatusCode: 200,
 	}
+}
+
+func Test_jsAssertPlugin_Assert(t *testing.T) {
 	tests := []struct {
 		name    string
-		fields  fields
-		args    args
-		wantErr bool
+		asserts []mbcase.CommonAssert
+		timeout int64
+		wantErr string
 	}{
 		{
 			name: "测试 js 断言",
-			fields: fields{
-				Logger: logger.NewDefault("test"),
+			asserts: []mbcase.CommonAssert{
+				{TypeName: "js", Actual: "assert.data.statusCode == 200", Expected: true},
+				{TypeName: "js", Actual: "assert.data.data.color == 'Purples'", Expected: true},
+			},
+		},
+		{
+			name: "helpers",
+			asserts: []mbcase.CommonAssert{
+				{TypeName: "js", Actual: "assert.statusCode", Expected: 200},
+				{TypeName: "js", Actual: "assert.body.age", Expected: 55},
+				{TypeName: "js", Actual: "assert.header('content-type')", Expected: "text/plain; charset=utf-8"},
+				{TypeName: "js", Actual: "assert.headers['Content-Length'] === '42'", Expected: true},
+				{TypeName: "js", Actual: "assert.equal(assert.body.name, 'John')", Expected: true},
+			},
+		},
+		{
+			name: "mismatched value",
+			asserts: []mbcase.CommonAssert{
+				{TypeName: "js", Actual: "assert.body.age", Expected: 56},
 			},
-			args: args{
-				resp: &mbcase.Response{
-					Header: map[string]string{
-						"Date":           "Sun, 11 Sep 2022 01:42:38 GMT",
-						"Content-Length": "42",
-						"Content-Type":   "text/plain; charset=utf-8",
-					},
-					Data:       "{\"name\":\"John\",\"color\":\"Purples\",\"age\":55}",
-					StatusCode: 200,
-				},
-				asserts: []mbcase.CommonAssert{
-					{
-						TypeName: "js",
-						Actual:   "assert.data.statusCode == 200",
-						Expected: true,
-					},
-					{
-						TypeName: "js",
-						Actual:   "assert.data.data.color == 'Purples'",
-						Expected: true,
-					},
-				},
+			wantErr: "javascript assert",
+		},
+		{
+			name: "equal failure message",
+			asserts: []mbcase.CommonAssert{
+				{TypeName: "js", Actual: "assert.equal(assert.body.color, 'Red', 'the color')"},
 			},
-			wantErr: false,
+			wantErr: `AssertionError: the color: expected "Red", actual "Purples"`,
+		},
+		{
+			name: "fail message",
+			asserts: []mbcase.CommonAssert{
+				{TypeName: "js", Actual: "if (assert.body.age > 50) assert.fail('too old: ' + assert.body.age)"},
+			},
+			wantErr: "AssertionError: too old: 55",
+		},
+		{
+			name: "script timeout",
+			asserts: []mbcase.CommonAssert{
+				{TypeName: "js", Actual: "while (true) {}"},
+			},
+			timeout: 100,
+			wantErr: "the script timed out after 100ms",
 		},
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			j := &jsAssertPlugin{
-				Logger: tt.fields.Logger,
-			}
-			if err := j.Assert(tt.args.resp, tt.args.asserts); (err != nil) != tt.wantErr {
-				t.Errorf("jsAssertPlugin.Assert() error = %v, wantErr %v", err, tt.wantErr)
+	for _, engine := range []string{pluginregistry.JSEngineV8, pluginregistry.JSEngineGoja} {
+		for _, tt := range tests {
+			t.Run(engine+"/"+tt.name, func(t *testing.T) {
+				j := New(logger.NewDefault("test"), &pluginregistry.JSConfig{Engine: engine, Timeout: tt.timeout})
+				err := j.Assert(context.Background(), newResponse(), tt.asserts)
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Errorf("jsAssertPlugin.Assert() error = %v", err)
+					}
+					return
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("jsAssertPlugin.Assert() error = %v, want %q", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func Test_jsAssertPlugin_Assert_Canceled(t *testing.T) {
+	for _, engine := range []string{pluginregistry.JSEngineV8, pluginregistry.JSEngineGoja} {
+		t.Run(engine, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			j := New(logger.NewDefault("test"), &pluginregistry.JSConfig{Engine: engine})
+			err := j.Assert(ctx, newResponse(), []mbcase.CommonAssert{{TypeName: "js", Actual: "while (true) {}"}})
+			if err == nil {
+				t.Errorf("jsAssertPlugin.Assert() expected an error on a canceled context")
 			}
 		})
 	}
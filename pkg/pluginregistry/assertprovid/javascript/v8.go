@@ -0,0 +1,97 @@
+//go:build !nov8
+// +build !nov8
+
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package javascript
+
+import (
+	"context"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"rogchap.com/v8go"
+)
+
+// v8Engine runs the scripts on v8, it needs cgo.
+type v8Engine struct {
+	iso *v8go.Isolate
+	vm  *v8go.Context
+}
+
+func newV8Engine(logger.Logger) (engine, error) {
+	iso := v8go.NewIsolate()
+	return &v8Engine{iso: iso, vm: v8go.NewContext(iso)}, nil
+}
+
+func (e *v8Engine) define(name string, fn func(args []string) (string, error)) error {
+	tmpl := v8go.NewFunctionTemplate(e.iso, func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+		args := make([]string, len(info.Args()))
+		for i, a := range info.Args() {
+			args[i] = a.String()
+		}
+		res, err := fn(args)
+		if err != nil {
+			msg, _ := v8go.NewValue(e.iso, err.Error())
+			return e.iso.ThrowException(msg)
+		}
+		val, _ := v8go.NewValue(e.iso, res)
+		return val
+	})
+	return e.vm.Global().Set(name, tmpl.GetFunction(e.vm))
+}
+
+func (e *v8Engine) run(ctx context.Context, script string) ([]byte, error) {
+	type result struct {
+		val *v8go.Value
+		err error
+	}
+
+	resCh := make(chan result, 1)
+	go func() {
+		val, err := e.vm.RunScript(script, "main.js")
+		resCh <- result{val: val, err: err}
+	}()
+
+	var res result
+	select {
+	case res = <-resCh:
+	case <-ctx.Done():
+		// a termination before the script is entered is lost, keep terminating until it returns.
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for terminated := false; !terminated; {
+			e.iso.TerminateExecution()
+			select {
+			case res = <-resCh:
+				terminated = true
+			case <-ticker.C:
+			}
+		}
+	}
+
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.val.MarshalJSON()
+}
+
+func (e *v8Engine) close() {
+	e.vm.Close()
+	e.iso.Dispose()
+}
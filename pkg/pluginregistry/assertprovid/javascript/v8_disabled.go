@@ -0,0 +1,37 @@
+//go:build nov8
+// +build nov8
+
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package javascript
+
+import (
+	"sync"
+
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+var fallbackOnce sync.Once
+
+// newV8Engine falls back to goja in the builds without v8.
+func newV8Engine(log logger.Logger) (engine, error) {
+	fallbackOnce.Do(func() {
+		log.Warn(nil, "built without v8 (the nov8 tag), running the js asserts on goja")
+	})
+	return newGojaEngine(), nil
+}
@@ -0,0 +1,84 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package jsonpath
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+	"github.com/alsritter/middlebaby/pkg/util/common"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+type jsonPathAssertPlugin struct {
+	log logger.Logger
+}
+
+func New(log logger.Logger) pluginregistry.AssertPlugin {
+	return &jsonPathAssertPlugin{log: log.NewLogger("plugin.assert.jsonpath")}
+}
+
+func (*jsonPathAssertPlugin) Name() string {
+	return "jsonPathAssertPlugin"
+}
+
+func (*jsonPathAssertPlugin) GetTypeName() string {
+	return "jsonpath"
+}
+
+// Assert CommonAssert e.g.
+// {"actual": "$.data.items[?(@.id==3)].name", "expected": ["apple"]}
+// {"actual": "$.data.total", "expected": "@type:number"}
+// the actual is a JSONPath expression evaluated against the response body, the expected is asserted like the
+// response data (the modifiers are supported), "@absent" accepts a path which selects nothing.
+func (j *jsonPathAssertPlugin) Assert(ctx context.Context, resp *mbcase.Response, asserts []mbcase.CommonAssert) error {
+	if len(asserts) == 0 {
+		return nil
+	}
+
+	body := assert.JSONValue(resp.Data)
+	var errs assert.Errors
+	for _, a := range asserts {
+		if errs = errs.Append(assertType(a.Actual), j.assert(ctx, body, a)); len(errs) > 0 && !assert.IsSoft(ctx) {
+			break
+		}
+	}
+
+	return errs.Err()
+}
+
+func (j *jsonPathAssertPlugin) assert(ctx context.Context, body interface{}, a mbcase.CommonAssert) error {
+	value, err := jsonpath.Get(a.Actual, body)
+	if err != nil {
+		if expected, ok := a.Expected.(string); ok && expected == common.AbsentMarker {
+			return nil
+		}
+		return fmt.Errorf("evaluate the JSONPath [%s] error: %v", a.Actual, err)
+	}
+
+	j.log.Trace(nil, "JSONPath: %s result: %v", a.Actual, value)
+	return assert.SoContext(ctx, j.log, assertType(a.Actual), value, a.Expected)
+}
+
+func assertType(path string) string {
+	return "jsonpath assert " + path
+}
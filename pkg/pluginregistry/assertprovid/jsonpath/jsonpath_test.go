@@ -0,0 +1,70 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package jsonpath
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func Test_jsonPathAssertPlugin_Assert(t *testing.T) {
+	resp := &mbcase.Response{
+		Data:       `{"data": {"total": 2, "items": [{"id": 3, "name": "apple"}, {"id": 4, "name": "pear"}]}}`,
+		StatusCode: 200,
+	}
+	tests := []struct {
+		name    string
+		asserts []mbcase.CommonAssert
+		wantErr bool
+	}{
+		{name: "filter", asserts: []mbcase.CommonAssert{{Actual: "$.data.items[?(@.id==3)].name", Expected: []interface{}{"apple"}}}},
+		{name: "index", asserts: []mbcase.CommonAssert{{Actual: "$.data.items[1].name", Expected: "pear"}}},
+		{name: "wildcard", asserts: []mbcase.CommonAssert{{Actual: "$.data.items[*].id", Expected: []interface{}{3, 4}}}},
+		{name: "modifier", asserts: []mbcase.CommonAssert{{Actual: "$.data.total", Expected: "@type:number"}}},
+		{name: "absent", asserts: []mbcase.CommonAssert{{Actual: "$.data.cursor", Expected: "@absent"}}},
+		{name: "mismatch", asserts: []mbcase.CommonAssert{{Actual: "$.data.items[0].name", Expected: "pear"}}, wantErr: true},
+		{name: "missing", asserts: []mbcase.CommonAssert{{Actual: "$.data.cursor", Expected: "abc"}}, wantErr: true},
+		{name: "invalid expression", asserts: []mbcase.CommonAssert{{Actual: "$.data[", Expected: 1}}, wantErr: true},
+	}
+	j := New(logger.NewDefault("test"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := j.Assert(context.Background(), resp, tt.asserts); (err != nil) != tt.wantErr {
+				t.Errorf("jsonPathAssertPlugin.Assert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_jsonPathAssertPlugin_Assert_Soft(t *testing.T) {
+	resp := &mbcase.Response{Data: `{"a": 1, "b": 2}`}
+	asserts := []mbcase.CommonAssert{
+		{Actual: "$.a", Expected: 2},
+		{Actual: "$.b", Expected: 2},
+		{Actual: "$.c", Expected: 3},
+	}
+
+	err := New(logger.NewDefault("test")).Assert(assert.WithSoft(context.Background(), true), resp, asserts)
+	if errs, _ := err.(assert.Errors); len(errs) != 2 {
+		t.Errorf("jsonPathAssertPlugin.Assert() error = %v, want 2 failures", err)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package jsonschema
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/alsritter/middlebaby/pkg/util/matcher"
+)
+
+const assertType = "jsonschema assert"
+
+type jsonSchemaAssertPlugin struct {
+	log logger.Logger
+}
+
+func New(log logger.Logger) pluginregistry.AssertPlugin {
+	return &jsonSchemaAssertPlugin{log: log.NewLogger("plugin.assert.jsonschema")}
+}
+
+func (*jsonSchemaAssertPlugin) Name() string {
+	return "jsonSchemaAssertPlugin"
+}
+
+func (*jsonSchemaAssertPlugin) GetTypeName() string {
+	return "jsonschema"
+}
+
+// Assert CommonAssert e.g.
+// {"actual": "$.data", "expected": {"type": "object", "required": ["id"]}}
+// {"expected": "./schemas/user.schema.json"}
+// the actual is the JSONPath of the validated value, the whole response body when it is empty,
+// the expected is an inline schema or the path of a schema file (relative to the working directory).
+func (j *jsonSchemaAssertPlugin) Assert(ctx context.Context, resp *mbcase.Response, asserts []mbcase.CommonAssert) error {
+	if len(asserts) == 0 {
+		return nil
+	}
+
+	body := assert.JSONValue(resp.Data)
+	var errs assert.Errors
+	for _, a := range asserts {
+		if errs = errs.Append(assertType, j.assert(body, a)); len(errs) > 0 && !assert.IsSoft(ctx) {
+			break
+		}
+	}
+
+	return errs.Err()
+}
+
+func (j *jsonSchemaAssertPlugin) assert(body interface{}, a mbcase.CommonAssert) error {
+	value := body
+	if a.Actual != "" && a.Actual != "$" {
+		var err error
+		if value, err = jsonpath.Get(a.Actual, body); err != nil {
+			return fmt.Errorf("evaluate the JSONPath [%s] error: %v", a.Actual, err)
+		}
+	}
+
+	switch schema := a.Expected.(type) {
+	case nil:
+		return fmt.Errorf("the JSON schema is required")
+	case string:
+		j.log.Trace(nil, "validate [%s] against the schema file %s", a.Actual, schema)
+		return matcher.ValidateSchemaFile(schema, value)
+	default:
+		j.log.Trace(nil, "validate [%s] against the inline schema", a.Actual)
+		return matcher.ValidateSchema(schema, value)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package jsonschema
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func Test_jsonSchemaAssertPlugin_Assert(t *testing.T) {
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "user.schema.json")
+	if err := ioutil.WriteFile(filepath.Join(dir, "id.schema.json"), []byte(`{"type": "integer", "minimum": 1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(schemaFile, []byte(`{
+		"type": "object",
+		"required": ["id", "name"],
+		"properties": {"id": {"$ref": "id.schema.json"}, "name": {"type": "string"}}
+	}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &mbcase.Response{Data: `{"code": 0, "data": {"id": 1, "name": "alice"}}`, StatusCode: 200}
+	inline := map[string]interface{}{"type": "object", "required": []interface{}{"code", "data"}}
+	tests := []struct {
+		name    string
+		asserts []mbcase.CommonAssert
+		wantErr bool
+	}{
+		{name: "inline schema of the body", asserts: []mbcase.CommonAssert{{Expected: inline}}},
+		{name: "schema file with a reference", asserts: []mbcase.CommonAssert{{Actual: "$.data", Expected: schemaFile}}},
+		{name: "invalid value", asserts: []mbcase.CommonAssert{{Actual: "$.data.name", Expected: map[string]interface{}{"type": "integer"}}}, wantErr: true},
+		{name: "invalid against the file", asserts: []mbcase.CommonAssert{{Actual: "$", Expected: schemaFile}}, wantErr: true},
+		{name: "missing schema file", asserts: []mbcase.CommonAssert{{Expected: filepath.Join(dir, "none.json")}}, wantErr: true},
+		{name: "no schema", asserts: []mbcase.CommonAssert{{Actual: "$.data"}}, wantErr: true},
+	}
+	j := New(logger.NewDefault("test"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := j.Assert(context.Background(), resp, tt.asserts); (err != nil) != tt.wantErr {
+				t.Errorf("jsonSchemaAssertPlugin.Assert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@
 package mysql
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alsritter/middlebaby/pkg/pluginregistry"
@@ -57,24 +58,34 @@ func (m *mysqlAssertPlugin) GetTypeName() string {
 }
 
 // Assert run mysql assertprovid.
-func (m *mysqlAssertPlugin) Assert(_ *mbcase.Response, asserts []mbcase.CommonAssert) error {
+func (m *mysqlAssertPlugin) Assert(ctx context.Context, _ *mbcase.Response, asserts []mbcase.CommonAssert) error {
+	var errs assert.Errors
 	for _, commonAssert := range asserts {
-		if result, err := m.run(commonAssert.Actual); err != nil {
-			return err
-		} else if len(result) <= 0 {
-			return fmt.Errorf("no result is found: %s", commonAssert.Actual)
-
-			// this result[0] returns a map
-		} else if err := assert.So(m.log, "MySQL data assert", result[0], commonAssert.Expected); err != nil {
-			return err
+		if errs = errs.Append(assertType, m.assert(ctx, commonAssert)); len(errs) > 0 && !assert.IsSoft(ctx) {
+			break
 		}
 	}
 
+	return errs.Err()
+}
+
+const assertType = "MySQL data assert"
+
+func (m *mysqlAssertPlugin) assert(ctx context.Context, commonAssert mbcase.CommonAssert) error {
+	if result, err := m.run(ctx, commonAssert.Actual); err != nil {
+		return err
+	} else if len(result) <= 0 {
+		return fmt.Errorf("no result is found: %s", commonAssert.Actual)
+
+		// this result[0] returns a map
+	} else if err := assert.SoContext(ctx, m.log, assertType, result[0], commonAssert.Expected); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (m *mysqlAssertPlugin) run(sql string) (result []map[string]interface{}, err error) {
-	err = m.db.Raw(sql).Find(&result).Error
+func (m *mysqlAssertPlugin) run(ctx context.Context, sql string) (result []map[string]interface{}, err error) {
+	err = m.db.WithContext(ctx).Raw(sql).Find(&result).Error
 	m.log.Trace(nil, "RUN MySQL: %s %v \n", sql, result)
 	return
 }
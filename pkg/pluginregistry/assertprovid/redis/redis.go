@@ -18,6 +18,7 @@
 package redis
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -52,19 +53,28 @@ func (r *redisAssertPlugin) GetTypeName() string {
 }
 
 // Assert run mysql assertprovid.
-func (r *redisAssertPlugin) Assert(_ *mbcase.Response, asserts []mbcase.CommonAssert) error {
+func (r *redisAssertPlugin) Assert(ctx context.Context, _ *mbcase.Response, asserts []mbcase.CommonAssert) error {
+	var errs assert.Errors
 	for _, commonAssert := range asserts {
-		if result, err := r.run(commonAssert.Actual); err != nil {
-			return err
-		} else if err = assert.So(r.log, "Redis data assert", result, commonAssert.Expected); err != nil {
-			return err
+		if errs = errs.Append(assertType, r.assert(ctx, commonAssert)); len(errs) > 0 && !assert.IsSoft(ctx) {
+			break
 		}
 	}
 
-	return nil
+	return errs.Err()
 }
 
-func (r *redisAssertPlugin) run(cmd string) (result interface{}, err error) {
+const assertType = "Redis data assert"
+
+func (r *redisAssertPlugin) assert(ctx context.Context, commonAssert mbcase.CommonAssert) error {
+	result, err := r.run(ctx, commonAssert.Actual)
+	if err != nil {
+		return err
+	}
+	return assert.SoContext(ctx, r.log, assertType, result, commonAssert.Expected)
+}
+
+func (r *redisAssertPlugin) run(ctx context.Context, cmd string) (result interface{}, err error) {
 	// formatting command.
 	cmdList := r.redisParse(cmd)
 	r.log.Trace(nil, "redis parse list: %v", cmdList)
@@ -72,25 +82,26 @@ func (r *redisAssertPlugin) run(cmd string) (result interface{}, err error) {
 		return nil, nil
 	}
 
+	rc := r.rc.WithContext(ctx)
 	commandName := strings.ToLower(cmdList[0])
 	switch commandName {
 	case "get":
-		result, err = r.rc.Get(cmdList[1]).Result()
+		result, err = rc.Get(cmdList[1]).Result()
 	case "hgetall":
-		result, err = r.rc.HGetAll(cmdList[1]).Result()
+		result, err = rc.HGetAll(cmdList[1]).Result()
 	case "set":
 		if len(cmdList) < 3 {
 			r.log.Error(nil, "redis command error:", cmd)
 			return nil, fmt.Errorf("the redis command format is incorrect")
 		}
-		result, err = r.rc.Set(cmdList[1], strings.Join(cmdList[2:], ""), -1).Result()
+		result, err = rc.Set(cmdList[1], strings.Join(cmdList[2:], ""), -1).Result()
 	default:
 		var icmds []interface{}
 		for _, v := range cmdList {
 			icmds = append(icmds, v)
 		}
 		// TODO: the result returned by the do method is interface{}([]byte), inconsistent with the format expected by the default test case. so here need reflection conversion type.
-		result, err = r.rc.Do(icmds...).Result()
+		result, err = rc.Do(icmds...).Result()
 	}
 	if err == redis.Nil {
 		result, err = redis.Nil.Error(), nil
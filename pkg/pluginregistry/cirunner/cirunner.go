@@ -17,40 +17,398 @@
 
 package cirunner
 
-// type Plugin struct {
-// 	taskService *taskserver.Provider
-// 	log         logger.Logger
-// }
-
-// func NewCIRunnerPlugin(taskService *taskserver.Provider, log logger.Logger) *Plugin {
-// 	return &Plugin{
-// 		log:         log.NewLogger("ci-runner-plugin"),
-// 		taskService: taskService,
-// 	}
-// }
-
-// func (s *Plugin) Start() error {
-// 	taskCaseMap := s.taskService.GetAllTestCase()
-// 	for _, testCaseType := range []string{taskserver.TestCaseTypeGRpc, taskserver.TestCaseTypeHTTP} {
-// 		t := taskCaseMap[testCaseType]
-// 		if t == nil {
-// 			continue
-// 		}
-
-// 		interfaceList := t.GetTaskCaseTree()
-// 		for _, iFace := range interfaceList {
-// 			for _, caseName := range iFace.CaseList {
-// 				if err := s.taskService.Run(testCaseType, caseName); err != nil {
-// 					s.log.Error(nil, "execute failure ", caseName, err.Error())
-// 				} else {
-// 					s.log.Debug(nil, "execute successfully ", caseName)
-// 				}
-// 			}
-// 		}
-// 	}
-// 	return nil
-// }
-
-// func (s *Plugin) Name() string {
-// 	return "ci-runner-plugin"
-// }
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/caseprovider"
+	"github.com/alsritter/middlebaby/pkg/taskserver"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/alsritter/middlebaby/pkg/util/tagexpr"
+	"github.com/spf13/pflag"
+)
+
+// Config defines the config structure
+type Config struct {
+	// WaitTimeout how long (in milliseconds) to wait for the target service to accept connections.
+	WaitTimeout int64 `yaml:"waitTimeout"`
+	// Concurrency the number of cases executed at the same time.
+	Concurrency int `yaml:"concurrency"`
+	// Filter the tag expression selecting the cases of "middlebaby run", e.g. "smoke && !slow".
+	Filter string `yaml:"filter"`
+}
+
+// NewConfig is used to init config with default values
+func NewConfig() *Config {
+	return &Config{
+		WaitTimeout: 30000,
+		Concurrency: 1,
+	}
+}
+
+// Validate is used to validate config and returns error on failure
+func (c *Config) Validate() error {
+	if c.WaitTimeout < 0 {
+		return fmt.Errorf("wait timeout cannot be negative")
+	}
+	if c.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	if _, err := tagexpr.Parse(c.Filter); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RegisterFlagsWithPrefix is used to register flags
+func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {
+	f.Int64Var(&c.WaitTimeout, prefix+"runner.wait-timeout", c.WaitTimeout, "milliseconds to wait for the target service to be ready")
+	f.IntVar(&c.Concurrency, prefix+"runner.concurrency", c.Concurrency, "the number of cases executed at the same time")
+	f.StringVar(&c.Filter, prefix+"runner.filter", c.Filter, "the tag expression selecting the cases, e.g. \"smoke && !slow\"")
+}
+
+// Summary the execution result of all cases.
+type Summary struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+	// Quarantined the number of quarantined cases which failed, they are not counted as failed.
+	Quarantined int                  `json:"quarantined"`
+	StartTime   time.Time            `json:"startTime"`
+	Duration    time.Duration        `json:"duration"`
+	Results     []*task.RunTaskReply `json:"results"`
+}
+
+// NewSummary count the results, the results of the cases which have not been executed are nil.
+func NewSummary(startTime time.Time, results []*task.RunTaskReply) *Summary {
+	summary := &Summary{StartTime: startTime, Duration: time.Since(startTime)}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+
+		summary.Total++
+		switch {
+		case result.Skipped():
+			summary.Skipped++
+		case result.Passed():
+			summary.Passed++
+		case result.FailedQuarantined():
+			summary.Quarantined++
+		default:
+			summary.Failed++
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	return summary
+}
+
+// Success whether all cases passed.
+func (s *Summary) Success() bool {
+	return s.Failed == 0
+}
+
+// Selection selects the cases of a run, the empty fields select every case.
+type Selection struct {
+	// ItfName the serviceName of the interface.
+	ItfName string `json:"itfName,omitempty"`
+	// CaseName the name of a case of the interface.
+	CaseName string `json:"caseName,omitempty"`
+	// File the case file, either its path or its name.
+	File string `json:"file,omitempty"`
+	// Filter the tag filter expression.
+	Filter string `json:"filter,omitempty"`
+}
+
+// EventType defines the progress events of a run.
+type EventType string
+
+// defines a set of known events
+const (
+	// EventRunStarted the cases have been selected, the event holds their number.
+	EventRunStarted  EventType = "runStarted"
+	EventCaseStarted EventType = "caseStarted"
+	EventCasePassed  EventType = "casePassed"
+	EventCaseFailed  EventType = "caseFailed"
+	EventCaseSkipped EventType = "caseSkipped"
+)
+
+// Event the progress of a run.
+type Event struct {
+	Type     EventType `json:"type"`
+	ItfName  string    `json:"itfName,omitempty"`
+	CaseName string    `json:"caseName,omitempty"`
+	// Total the number of selected cases. (runStarted)
+	Total int `json:"total,omitempty"`
+	// Result the result of the case. (casePassed, caseFailed and caseSkipped)
+	Result *task.RunTaskReply `json:"result,omitempty"`
+}
+
+// ProgressFunc receives the events of a run, it is called concurrently when the cases run concurrently.
+type ProgressFunc func(Event)
+
+// Provider executes the cases loaded by the case provider.
+type Provider interface {
+	// Run execute the selected cases, progress is optional.
+	Run(ctx context.Context, sel Selection, progress ProgressFunc) (*Summary, error)
+}
+
+type ciRunner struct {
+	logger.Logger
+	cfg          *Config
+	targetAddr   string
+	caseProvider caseprovider.Provider
+	taskService  taskserver.Provider
+}
+
+func New(log logger.Logger, cfg *Config, targetAddr string,
+	caseProvider caseprovider.Provider, taskService taskserver.Provider) Provider {
+	return &ciRunner{
+		Logger:       log.NewLogger("ci-runner"),
+		cfg:          cfg,
+		targetAddr:   targetAddr,
+		caseProvider: caseProvider,
+		taskService:  taskService,
+	}
+}
+
+// job the cases of an interface, they always run in declaration order so that a case
+// can use the variables extracted by the previous ones.
+type job struct {
+	itfName string
+	cases   []jobCase
+	// the index of the first case in the results.
+	index int
+}
+
+type jobCase struct {
+	name string
+	// skip the reason the case is reported as skipped instead of being run.
+	skip string
+}
+
+// Run implements Provider
+// The interfaces are spread over a worker pool, the cases of an interface run one by one in order,
+// then the serial interfaces run one by one.
+func (r *ciRunner) Run(ctx context.Context, sel Selection, progress ProgressFunc) (*Summary, error) {
+	expr, err := tagexpr.Parse(sel.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = func(Event) {}
+	}
+
+	if err := r.waitTarget(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		startTime        = time.Now()
+		itfs             = r.selectItfs(sel)
+		total            int
+		parallel, serial []job
+	)
+	if len(itfs) == 0 && (sel.ItfName != "" || sel.File != "") {
+		return nil, fmt.Errorf("no interface matches the selection %+v", sel)
+	}
+
+	// the provider keeps interfaces in a map, sort them to get a stable execution order.
+	sort.Slice(itfs, func(i, j int) bool {
+		return itfs[i].ServiceName < itfs[j].ServiceName
+	})
+
+	only := hasOnly(itfs, expr)
+	if only {
+		r.Warn(nil, "some cases are marked as only, the other cases are not run")
+	}
+	for _, itf := range itfs {
+		j := job{itfName: itf.ServiceName, index: total}
+		for _, c := range itf.Cases {
+			if (sel.CaseName != "" && c.Name != sel.CaseName) || !selected(itf, c, expr, only) {
+				continue
+			}
+			j.cases = append(j.cases, jobCase{name: c.Name, skip: c.Skip})
+		}
+		if len(j.cases) == 0 {
+			continue
+		}
+		total += len(j.cases)
+
+		if itf.Serial {
+			serial = append(serial, j)
+		} else {
+			parallel = append(parallel, j)
+		}
+	}
+
+	progress(Event{Type: EventRunStarted, Total: total})
+
+	// the interfaces running one by one in name order share the variables of the run (the serial interfaces,
+	// and every interface with a concurrency of 1), the interfaces running concurrently have their own variables.
+	ctx = task.WithVars(ctx, task.NewVars())
+	results := make([]*task.RunTaskReply, total)
+	r.runParallel(ctx, parallel, results, progress)
+	for _, j := range serial {
+		r.runJob(ctx, j, results, progress)
+	}
+
+	// the results are nil for the cases which have not been executed since the run was interrupted.
+	summary := NewSummary(startTime, results)
+	if ctx.Err() != nil {
+		return summary, fmt.Errorf("run has been interrupted: %v", ctx.Err())
+	}
+
+	r.Info(map[string]interface{}{
+		"total":       summary.Total,
+		"passed":      summary.Passed,
+		"failed":      summary.Failed,
+		"skipped":     summary.Skipped,
+		"quarantined": summary.Quarantined,
+		"duration":    summary.Duration.String(),
+	}, "all cases have been executed")
+	return summary, nil
+}
+
+// selectItfs returns the interfaces selected by the interface name and the file of the selection.
+func (r *ciRunner) selectItfs(sel Selection) []*mbcase.ItfTask {
+	if sel.File == "" {
+		var itfs []*mbcase.ItfTask
+		for _, itf := range r.caseProvider.GetAllItf() {
+			if sel.ItfName == "" || itf.ServiceName == sel.ItfName {
+				itfs = append(itfs, itf)
+			}
+		}
+		return itfs
+	}
+
+	var itfs []*mbcase.ItfTask
+	for _, itf := range r.caseProvider.GetAllItfWithFileInfo() {
+		if sel.ItfName != "" && itf.ServiceName != sel.ItfName {
+			continue
+		}
+		if itf.Filename == sel.File || filepath.Join(itf.Dirpath, itf.Filename) == filepath.Clean(sel.File) {
+			itfs = append(itfs, itf.ItfTask)
+		}
+	}
+	return itfs
+}
+
+func (r *ciRunner) runParallel(ctx context.Context, jobs []job, results []*task.RunTaskReply, progress ProgressFunc) {
+	var (
+		wg    sync.WaitGroup
+		queue = make(chan job)
+	)
+
+	for i := 0; i < r.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				jobCtx := ctx
+				if r.cfg.Concurrency > 1 {
+					jobCtx = task.WithVars(ctx, task.NewVars())
+				}
+				r.runJob(jobCtx, j, results, progress)
+			}
+		}()
+	}
+
+dispatch:
+	for _, j := range jobs {
+		select {
+		case queue <- j:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (r *ciRunner) runJob(ctx context.Context, j job, results []*task.RunTaskReply, progress ProgressFunc) {
+	for i, c := range j.cases {
+		if ctx.Err() != nil {
+			return
+		}
+
+		var result *task.RunTaskReply
+		if c.skip != "" {
+			result = &task.RunTaskReply{ItfName: j.itfName, CaseName: c.name, SkipReason: c.skip}
+		} else {
+			progress(Event{Type: EventCaseStarted, ItfName: j.itfName, CaseName: c.name})
+			result = r.runCase(ctx, j.itfName, c.name)
+		}
+		results[j.index+i] = result
+
+		event := Event{Type: EventCasePassed, ItfName: j.itfName, CaseName: c.name, Result: result}
+		if result.Skipped() {
+			event.Type = EventCaseSkipped
+		} else if !result.Passed() {
+			event.Type = EventCaseFailed
+		}
+		progress(event)
+	}
+}
+
+// selected whether the case matches the filter expression (with the tags of its interface),
+// and is marked as "only" when any selected case is.
+func selected(itf *mbcase.ItfTask, c *mbcase.CaseTask, expr *tagexpr.Expr, only bool) bool {
+	tags := append(append([]string(nil), itf.Tags...), c.Tags...)
+	return expr.Match(tags) && (!only || c.Only)
+}
+
+// hasOnly whether any case matching the filter expression is marked as "only".
+func hasOnly(itfs []*mbcase.ItfTask, expr *tagexpr.Expr) bool {
+	for _, itf := range itfs {
+		for _, c := range itf.Cases {
+			if c.Only && selected(itf, c, expr, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *ciRunner) runCase(ctx context.Context, itfName, caseName string) *task.RunTaskReply {
+	reply, err := r.taskService.RunSingleTaskCase(ctx, itfName, caseName)
+	if err != nil {
+		return &task.RunTaskReply{ItfName: itfName, CaseName: caseName, FailedReason: err.Error()}
+	}
+	return &reply
+}
+
+// wait until the target service accepts connections.
+func (r *ciRunner) waitTarget(ctx context.Context) error {
+	if r.targetAddr == "" {
+		return nil
+	}
+
+	deadline := time.Now().Add(time.Duration(r.cfg.WaitTimeout) * time.Millisecond)
+	for {
+		conn, err := net.DialTimeout("tcp", r.targetAddr, time.Second)
+		if err == nil {
+			_ = conn.Close()
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the target service [%s] is not ready: %v", r.targetAddr, err)
+		}
+
+		r.Debug(nil, "waiting for the target service [%s] to be ready", r.targetAddr)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,423 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package cirunner
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/caseprovider"
+	"github.com/alsritter/middlebaby/pkg/taskserver"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+type fakeCaseProvider struct {
+	caseprovider.Provider
+	itfs []*mbcase.ItfTask
+}
+
+func (f *fakeCaseProvider) GetAllItf() []*mbcase.ItfTask {
+	return f.itfs
+}
+
+// GetAllItfWithFileInfo every interface is in its own file: /cases/<serviceName>.case.json
+func (f *fakeCaseProvider) GetAllItfWithFileInfo() []*mbcase.ItfTaskWithFileInfo {
+	var all []*mbcase.ItfTaskWithFileInfo
+	for _, itf := range f.itfs {
+		all = append(all, &mbcase.ItfTaskWithFileInfo{Dirpath: "/cases", Filename: itf.ServiceName + ".case.json", ItfTask: itf})
+	}
+	return all
+}
+
+type fakeTaskService struct {
+	taskserver.Provider
+	failed map[string]bool
+	runs   []string
+	// key: case name, value: the names of the variables visible to the case.
+	vars map[string][]string
+	lock sync.Mutex
+}
+
+// RunSingleTaskCase records the run and the variables visible to the case, then sets a variable named after the case.
+func (f *fakeTaskService) RunSingleTaskCase(ctx context.Context, itfName, caseName string) (task.RunTaskReply, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.runs = append(f.runs, itfName+"/"+caseName)
+
+	vars := task.VarsFromContext(ctx)
+	var visible []string
+	for name := range vars.All() {
+		visible = append(visible, name)
+	}
+	sort.Strings(visible)
+	if f.vars == nil {
+		f.vars = make(map[string][]string)
+	}
+	f.vars[caseName] = visible
+	vars.Set(caseName, true)
+
+	if f.failed[caseName] {
+		return task.RunTaskReply{ItfName: itfName, CaseName: caseName, Status: 0, FailedReason: "failed"}, nil
+	}
+	return task.RunTaskReply{ItfName: itfName, CaseName: caseName, Status: 1}, nil
+}
+
+func Test_ciRunner_Run(t *testing.T) {
+	newItf := func(name string, cases ...string) *mbcase.ItfTask {
+		itf := &mbcase.ItfTask{TaskInfo: &mbcase.TaskInfo{ServiceName: name}}
+		for _, c := range cases {
+			itf.Cases = append(itf.Cases, &mbcase.CaseTask{Name: c})
+		}
+		return itf
+	}
+
+	tests := []struct {
+		name        string
+		itfs        []*mbcase.ItfTask
+		failed      map[string]bool
+		wantRuns    []string
+		wantFailed  int
+		wantSuccess bool
+	}{
+		{
+			name:        "all cases passed",
+			itfs:        []*mbcase.ItfTask{newItf("b", "b1"), newItf("a", "a1", "a2")},
+			wantRuns:    []string{"a/a1", "a/a2", "b/b1"},
+			wantSuccess: true,
+		},
+		{
+			name:        "some cases failed",
+			itfs:        []*mbcase.ItfTask{newItf("a", "a1", "a2")},
+			failed:      map[string]bool{"a2": true},
+			wantRuns:    []string{"a/a1", "a/a2"},
+			wantFailed:  1,
+			wantSuccess: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := &fakeTaskService{failed: tt.failed}
+			r := New(logger.NewDefault("test"), NewConfig(), "", &fakeCaseProvider{itfs: tt.itfs}, ts)
+			summary, err := r.Run(context.Background(), Selection{}, nil)
+			if err != nil {
+				t.Fatalf("ciRunner.Run() error = %v", err)
+			}
+			if summary.Failed != tt.wantFailed || summary.Success() != tt.wantSuccess {
+				t.Errorf("ciRunner.Run() failed = %d, success = %v", summary.Failed, summary.Success())
+			}
+			if len(ts.runs) != len(tt.wantRuns) {
+				t.Fatalf("ciRunner.Run() runs = %v, want %v", ts.runs, tt.wantRuns)
+			}
+			for i := range ts.runs {
+				if ts.runs[i] != tt.wantRuns[i] {
+					t.Errorf("ciRunner.Run() runs = %v, want %v", ts.runs, tt.wantRuns)
+				}
+			}
+		})
+	}
+}
+
+func Test_ciRunner_Run_Concurrency(t *testing.T) {
+	itfs := []*mbcase.ItfTask{
+		{TaskInfo: &mbcase.TaskInfo{ServiceName: "a"}, Serial: true, Cases: []*mbcase.CaseTask{{Name: "a1"}, {Name: "a2"}}},
+		{TaskInfo: &mbcase.TaskInfo{ServiceName: "b"}, Cases: []*mbcase.CaseTask{{Name: "b1"}, {Name: "b2"}}},
+		{TaskInfo: &mbcase.TaskInfo{ServiceName: "c"}, Cases: []*mbcase.CaseTask{{Name: "c1"}}},
+	}
+
+	cfg := NewConfig()
+	cfg.Concurrency = 3
+	ts := &fakeTaskService{}
+	summary, err := New(logger.NewDefault("test"), cfg, "", &fakeCaseProvider{itfs: itfs}, ts).Run(context.Background(), Selection{}, nil)
+	if err != nil {
+		t.Fatalf("ciRunner.Run() error = %v", err)
+	}
+
+	// the results keep the declaration order whatever the execution order is.
+	var got []string
+	for _, r := range summary.Results {
+		got = append(got, r.ItfName+"/"+r.CaseName)
+	}
+	if want := []string{"a/a1", "a/a2", "b/b1", "b/b2", "c/c1"}; !equal(got, want) {
+		t.Errorf("ciRunner.Run() results = %v, want %v", got, want)
+	}
+
+	// the serial interface runs after all other cases, in order.
+	if len(ts.runs) != 5 || !equal(ts.runs[3:], []string{"a/a1", "a/a2"}) {
+		t.Errorf("ciRunner.Run() runs = %v", ts.runs)
+	}
+	parallel := append([]string{}, ts.runs[:3]...)
+	sort.Strings(parallel)
+	if !equal(parallel, []string{"b/b1", "b/b2", "c/c1"}) {
+		t.Errorf("ciRunner.Run() runs = %v", ts.runs)
+	}
+
+	// the cases of an interface keep their order.
+	var b []string
+	for _, run := range ts.runs {
+		if run[0] == 'b' {
+			b = append(b, run)
+		}
+	}
+	if !equal(b, []string{"b/b1", "b/b2"}) {
+		t.Errorf("ciRunner.Run() runs = %v", ts.runs)
+	}
+}
+
+func Test_ciRunner_Run_Vars(t *testing.T) {
+	itfs := []*mbcase.ItfTask{
+		{TaskInfo: &mbcase.TaskInfo{ServiceName: "a"}, Cases: []*mbcase.CaseTask{{Name: "a1"}, {Name: "a2"}}},
+		{TaskInfo: &mbcase.TaskInfo{ServiceName: "b"}, Cases: []*mbcase.CaseTask{{Name: "b1"}}},
+		{TaskInfo: &mbcase.TaskInfo{ServiceName: "c"}, Serial: true, Cases: []*mbcase.CaseTask{{Name: "c1"}}},
+		{TaskInfo: &mbcase.TaskInfo{ServiceName: "d"}, Serial: true, Cases: []*mbcase.CaseTask{{Name: "d1"}}},
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		want        map[string][]string // key: case name, value: the variables visible to the case.
+	}{
+		{
+			// the interfaces run one by one, a case uses the variables extracted by another interface.
+			name:        "one by one",
+			concurrency: 1,
+			want: map[string][]string{
+				"a1": nil,
+				"a2": {"a1"},
+				"b1": {"a1", "a2"},
+				"c1": {"a1", "a2", "b1"},
+				"d1": {"a1", "a2", "b1", "c1"},
+			},
+		},
+		{
+			// the cases of an interface share their variables, the interfaces running concurrently do not,
+			// the serial interfaces share the variables of the run.
+			name:        "concurrently",
+			concurrency: 2,
+			want: map[string][]string{
+				"a1": nil,
+				"a2": {"a1"},
+				"b1": nil,
+				"c1": nil,
+				"d1": {"c1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.Concurrency = tt.concurrency
+			ts := &fakeTaskService{}
+			if _, err := New(logger.NewDefault("test"), cfg, "", &fakeCaseProvider{itfs: itfs}, ts).Run(context.Background(), Selection{}, nil); err != nil {
+				t.Fatalf("ciRunner.Run() error = %v", err)
+			}
+
+			for name, vars := range tt.want {
+				if !equal(ts.vars[name], vars) {
+					t.Errorf("ciRunner.Run() variables of %s = %v, want %v", name, ts.vars[name], vars)
+				}
+			}
+		})
+	}
+}
+
+func Test_ciRunner_Run_Filter(t *testing.T) {
+	newItfs := func() []*mbcase.ItfTask {
+		return []*mbcase.ItfTask{
+			{TaskInfo: &mbcase.TaskInfo{ServiceName: "a"}, Tags: []string{"smoke"}, Cases: []*mbcase.CaseTask{
+				{Name: "a1"},
+				{Name: "a2", Tags: []string{"slow"}},
+				{Name: "a3", Skip: "flaky, see #42"},
+			}},
+			{TaskInfo: &mbcase.TaskInfo{ServiceName: "b"}, Cases: []*mbcase.CaseTask{
+				{Name: "b1", Tags: []string{"smoke"}},
+				{Name: "b2"},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		filter      string
+		only        []string
+		wantResults []string
+		wantRuns    []string
+		wantSkipped int
+		wantErr     bool
+	}{
+		{
+			name:        "no filter",
+			wantResults: []string{"a/a1", "a/a2", "a/a3", "b/b1", "b/b2"},
+			wantRuns:    []string{"a/a1", "a/a2", "b/b1", "b/b2"},
+			wantSkipped: 1,
+		},
+		{
+			name:        "interface and case tags",
+			filter:      "smoke && !slow",
+			wantResults: []string{"a/a1", "a/a3", "b/b1"},
+			wantRuns:    []string{"a/a1", "b/b1"},
+			wantSkipped: 1,
+		},
+		{
+			name:        "only",
+			only:        []string{"a2", "b2"},
+			wantResults: []string{"a/a2", "b/b2"},
+			wantRuns:    []string{"a/a2", "b/b2"},
+		},
+		{
+			name:        "only within the filter",
+			filter:      "smoke",
+			only:        []string{"b2"},
+			wantResults: []string{"a/a1", "a/a2", "a/a3", "b/b1"},
+			wantRuns:    []string{"a/a1", "a/a2", "b/b1"},
+			wantSkipped: 1,
+		},
+		{
+			name:    "invalid filter",
+			filter:  "smoke &&",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			itfs := newItfs()
+			for _, itf := range itfs {
+				for _, c := range itf.Cases {
+					for _, name := range tt.only {
+						c.Only = c.Only || c.Name == name
+					}
+				}
+			}
+
+			ts := &fakeTaskService{}
+			summary, err := New(logger.NewDefault("test"), NewConfig(), "", &fakeCaseProvider{itfs: itfs}, ts).
+				Run(context.Background(), Selection{Filter: tt.filter}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ciRunner.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var got []string
+			for _, r := range summary.Results {
+				got = append(got, r.ItfName+"/"+r.CaseName)
+			}
+			if !equal(got, tt.wantResults) {
+				t.Errorf("ciRunner.Run() results = %v, want %v", got, tt.wantResults)
+			}
+			if !equal(ts.runs, tt.wantRuns) {
+				t.Errorf("ciRunner.Run() runs = %v, want %v", ts.runs, tt.wantRuns)
+			}
+			if summary.Skipped != tt.wantSkipped || !summary.Success() {
+				t.Errorf("ciRunner.Run() skipped = %d, success = %v", summary.Skipped, summary.Success())
+			}
+		})
+	}
+}
+
+func Test_ciRunner_Run_Selection(t *testing.T) {
+	itfs := []*mbcase.ItfTask{
+		{TaskInfo: &mbcase.TaskInfo{ServiceName: "a"}, Cases: []*mbcase.CaseTask{{Name: "a1"}, {Name: "a2", Skip: "later"}}},
+		{TaskInfo: &mbcase.TaskInfo{ServiceName: "b"}, Cases: []*mbcase.CaseTask{{Name: "b1"}}},
+	}
+
+	tests := []struct {
+		name       string
+		sel        Selection
+		wantEvents []string
+		wantErr    bool
+	}{
+		{
+			name:       "interface",
+			sel:        Selection{ItfName: "b"},
+			wantEvents: []string{"runStarted", "caseStarted b/b1", "caseFailed b/b1"},
+		},
+		{
+			name:       "file name",
+			sel:        Selection{File: "a.case.json"},
+			wantEvents: []string{"runStarted", "caseStarted a/a1", "casePassed a/a1", "caseSkipped a/a2"},
+		},
+		{
+			name:       "file path",
+			sel:        Selection{File: "/cases/./b.case.json"},
+			wantEvents: []string{"runStarted", "caseStarted b/b1", "caseFailed b/b1"},
+		},
+		{
+			name:       "case",
+			sel:        Selection{ItfName: "a", CaseName: "a1"},
+			wantEvents: []string{"runStarted", "caseStarted a/a1", "casePassed a/a1"},
+		},
+		{
+			name:    "unknown interface",
+			sel:     Selection{ItfName: "c"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []string
+			ts := &fakeTaskService{failed: map[string]bool{"b1": true}}
+			_, err := New(logger.NewDefault("test"), NewConfig(), "", &fakeCaseProvider{itfs: itfs}, ts).
+				Run(context.Background(), tt.sel, func(e Event) {
+					if e.Type == EventRunStarted {
+						events = append(events, string(e.Type))
+						return
+					}
+					events = append(events, string(e.Type)+" "+e.ItfName+"/"+e.CaseName)
+				})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ciRunner.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !equal(events, tt.wantEvents) {
+				t.Errorf("ciRunner.Run() events = %v, want %v", events, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewSummary(t *testing.T) {
+	summary := NewSummary(time.Now(), []*task.RunTaskReply{
+		{Status: 1},
+		{Status: 1, Attempts: 2},
+		{FailedReason: "failed"},
+		{FailedReason: "failed", Quarantined: true},
+		{SkipReason: "later"},
+		nil,
+	})
+	if summary.Total != 5 || summary.Passed != 2 || summary.Failed != 1 || summary.Quarantined != 1 ||
+		summary.Skipped != 1 || summary.Success() {
+		t.Errorf("NewSummary() = %+v", summary)
+	}
+}
@@ -17,11 +17,13 @@
 
 package pluginregistry
 
+import "context"
+
 // EnvPlugin Provide environmental support at runtime. (mysql, redis, ....)
 type EnvPlugin interface {
 	Plugin
 	// GetTypeName the plugin type
 	GetTypeName() string
-	// Run setup run
-	Run(commands []string) error
+	// Run setup run, the commands are abandoned when the ctx is done.
+	Run(ctx context.Context, commands []string) error
 }
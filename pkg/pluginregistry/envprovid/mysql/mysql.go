@@ -18,6 +18,8 @@
 package envmysql
 
 import (
+	"context"
+
 	"github.com/alsritter/middlebaby/pkg/pluginregistry"
 	"github.com/alsritter/middlebaby/pkg/storageprovider"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
@@ -53,10 +55,13 @@ func (*MySQLEnvPlugin) Name() string {
 	return "MySQLEnvPlugin"
 }
 
-func (m *MySQLEnvPlugin) Run(commands []string) error {
+func (m *MySQLEnvPlugin) Run(ctx context.Context, commands []string) error {
 	var errs error
 	for _, cmd := range commands {
-		_, err := m.run(cmd)
+		if err := ctx.Err(); err != nil {
+			return multierror.Append(errs, err)
+		}
+		_, err := m.run(ctx, cmd)
 		if err != nil {
 			errs = multierror.Append(errs, err)
 		}
@@ -64,8 +69,8 @@ func (m *MySQLEnvPlugin) Run(commands []string) error {
 	return errs
 }
 
-func (m *MySQLEnvPlugin) run(sql string) (result []map[string]interface{}, err error) {
-	err = m.db.Raw(sql).Find(&result).Error
+func (m *MySQLEnvPlugin) run(ctx context.Context, sql string) (result []map[string]interface{}, err error) {
+	err = m.db.WithContext(ctx).Raw(sql).Find(&result).Error
 	m.log.Trace(nil, "RUN MySQL: %s %v \n", sql, result)
 	return
 }
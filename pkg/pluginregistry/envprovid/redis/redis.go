@@ -18,6 +18,7 @@
 package envredis
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -51,10 +52,13 @@ func (*RedisEnvPlugin) GetTypeName() string {
 	return "redis"
 }
 
-func (r *RedisEnvPlugin) Run(commands []string) error {
+func (r *RedisEnvPlugin) Run(ctx context.Context, commands []string) error {
 	var errs error
 	for _, cmd := range commands {
-		_, err := r.run(cmd)
+		if err := ctx.Err(); err != nil {
+			return multierror.Append(errs, err)
+		}
+		_, err := r.run(ctx, cmd)
 		if err != nil {
 			errs = multierror.Append(errs, err)
 		}
@@ -62,7 +66,7 @@ func (r *RedisEnvPlugin) Run(commands []string) error {
 	return errs
 }
 
-func (r *RedisEnvPlugin) run(cmd string) (result interface{}, err error) {
+func (r *RedisEnvPlugin) run(ctx context.Context, cmd string) (result interface{}, err error) {
 	// formatting command.
 	cmdList := r.redisParse(cmd)
 	r.log.Trace(nil, "redis parse list: %v", cmdList)
@@ -70,25 +74,26 @@ func (r *RedisEnvPlugin) run(cmd string) (result interface{}, err error) {
 		return nil, nil
 	}
 
+	rc := r.rc.WithContext(ctx)
 	commandName := strings.ToLower(cmdList[0])
 	switch commandName {
 	case "get":
-		result, err = r.rc.Get(cmdList[1]).Result()
+		result, err = rc.Get(cmdList[1]).Result()
 	case "hgetall":
-		result, err = r.rc.HGetAll(cmdList[1]).Result()
+		result, err = rc.HGetAll(cmdList[1]).Result()
 	case "set":
 		if len(cmdList) < 3 {
 			r.log.Error(nil, "redis command error:", cmd)
 			return nil, fmt.Errorf("the redis command format is incorrect")
 		}
-		result, err = r.rc.Set(cmdList[1], strings.Join(cmdList[2:], ""), -1).Result()
+		result, err = rc.Set(cmdList[1], strings.Join(cmdList[2:], ""), -1).Result()
 	default:
 		var icmds []interface{}
 		for _, v := range cmdList {
 			icmds = append(icmds, v)
 		}
 		// TODO: the result returned by the do method is interface{}([]byte), inconsistent with the format expected by the default test case. so here need reflection conversion type.
-		result, err = r.rc.Do(icmds...).Result()
+		result, err = rc.Do(icmds...).Result()
 	}
 	if err == redis.Nil {
 		result, err = redis.Nil.Error(), nil
@@ -0,0 +1,37 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pluginregistry
+
+import (
+	"context"
+
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+)
+
+// HookPlugin run the beforeRequest and afterResponse hooks of the cases. (js, ...)
+type HookPlugin interface {
+	Plugin
+	// GetTypeName the plugin type
+	GetTypeName() string
+	// BeforeRequest returns the request modified by the commands, the vars are shared by the hooks of a run.
+	BeforeRequest(ctx context.Context, commands []string, req *mbcase.CaseRequest, vars *task.Vars) (*mbcase.CaseRequest, error)
+	// AfterResponse returns the response modified by the commands, the vars are shared by the hooks of a run.
+	AfterResponse(ctx context.Context, commands []string, req *mbcase.CaseRequest, resp *mbcase.Response,
+		vars *task.Vars) (*mbcase.Response, error)
+}
@@ -18,6 +18,7 @@
 package pluginregistry
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/alsritter/middlebaby/pkg/util/logger"
@@ -30,22 +31,59 @@ type Registry interface {
 
 	AssertPlugins() []AssertPlugin
 	RegisterAssertPlugin(...AssertPlugin)
+
+	HookPlugins() []HookPlugin
+	RegisterHookPlugin(...HookPlugin)
 }
 
+// the JavaScript engines of the js assert plugin.
+const (
+	JSEngineV8   = "v8"
+	JSEngineGoja = "goja"
+)
+
 // Config defines the config structure
-type Config struct{}
+type Config struct {
+	JS *JSConfig `yaml:"js"`
+}
+
+// JSConfig defines the config of the js assert and hook plugins
+type JSConfig struct {
+	// Engine runs the scripts: "v8" (needs cgo) or "goja" (pure Go).
+	Engine string `yaml:"engine"`
+	// Timeout the milliseconds a script may run, 0 disables it.
+	Timeout int64 `yaml:"timeout"`
+}
 
 // NewConfig is used to init config with default values
 func NewConfig() *Config {
-	return &Config{}
+	return &Config{
+		JS: &JSConfig{
+			Engine:  JSEngineV8,
+			Timeout: 5000,
+		},
+	}
 }
 
 // RegisterFlagsWithPrefix is used to register flags
 func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {
+	f.StringVar(&c.JS.Engine, prefix+"plugin.js.engine", c.JS.Engine, "the JavaScript engine of the js asserts and hooks(v8, goja)")
+	f.Int64Var(&c.JS.Timeout, prefix+"plugin.js.timeout", c.JS.Timeout, "the milliseconds a js assert or hook script may run, 0 disables it")
 }
 
 // Validate is used to validate config and returns error on failure
 func (c *Config) Validate() error {
+	if c.JS == nil {
+		return fmt.Errorf("js config cannot be nil")
+	}
+
+	if c.JS.Engine != JSEngineV8 && c.JS.Engine != JSEngineGoja {
+		return fmt.Errorf("unknown js engine: [%s]", c.JS.Engine)
+	}
+
+	if c.JS.Timeout < 0 {
+		return fmt.Errorf("js timeout cannot be negative")
+	}
 	return nil
 }
 
@@ -54,6 +92,7 @@ type BasicRegistry struct {
 
 	envPlugins    []EnvPlugin
 	assertPlugins []AssertPlugin
+	hookPlugins   []HookPlugin
 	lock          sync.Mutex
 
 	logger.Logger
@@ -63,6 +102,7 @@ func New(logger logger.Logger, cfg *Config) (Registry, error) {
 	service := &BasicRegistry{
 		envPlugins:    []EnvPlugin{},
 		assertPlugins: []AssertPlugin{},
+		hookPlugins:   []HookPlugin{},
 		cfg:           cfg,
 		Logger:        logger.NewLogger("pluginsRegistry"),
 	}
@@ -74,6 +114,11 @@ func (b *BasicRegistry) AssertPlugins() []AssertPlugin {
 	return b.assertPlugins
 }
 
+// HookPlugins implements Registry
+func (b *BasicRegistry) HookPlugins() []HookPlugin {
+	return b.hookPlugins
+}
+
 // EnvPlugins implements Registry
 func (b *BasicRegistry) EnvPlugins() []EnvPlugin {
 	return b.envPlugins
@@ -94,3 +139,11 @@ func (b *BasicRegistry) RegisterEnvPlugin(plugins ...EnvPlugin) {
 	b.envPlugins = append(b.envPlugins, plugins...)
 	return
 }
+
+// RegisterHookPlugin implements Registry
+func (b *BasicRegistry) RegisterHookPlugin(plugins ...HookPlugin) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.hookPlugins = append(b.hookPlugins, plugins...)
+	return
+}
@@ -0,0 +1,48 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package runhistory
+
+import (
+	"encoding/json"
+
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+)
+
+// diffResponses returns the differences between two actual responses,
+// a body holding a JSON document is compared as a JSON value.
+func diffResponses(from, to *mbcase.Response) []*assert.Change {
+	return assert.Diff(normalizeResponse(from), normalizeResponse(to))
+}
+
+// normalizeResponse returns the response as a generic JSON value.
+func normalizeResponse(r *mbcase.Response) interface{} {
+	if r == nil {
+		return nil
+	}
+
+	data := r.Data
+	if s, ok := data.(string); ok {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err == nil {
+			data = v
+		}
+	}
+
+	return map[string]interface{}{"statusCode": r.StatusCode, "header": r.Header, "data": data}
+}
@@ -0,0 +1,190 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package runhistory keeps the results of the runs in an embedded database, so that the history of a case
+// and its actual responses can be compared between runs.
+package runhistory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/cirunner"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// ErrDisabled the history directory is not configured.
+	ErrDisabled = errors.New("the run history is disabled")
+	// ErrNotFound the run or the case does not exist in the history.
+	ErrNotFound = errors.New("not found in the run history")
+)
+
+// Config defines the config structure
+type Config struct {
+	// Dir the directory of the history database, the history is disabled when it is empty.
+	Dir string `yaml:"dir"`
+	// MaxRuns the number of runs kept, the oldest ones are deleted.
+	MaxRuns int `yaml:"maxRuns"`
+}
+
+// NewConfig is used to init config with default values
+func NewConfig() *Config {
+	return &Config{
+		Dir:     "",
+		MaxRuns: 200,
+	}
+}
+
+// Validate is used to validate config and returns error on failure
+func (c *Config) Validate() error {
+	if c.MaxRuns < 1 {
+		return fmt.Errorf("[history] maxRuns must be at least 1")
+	}
+	return nil
+}
+
+// RegisterFlagsWithPrefix is used to register flags
+func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {
+	f.StringVar(&c.Dir, prefix+"history.dir", c.Dir, "the directory of the run history (e.g. .middlebaby/history), empty disables it")
+}
+
+// Run a run saved in the history.
+type Run struct {
+	ID        string             `json:"id"`
+	Selection cirunner.Selection `json:"selection"`
+	Status    string             `json:"status"`
+	StartTime time.Time          `json:"startTime"`
+	Duration  time.Duration      `json:"duration"`
+	Total     int                `json:"total"`
+	Passed    int                `json:"passed"`
+	Failed    int                `json:"failed"`
+	Skipped   int                `json:"skipped"`
+	// Quarantined the number of quarantined cases which failed.
+	Quarantined int `json:"quarantined"`
+	// Results the results of the cases, they are not listed with the runs.
+	Results []*task.RunTaskReply `json:"results,omitempty"`
+}
+
+// NewRun returns the run of the summary, the summary is nil when the run could not be executed.
+func NewRun(id string, sel cirunner.Selection, status string, summary *cirunner.Summary) *Run {
+	run := &Run{ID: id, Selection: sel, Status: status, StartTime: time.Now()}
+	if summary != nil {
+		run.StartTime = summary.StartTime
+		run.Duration = summary.Duration
+		run.Total, run.Passed, run.Failed, run.Skipped = summary.Total, summary.Passed, summary.Failed, summary.Skipped
+		run.Quarantined = summary.Quarantined
+		run.Results = summary.Results
+	}
+	return run
+}
+
+// CaseStatus defines the outcome of a case.
+type CaseStatus string
+
+// defines a set of known outcomes
+const (
+	CasePassed  CaseStatus = "passed"
+	CaseFailed  CaseStatus = "failed"
+	CaseSkipped CaseStatus = "skipped"
+)
+
+func caseStatus(r *task.RunTaskReply) CaseStatus {
+	switch {
+	case r.Skipped():
+		return CaseSkipped
+	case r.Passed():
+		return CasePassed
+	default:
+		return CaseFailed
+	}
+}
+
+// CaseRun the outcome of a case in a run.
+type CaseRun struct {
+	RunID        string        `json:"runId"`
+	StartTime    time.Time     `json:"startTime"` // the start time of the run.
+	Status       CaseStatus    `json:"status"`
+	Duration     time.Duration `json:"duration"`
+	FailedReason string        `json:"failedReason,omitempty"`
+}
+
+// CaseHistory the outcomes of a case, the latest first.
+type CaseHistory struct {
+	ItfName  string     `json:"itfName"`
+	CaseName string     `json:"caseName"`
+	Runs     []*CaseRun `json:"runs"`
+	// Flips the number of times the case went from passed to failed or back, a case flipping
+	// while nothing changed is flaky.
+	Flips int `json:"flips"`
+}
+
+// CaseDiff the differences of the actual response of a case between two runs.
+type CaseDiff struct {
+	ItfName  string           `json:"itfName"`
+	CaseName string           `json:"caseName"`
+	From     *CaseRun         `json:"from"`
+	To       *CaseRun         `json:"to"`
+	Changes  []*assert.Change `json:"changes"`
+}
+
+// Provider stores the runs.
+type Provider interface {
+	// Save add the run to the history, nothing is saved when the history is disabled.
+	Save(run *Run) error
+	// ListRuns returns the latest runs without their results, the latest first.
+	ListRuns(limit int) ([]*Run, error)
+	// GetRun returns the run with its results.
+	GetRun(id string) (*Run, error)
+	// GetCaseHistory returns the latest outcomes of the case.
+	GetCaseHistory(itfName, caseName string, limit int) (*CaseHistory, error)
+	// DiffCase compare the actual response of the case in the from run and in the to run.
+	DiffCase(itfName, caseName, fromRunID, toRunID string) (*CaseDiff, error)
+	Close() error
+}
+
+// Disabled returns a history which does not save anything.
+func Disabled() Provider {
+	return disabled{}
+}
+
+// New open the history database, the history is disabled when the directory is not configured.
+func New(log logger.Logger, cfg *Config) (Provider, error) {
+	l := log.NewLogger("history")
+	if cfg.Dir == "" {
+		l.Info(nil, "the run history is disabled")
+		return Disabled(), nil
+	}
+	return openStore(l, cfg)
+}
+
+type disabled struct{}
+
+func (disabled) Save(*Run) error              { return nil }
+func (disabled) ListRuns(int) ([]*Run, error) { return nil, ErrDisabled }
+func (disabled) GetRun(string) (*Run, error)  { return nil, ErrDisabled }
+func (disabled) Close() error                 { return nil }
+func (disabled) GetCaseHistory(string, string, int) (*CaseHistory, error) {
+	return nil, ErrDisabled
+}
+func (disabled) DiffCase(string, string, string, string) (*CaseDiff, error) {
+	return nil, ErrDisabled
+}
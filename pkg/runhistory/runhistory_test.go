@@ -0,0 +1,151 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package runhistory
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/cirunner"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func newStore(t *testing.T, maxRuns int) Provider {
+	cfg := NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.MaxRuns = maxRuns
+	h, err := New(logger.NewDefault("test"), cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+	return h
+}
+
+// saveRun save a run of the case a/a1 which responded the body.
+func saveRun(t *testing.T, h Provider, id string, start time.Time, passed bool, body string) {
+	r := &task.RunTaskReply{ItfName: "a", CaseName: "a1", Response: &mbcase.Response{StatusCode: 200, Data: body}}
+	if passed {
+		r.Status = 1
+	} else {
+		r.FailedReason = "assert failed"
+	}
+	summary := cirunner.NewSummary(start, []*task.RunTaskReply{r, {ItfName: "b", CaseName: "b1", SkipReason: "later"}})
+	if err := h.Save(NewRun(id, cirunner.Selection{}, "done", summary)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	h := newStore(t, 3)
+	start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	saveRun(t, h, "r1", start, true, `{"id": 1}`)
+	saveRun(t, h, "r2", start.Add(time.Hour), false, `{"id": 2, "items": [1]}`)
+	saveRun(t, h, "r3", start.Add(2*time.Hour), true, `{"id": 1}`)
+	saveRun(t, h, "r4", start.Add(3*time.Hour), true, `{"id": 1}`)
+
+	runs, err := h.ListRuns(0)
+	if err != nil {
+		t.Fatalf("ListRuns() error = %v", err)
+	}
+	var ids []string
+	for _, r := range runs {
+		ids = append(ids, r.ID)
+	}
+	// the oldest run has been deleted.
+	if want := "r4,r3,r2"; strings.Join(ids, ",") != want || runs[0].Results != nil {
+		t.Errorf("ListRuns() = %s, want %s without results", strings.Join(ids, ","), want)
+	}
+	if _, err := h.GetRun("r1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRun() error = %v, want %v", err, ErrNotFound)
+	}
+
+	run, err := h.GetRun("r2")
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if run.Total != 2 || run.Failed != 1 || run.Skipped != 1 || len(run.Results) != 2 || run.Results[1].CaseName != "b1" {
+		t.Errorf("GetRun() = %+v", run)
+	}
+
+	ch, err := h.GetCaseHistory("a", "a1", 2)
+	if err != nil {
+		t.Fatalf("GetCaseHistory() error = %v", err)
+	}
+	if len(ch.Runs) != 2 || ch.Runs[0].RunID != "r4" || ch.Runs[1].Status != CasePassed {
+		t.Errorf("GetCaseHistory() runs = %+v", ch.Runs)
+	}
+	if ch, _ = h.GetCaseHistory("a", "a1", 0); len(ch.Runs) != 3 || ch.Flips != 1 {
+		t.Errorf("GetCaseHistory() runs = %d, flips = %d", len(ch.Runs), ch.Flips)
+	}
+	if ch, _ = h.GetCaseHistory("a", "a", 0); len(ch.Runs) != 0 {
+		t.Errorf("GetCaseHistory() returns the runs of another case: %+v", ch.Runs)
+	}
+
+	d, err := h.DiffCase("a", "a1", "r2", "r3")
+	if err != nil {
+		t.Fatalf("DiffCase() error = %v", err)
+	}
+	if d.From.Status != CaseFailed || d.To.Status != CasePassed || len(d.Changes) != 2 ||
+		d.Changes[0].Path != "/data/id" || d.Changes[1].Type != assert.ChangeRemoved {
+		t.Errorf("DiffCase() = %+v %+v", d, d.Changes)
+	}
+	if _, err := h.DiffCase("b", "b2", "r2", "r3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DiffCase() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	// the history is disabled by default.
+	h, err := New(logger.NewDefault("test"), NewConfig())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := h.Save(&Run{}); err != nil {
+		t.Errorf("Save() error = %v", err)
+	}
+	if _, err := h.ListRuns(0); !errors.Is(err, ErrDisabled) {
+		t.Errorf("ListRuns() error = %v, want %v", err, ErrDisabled)
+	}
+}
+
+func Test_diffResponses(t *testing.T) {
+	from := &mbcase.Response{StatusCode: 200, Header: map[string]string{"X-Id": "1"},
+		Data: `{"name": "a", "tags": ["x", "y"], "a/b": 1}`}
+	to := &mbcase.Response{StatusCode: 500, Header: map[string]string{"X-Trace": "2"},
+		Data: `{"name": "b", "tags": ["x"], "a/b": 1, "error": "oops"}`}
+
+	var got []string
+	for _, c := range diffResponses(from, to) {
+		got = append(got, string(c.Type)+" "+c.Path)
+	}
+	want := "added /data/error,changed /data/name,removed /data/tags/1,removed /header/X-Id,added /header/X-Trace,changed /statusCode"
+	if strings.Join(got, ",") != want {
+		t.Errorf("diffResponses() = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	if changes := diffResponses(&mbcase.Response{Data: "plain"}, &mbcase.Response{Data: "text"}); len(changes) != 1 ||
+		changes[0].Path != "/data" || changes[0].From != "plain" {
+		t.Errorf("diffResponses() of text bodies = %+v", changes)
+	}
+}
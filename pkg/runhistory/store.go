@@ -0,0 +1,328 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package runhistory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// The layout of the database:
+//
+//	runs:    <run key> -> the run without its results
+//	ids:     <run id> -> <run key>
+//	results: <run key> (bucket): <sequence> -> the result of a case
+//	cases:   <itf name> 0x00 <case name> 0x00 <run key> -> <sequence>
+//
+// The run key starts with the start time of the run, so that the keys are sorted by time.
+var (
+	runsBucket    = []byte("runs")
+	idsBucket     = []byte("ids")
+	resultsBucket = []byte("results")
+	casesBucket   = []byte("cases")
+)
+
+type store struct {
+	logger.Logger
+	cfg *Config
+	db  *bolt.DB
+}
+
+func openStore(log logger.Logger, cfg *Config) (*store, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create the history directory [%s] failed: %v", cfg.Dir, err)
+	}
+
+	file := filepath.Join(cfg.Dir, "history.db")
+	db, err := bolt.Open(file, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open the run history [%s] failed (is it used by another process?): %v", file, err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{runsBucket, idsBucket, resultsBucket, casesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initialize the run history [%s] failed: %v", file, err)
+	}
+
+	log.Info(nil, "run history: %s", file)
+	return &store{Logger: log, cfg: cfg, db: db}, nil
+}
+
+func runKey(run *Run) []byte {
+	key := make([]byte, 8, 8+len(run.ID))
+	binary.BigEndian.PutUint64(key, uint64(run.StartTime.UnixNano()))
+	return append(key, run.ID...)
+}
+
+func casePrefix(itfName, caseName string) []byte {
+	return []byte(itfName + "\x00" + caseName + "\x00")
+}
+
+func sequenceKey(i int) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, uint32(i))
+	return key
+}
+
+// Save implements Provider
+func (s *store) Save(run *Run) error {
+	if run.ID == "" {
+		run.ID = uuid.New().String()
+	}
+
+	meta := *run
+	meta.Results = nil
+	data, err := json.Marshal(&meta)
+	if err != nil {
+		return err
+	}
+
+	key := runKey(run)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(idsBucket).Get([]byte(run.ID)) != nil {
+			return fmt.Errorf("the run [%s] is already in the history", run.ID)
+		}
+		if err := tx.Bucket(runsBucket).Put(key, data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(idsBucket).Put([]byte(run.ID), key); err != nil {
+			return err
+		}
+
+		results, err := tx.Bucket(resultsBucket).CreateBucket(key)
+		if err != nil {
+			return err
+		}
+		for i, r := range run.Results {
+			data, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			if err := results.Put(sequenceKey(i), data); err != nil {
+				return err
+			}
+			if err := tx.Bucket(casesBucket).Put(append(casePrefix(r.ItfName, r.CaseName), key...), sequenceKey(i)); err != nil {
+				return err
+			}
+		}
+		return s.prune(tx)
+	})
+}
+
+// prune delete the oldest runs beyond the maximum number of runs.
+func (s *store) prune(tx *bolt.Tx) error {
+	runs := tx.Bucket(runsBucket)
+	extra := -s.cfg.MaxRuns
+	c := runs.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		extra++
+	}
+	for ; extra > 0; extra-- {
+		key, data := runs.Cursor().First()
+		var run Run
+		if err := json.Unmarshal(data, &run); err != nil {
+			return err
+		}
+
+		if results := tx.Bucket(resultsBucket).Bucket(key); results != nil {
+			if err := results.ForEach(func(_, v []byte) error {
+				var r task.RunTaskReply
+				if err := json.Unmarshal(v, &r); err != nil {
+					return err
+				}
+				return tx.Bucket(casesBucket).Delete(append(casePrefix(r.ItfName, r.CaseName), key...))
+			}); err != nil {
+				return err
+			}
+			if err := tx.Bucket(resultsBucket).DeleteBucket(key); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(idsBucket).Delete([]byte(run.ID)); err != nil {
+			return err
+		}
+		if err := runs.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListRuns implements Provider
+func (s *store) ListRuns(limit int) ([]*Run, error) {
+	var runs []*Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(runsBucket).Cursor()
+		for k, v := c.Last(); k != nil && (limit <= 0 || len(runs) < limit); k, v = c.Prev() {
+			run := new(Run)
+			if err := json.Unmarshal(v, run); err != nil {
+				return err
+			}
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// GetRun implements Provider
+func (s *store) GetRun(id string) (*Run, error) {
+	var run *Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(idsBucket).Get([]byte(id))
+		if key == nil {
+			return fmt.Errorf("the run [%s] is %w", id, ErrNotFound)
+		}
+
+		run = new(Run)
+		if err := json.Unmarshal(tx.Bucket(runsBucket).Get(key), run); err != nil {
+			return err
+		}
+		results := tx.Bucket(resultsBucket).Bucket(key)
+		if results == nil {
+			return nil
+		}
+		return results.ForEach(func(_, v []byte) error {
+			r := new(task.RunTaskReply)
+			if err := json.Unmarshal(v, r); err != nil {
+				return err
+			}
+			run.Results = append(run.Results, r)
+			return nil
+		})
+	})
+	return run, err
+}
+
+// GetCaseHistory implements Provider
+func (s *store) GetCaseHistory(itfName, caseName string, limit int) (*CaseHistory, error) {
+	h := &CaseHistory{ItfName: itfName, CaseName: caseName}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := casePrefix(itfName, caseName)
+		// the first key after the prefix: the separator 0x00 is replaced by 0x01.
+		end := append(append([]byte(nil), prefix[:len(prefix)-1]...), 0x01)
+
+		c := tx.Bucket(casesBucket).Cursor()
+		k, v := c.Seek(end)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix) && (limit <= 0 || len(h.Runs) < limit); k, v = c.Prev() {
+			cr, _, err := caseRun(tx, k[len(prefix):], v)
+			if err != nil {
+				return err
+			}
+			h.Runs = append(h.Runs, cr)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var last CaseStatus
+	for _, r := range h.Runs {
+		if r.Status == CaseSkipped {
+			continue
+		}
+		if last != "" && r.Status != last {
+			h.Flips++
+		}
+		last = r.Status
+	}
+	return h, nil
+}
+
+// DiffCase implements Provider
+func (s *store) DiffCase(itfName, caseName, fromRunID, toRunID string) (*CaseDiff, error) {
+	d := &CaseDiff{ItfName: itfName, CaseName: caseName}
+	var from, to *task.RunTaskReply
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		if d.From, from, err = s.caseResult(tx, itfName, caseName, fromRunID); err != nil {
+			return err
+		}
+		d.To, to, err = s.caseResult(tx, itfName, caseName, toRunID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.Changes = diffResponses(from.Response, to.Response)
+	return d, nil
+}
+
+func (s *store) caseResult(tx *bolt.Tx, itfName, caseName, runID string) (*CaseRun, *task.RunTaskReply, error) {
+	key := tx.Bucket(idsBucket).Get([]byte(runID))
+	if key == nil {
+		return nil, nil, fmt.Errorf("the run [%s] is %w", runID, ErrNotFound)
+	}
+	seq := tx.Bucket(casesBucket).Get(append(casePrefix(itfName, caseName), key...))
+	if seq == nil {
+		return nil, nil, fmt.Errorf("the case [%s/%s] of the run [%s] is %w", itfName, caseName, runID, ErrNotFound)
+	}
+	return caseRun(tx, key, seq)
+}
+
+// caseRun returns the result of the case at the sequence of the run.
+func caseRun(tx *bolt.Tx, key, seq []byte) (*CaseRun, *task.RunTaskReply, error) {
+	var run Run
+	if err := json.Unmarshal(tx.Bucket(runsBucket).Get(key), &run); err != nil {
+		return nil, nil, err
+	}
+	results := tx.Bucket(resultsBucket).Bucket(key)
+	if results == nil {
+		return nil, nil, fmt.Errorf("the results of the run [%s] are %w", run.ID, ErrNotFound)
+	}
+
+	r := new(task.RunTaskReply)
+	if err := json.Unmarshal(results.Get(seq), r); err != nil {
+		return nil, nil, err
+	}
+	return &CaseRun{
+		RunID:        run.ID,
+		StartTime:    run.StartTime,
+		Status:       caseStatus(r),
+		Duration:     r.Duration,
+		FailedReason: r.FailedReason,
+	}, r, nil
+}
+
+// Close implements Provider
+func (s *store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,267 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package runmanager runs batches of cases in the background for the web service,
+// their progress is pushed to the websocket clients.
+package runmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/messagepush"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/cirunner"
+	"github.com/alsritter/middlebaby/pkg/runhistory"
+	"github.com/alsritter/middlebaby/pkg/types/msgpush"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/alsritter/middlebaby/pkg/util/mbcontext"
+	"github.com/alsritter/middlebaby/pkg/util/tagexpr"
+	"github.com/google/uuid"
+)
+
+// maxFinishedRuns the number of finished runs kept, the oldest ones are dropped.
+const maxFinishedRuns = 100
+
+// ErrRunNotFound the run does not exist or has been dropped.
+var ErrRunNotFound = errors.New("run not found")
+
+// Status defines the status of a run.
+type Status string
+
+// defines a set of known statuses
+const (
+	StatusRunning  Status = "running"
+	StatusPassed   Status = "passed"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+	// StatusError the run could not be executed, e.g. the selection matches no interface.
+	StatusError Status = "error"
+	// StatusInterrupted the run of `middlebaby run` has been stopped (e.g. Ctrl-C), only the executed cases are saved.
+	StatusInterrupted Status = "interrupted"
+)
+
+// EventRunFinished the run is over, the progress holds its status and summary.
+const EventRunFinished cirunner.EventType = "runFinished"
+
+// Run a batch of cases.
+type Run struct {
+	ID        string             `json:"id"`
+	Selection cirunner.Selection `json:"selection"`
+	Status    Status             `json:"status"`
+	StartTime time.Time          `json:"startTime"`
+	EndTime   time.Time          `json:"endTime"`
+	// Total the number of selected cases, known once the run has started.
+	Total int `json:"total"`
+	// Results the results of the finished cases, in completion order.
+	Results []*task.RunTaskReply `json:"results"`
+	// Summary the summary of the run once it is finished.
+	Summary *cirunner.Summary `json:"summary,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Progress the content of the "run" messages pushed to the websocket clients.
+type Progress struct {
+	RunID  string `json:"runId"`
+	Status Status `json:"status"`
+	cirunner.Event
+	Summary *cirunner.Summary `json:"summary,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Provider manages the background runs.
+type Provider interface {
+	// StartRun start running the selected cases in the background.
+	StartRun(sel cirunner.Selection) (*Run, error)
+	// GetRun returns a snapshot of the run.
+	GetRun(id string) (*Run, error)
+	// CancelRun stop a running run, the cases being executed are interrupted.
+	CancelRun(id string) error
+}
+
+type runManager struct {
+	logger.Logger
+	// ctx the lifetime of the service, the runs are canceled when it is done.
+	ctx     *mbcontext.Context
+	runner  cirunner.Provider
+	history runhistory.Provider
+	msgPush messagepush.Provider
+
+	lock sync.Mutex
+	runs map[string]*runState
+	// finished the ids of the finished runs, the oldest first.
+	finished []string
+	msgId    uint64
+}
+
+type runState struct {
+	run    Run
+	cancel context.CancelFunc
+}
+
+// New returns the run manager, the runs in progress are canceled when the ctx is done
+// and the ctx waits for them (ctx.Wait), so that they are saved into the history before it is closed.
+func New(ctx *mbcontext.Context, log logger.Logger, runner cirunner.Provider, history runhistory.Provider,
+	msgPush messagepush.Provider) Provider {
+	return &runManager{
+		Logger:  log.NewLogger("run-manager"),
+		ctx:     ctx,
+		runner:  runner,
+		history: history,
+		msgPush: msgPush,
+		runs:    make(map[string]*runState),
+	}
+}
+
+// StartRun implements Provider
+func (m *runManager) StartRun(sel cirunner.Selection) (*Run, error) {
+	if _, err := tagexpr.Parse(sel.Filter); err != nil {
+		return nil, err
+	}
+
+	if m.ctx.Err() != nil {
+		return nil, fmt.Errorf("the service is stopping")
+	}
+
+	// the run outlives the request which starts it, not the service.
+	ctx, cancel := context.WithCancel(m.ctx)
+	s := &runState{
+		run: Run{
+			ID:        uuid.New().String(),
+			Selection: sel,
+			Status:    StatusRunning,
+			StartTime: time.Now(),
+		},
+		cancel: cancel,
+	}
+
+	m.lock.Lock()
+	m.runs[s.run.ID] = s
+	snapshot := s.snapshot()
+	m.lock.Unlock()
+
+	m.Info(map[string]interface{}{"runId": s.run.ID, "selection": fmt.Sprintf("%+v", sel)}, "run started")
+	m.ctx.AddService(1)
+	go func() {
+		defer m.ctx.DoneService()
+		m.execute(ctx, s)
+	}()
+	return snapshot, nil
+}
+
+// GetRun implements Provider
+func (m *runManager) GetRun(id string) (*Run, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s, ok := m.runs[id]
+	if !ok {
+		return nil, ErrRunNotFound
+	}
+	return s.snapshot(), nil
+}
+
+// CancelRun implements Provider
+func (m *runManager) CancelRun(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s, ok := m.runs[id]
+	if !ok {
+		return ErrRunNotFound
+	}
+	if s.run.Status != StatusRunning {
+		return fmt.Errorf("the run [%s] is already %s", id, s.run.Status)
+	}
+	s.cancel()
+	return nil
+}
+
+func (m *runManager) execute(ctx context.Context, s *runState) {
+	defer s.cancel()
+	summary, err := m.runner.Run(ctx, s.run.Selection, func(e cirunner.Event) {
+		m.lock.Lock()
+		switch {
+		case e.Type == cirunner.EventRunStarted:
+			s.run.Total = e.Total
+		case e.Result != nil:
+			s.run.Results = append(s.run.Results, e.Result)
+		}
+		m.lock.Unlock()
+		m.push(Progress{RunID: s.run.ID, Status: StatusRunning, Event: e})
+	})
+
+	m.lock.Lock()
+	s.run.EndTime = time.Now()
+	s.run.Summary = summary
+	switch {
+	case ctx.Err() != nil:
+		s.run.Status = StatusCanceled
+	case err != nil:
+		s.run.Status, s.run.Error = StatusError, err.Error()
+	case summary.Success():
+		s.run.Status = StatusPassed
+	default:
+		s.run.Status = StatusFailed
+	}
+	progress := Progress{RunID: s.run.ID, Status: s.run.Status, Event: cirunner.Event{Type: EventRunFinished},
+		Summary: summary, Error: s.run.Error}
+	m.finish(s.run.ID)
+	m.lock.Unlock()
+
+	if err := m.history.Save(runhistory.NewRun(s.run.ID, s.run.Selection, string(progress.Status), summary)); err != nil {
+		m.Error(map[string]interface{}{"runId": s.run.ID}, "save the run into the history failed: [%v]", err)
+	}
+
+	m.Info(map[string]interface{}{"runId": s.run.ID, "status": progress.Status}, "run finished")
+	m.push(progress)
+}
+
+// finish drop the oldest finished runs, the caller must hold the lock.
+func (m *runManager) finish(id string) {
+	m.finished = append(m.finished, id)
+	for len(m.finished) > maxFinishedRuns {
+		delete(m.runs, m.finished[0])
+		m.finished = m.finished[1:]
+	}
+}
+
+func (m *runManager) push(p Progress) {
+	content, err := json.Marshal(p)
+	if err != nil {
+		m.Error(nil, "marshal run progress failed: [%v]", err)
+		return
+	}
+	if err := m.msgPush.SendMessage(msgpush.PushMessage{
+		ID:          atomic.AddUint64(&m.msgId, 1),
+		Extra:       time.Now().Format("2006-01-02T15:04:05Z07:00"),
+		MessageType: msgpush.Run,
+		Content:     string(content),
+	}); err != nil {
+		m.Error(nil, "message push failed: [%v]", err)
+	}
+}
+
+// snapshot copy the run, the caller must hold the lock.
+func (s *runState) snapshot() *Run {
+	run := s.run
+	run.Results = append([]*task.RunTaskReply(nil), s.run.Results...)
+	return &run
+}
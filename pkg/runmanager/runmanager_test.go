@@ -0,0 +1,240 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package runmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/messagepush"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/cirunner"
+	"github.com/alsritter/middlebaby/pkg/runhistory"
+	"github.com/alsritter/middlebaby/pkg/types/msgpush"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/alsritter/middlebaby/pkg/util/mbcontext"
+)
+
+// fakeRunner runs a passed case, then a failed one, and blocks until the run is canceled when block is set.
+type fakeRunner struct {
+	block bool
+}
+
+func (f *fakeRunner) Run(ctx context.Context, sel cirunner.Selection, progress cirunner.ProgressFunc) (*cirunner.Summary, error) {
+	if sel.ItfName == "unknown" {
+		return nil, errors.New("no interface matches the selection")
+	}
+
+	progress(cirunner.Event{Type: cirunner.EventRunStarted, Total: 2})
+	summary := &cirunner.Summary{StartTime: time.Now()}
+	for _, r := range []*task.RunTaskReply{
+		{ItfName: "a", CaseName: "a1", Status: 1},
+		{ItfName: "a", CaseName: "a2", FailedReason: "assert failed"},
+	} {
+		if f.block {
+			<-ctx.Done()
+			return summary, ctx.Err()
+		}
+
+		progress(cirunner.Event{Type: cirunner.EventCaseStarted, ItfName: r.ItfName, CaseName: r.CaseName})
+		event := cirunner.Event{Type: cirunner.EventCasePassed, ItfName: r.ItfName, CaseName: r.CaseName, Result: r}
+		summary.Total++
+		if r.Passed() {
+			summary.Passed++
+		} else {
+			event.Type = cirunner.EventCaseFailed
+			summary.Failed++
+		}
+		summary.Results = append(summary.Results, r)
+		progress(event)
+	}
+	return summary, nil
+}
+
+type fakeHistory struct {
+	runhistory.Provider
+	lock  sync.Mutex
+	saved []*runhistory.Run
+}
+
+func (f *fakeHistory) Save(run *runhistory.Run) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.saved = append(f.saved, run)
+	return nil
+}
+
+type fakeMsgPush struct {
+	messagepush.Provider
+	lock     sync.Mutex
+	progress []Progress
+}
+
+func (f *fakeMsgPush) SendMessage(m msgpush.PushMessage) error {
+	var p Progress
+	if err := json.Unmarshal([]byte(m.Content), &p); err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.progress = append(f.progress, p)
+	return nil
+}
+
+func (f *fakeMsgPush) events() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var events []string
+	for _, p := range f.progress {
+		events = append(events, string(p.Type)+" "+p.CaseName)
+	}
+	return events
+}
+
+// wait until the run is finished.
+func wait(t *testing.T, m Provider, id string) *Run {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		run, err := m.GetRun(id)
+		if err != nil {
+			t.Fatalf("GetRun() error = %v", err)
+		}
+		if run.Status != StatusRunning {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("the run [%s] is not finished", id)
+	return nil
+}
+
+func Test_runManager(t *testing.T) {
+	tests := []struct {
+		name       string
+		sel        cirunner.Selection
+		block      bool
+		wantStatus Status
+		wantEvents []string
+	}{
+		{
+			name:       "finished",
+			wantStatus: StatusFailed,
+			wantEvents: []string{"runStarted ", "caseStarted a1", "casePassed a1", "caseStarted a2", "caseFailed a2", "runFinished "},
+		},
+		{
+			name:       "canceled",
+			block:      true,
+			wantStatus: StatusCanceled,
+			wantEvents: []string{"runStarted ", "runFinished "},
+		},
+		{
+			name:       "error",
+			sel:        cirunner.Selection{ItfName: "unknown"},
+			wantStatus: StatusError,
+			wantEvents: []string{"runFinished "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgPush, history := &fakeMsgPush{}, &fakeHistory{}
+			m := New(mbcontext.NewContext(context.Background()), logger.NewDefault("test"), &fakeRunner{block: tt.block}, history, msgPush)
+			run, err := m.StartRun(tt.sel)
+			if err != nil {
+				t.Fatalf("StartRun() error = %v", err)
+			}
+			if tt.block {
+				if err := m.CancelRun(run.ID); err != nil {
+					t.Fatalf("CancelRun() error = %v", err)
+				}
+			}
+
+			run = wait(t, m, run.ID)
+			if run.Status != tt.wantStatus {
+				t.Errorf("GetRun() status = %s, want %s", run.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == StatusFailed && (run.Total != 2 || len(run.Results) != 2 || run.Summary.Failed != 1) {
+				t.Errorf("GetRun() total = %d, results = %d, summary = %+v", run.Total, len(run.Results), run.Summary)
+			}
+
+			// the runFinished message is pushed right after the status is updated.
+			events := msgPush.events()
+			for deadline := time.Now().Add(time.Second); len(events) < len(tt.wantEvents) && time.Now().Before(deadline); {
+				time.Sleep(10 * time.Millisecond)
+				events = msgPush.events()
+			}
+			if len(events) != len(tt.wantEvents) {
+				t.Fatalf("pushed events = %v, want %v", events, tt.wantEvents)
+			}
+			for i := range events {
+				if events[i] != tt.wantEvents[i] {
+					t.Errorf("pushed events = %v, want %v", events, tt.wantEvents)
+				}
+			}
+			if err := m.CancelRun(run.ID); err == nil {
+				t.Errorf("CancelRun() a finished run succeeded")
+			}
+
+			history.lock.Lock()
+			defer history.lock.Unlock()
+			if len(history.saved) != 1 || history.saved[0].ID != run.ID || history.saved[0].Status != string(tt.wantStatus) {
+				t.Errorf("the run is not saved into the history: %+v", history.saved)
+			}
+		})
+	}
+}
+
+func Test_runManager_Shutdown(t *testing.T) {
+	ctx := mbcontext.NewContext(context.Background())
+	history := &fakeHistory{}
+	m := New(ctx, logger.NewDefault("test"), &fakeRunner{block: true}, history, &fakeMsgPush{})
+	run, err := m.StartRun(cirunner.Selection{})
+	if err != nil {
+		t.Fatalf("StartRun() error = %v", err)
+	}
+
+	// stopping the service cancels the run, and waits until it is saved into the history.
+	ctx.CancelFunc()
+	ctx.Wait()
+
+	history.lock.Lock()
+	if len(history.saved) != 1 || history.saved[0].ID != run.ID || history.saved[0].Status != string(StatusCanceled) {
+		t.Errorf("the run is not saved into the history: %+v", history.saved)
+	}
+	history.lock.Unlock()
+
+	if _, err := m.StartRun(cirunner.Selection{}); err == nil {
+		t.Errorf("StartRun() succeeded after the service stopped")
+	}
+}
+
+func Test_runManager_Errors(t *testing.T) {
+	m := New(mbcontext.NewContext(context.Background()), logger.NewDefault("test"), &fakeRunner{}, &fakeHistory{}, &fakeMsgPush{})
+	if _, err := m.StartRun(cirunner.Selection{Filter: "smoke &&"}); err == nil {
+		t.Errorf("StartRun() an invalid filter succeeded")
+	}
+	if _, err := m.GetRun("missing"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("GetRun() error = %v, want %v", err, ErrRunNotFound)
+	}
+	if err := m.CancelRun("missing"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("CancelRun() error = %v, want %v", err, ErrRunNotFound)
+	}
+}
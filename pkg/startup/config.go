@@ -24,7 +24,9 @@ import (
 	"github.com/alsritter/middlebaby/pkg/messagepush"
 	"github.com/alsritter/middlebaby/pkg/mockserver"
 	"github.com/alsritter/middlebaby/pkg/pluginregistry"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/cirunner"
 	"github.com/alsritter/middlebaby/pkg/protomanager"
+	"github.com/alsritter/middlebaby/pkg/runhistory"
 	"github.com/alsritter/middlebaby/pkg/storageprovider"
 	"github.com/alsritter/middlebaby/pkg/targetprocess"
 	"github.com/alsritter/middlebaby/pkg/taskserver"
@@ -47,6 +49,8 @@ type Config struct {
 	WebService     *web.Config             `yaml:"web"`
 	CaptureServer  *captureserver.Config   `yaml:"capture"`
 	MessagePush    *messagepush.Config     `yaml:"msgPush"`
+	Runner         *cirunner.Config        `yaml:"runner"`
+	History        *runhistory.Config      `yaml:"history"`
 }
 
 func NewConfig() *Config {
@@ -63,6 +67,8 @@ func NewConfig() *Config {
 		WebService:     web.NewConfig(),
 		CaptureServer:  captureserver.NewConfig(),
 		MessagePush:    messagepush.NewConfig(),
+		Runner:         cirunner.NewConfig(),
+		History:        runhistory.NewConfig(),
 	}
 }
 
@@ -80,6 +86,8 @@ func (c *Config) Validate() error {
 		c.WebService,
 		c.CaptureServer,
 		c.MessagePush,
+		c.Runner,
+		c.History,
 	)
 }
 
@@ -96,4 +104,6 @@ func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {
 	c.WebService.RegisterFlagsWithPrefix(prefix, f)
 	c.CaptureServer.RegisterFlagsWithPrefix(prefix, f)
 	c.MessagePush.RegisterFlagsWithPrefix(prefix, f)
+	c.Runner.RegisterFlagsWithPrefix(prefix, f)
+	c.History.RegisterFlagsWithPrefix(prefix, f)
 }
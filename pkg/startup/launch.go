@@ -20,50 +20,61 @@ package startup
 import (
 	"github.com/alsritter/middlebaby/pkg/captureserver"
 	"github.com/alsritter/middlebaby/pkg/caseprovider"
+	"github.com/alsritter/middlebaby/pkg/casevalidator"
 	"github.com/alsritter/middlebaby/pkg/messagepush"
 	"github.com/alsritter/middlebaby/pkg/pluginregistry"
 	"github.com/alsritter/middlebaby/pkg/pluginregistry/assertprovid/javascript"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/assertprovid/jsonpath"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/assertprovid/jsonschema"
 	"github.com/alsritter/middlebaby/pkg/pluginregistry/assertprovid/mysql"
 	"github.com/alsritter/middlebaby/pkg/pluginregistry/assertprovid/redis"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/cirunner"
 	envmysql "github.com/alsritter/middlebaby/pkg/pluginregistry/envprovid/mysql"
 	envredis "github.com/alsritter/middlebaby/pkg/pluginregistry/envprovid/redis"
 	"github.com/alsritter/middlebaby/pkg/protomanager"
+	"github.com/alsritter/middlebaby/pkg/runhistory"
+	"github.com/alsritter/middlebaby/pkg/runmanager"
 	"github.com/alsritter/middlebaby/web"
 
 	"github.com/alsritter/middlebaby/pkg/apimanager"
 	"github.com/alsritter/middlebaby/pkg/storageprovider"
 	"github.com/alsritter/middlebaby/pkg/targetprocess"
 	"github.com/alsritter/middlebaby/pkg/taskserver"
+	"github.com/alsritter/middlebaby/pkg/taskserver/report"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
 	"github.com/alsritter/middlebaby/pkg/util/mbcontext"
 )
 
-func Startup(ctx *mbcontext.Context, cfg *Config, log logger.Logger, loader caseprovider.CaseLoader) error {
-	pluginRegistry, err := pluginregistry.New(log, cfg.PluginRegistry)
+// services the service graph shared by every startup mode.
+type services struct {
+	caseProvider  caseprovider.Provider
+	captureServer captureserver.Provider
+	taskServer    taskserver.Provider
+	targetProcess targetprocess.Provider
+	webService    web.Provider
+	runner        cirunner.Provider
+	history       runhistory.Provider
+}
+
+// newServices creates the services, with optionalHistory a history which cannot be opened
+// (e.g. locked by a running web service) is disabled instead of failing.
+func newServices(ctx *mbcontext.Context, cfg *Config, log logger.Logger, loader caseprovider.CaseLoader, optionalHistory bool) (*services, error) {
+	pluginRegistry, err := newPluginRegistry(cfg, log)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	storageProvider := storageprovider.New(log, cfg.Storage)
-	pluginRegistry.RegisterEnvPlugin(
-		envmysql.New(storageProvider, log),
-		envredis.New(storageProvider, log))
-	pluginRegistry.RegisterAssertPlugin(
-		mysql.New(storageProvider, log),
-		redis.New(storageProvider, log),
-		javascript.New(log))
-
 	log.Info(nil, "start loading case...")
 	caseProvider, err := caseprovider.New(log, cfg.CaseProvider, loader)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	log.Info(nil, "loaded case successfully")
 
 	log.Info(nil, "start loading proto file...")
 	protoProvider, err := protomanager.New(log, cfg.ProtoManager)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	log.Info(nil, "loaded proto file successfully")
 
@@ -74,30 +85,158 @@ func Startup(ctx *mbcontext.Context, cfg *Config, log logger.Logger, loader case
 
 	log.Info(nil, "* start to start messagepush server")
 	if err = msgPush.Start(ctx); err != nil {
-		return err
+		return nil, err
 	}
 
 	captureServer := captureserver.New(log, cfg.CaptureServer, protoProvider, msgPush)
 	taskServer := taskserver.New(log, cfg.TaskService, caseProvider, protoProvider, apiManager, pluginRegistry)
 	targetProcess := targetprocess.New(log, cfg.TargetProcess, captureServer)
 
-	webService := web.New(log, cfg.WebService, apiManager, caseProvider, protoProvider, taskServer, targetProcess)
+	runner := cirunner.New(log, cfg.Runner, cfg.TaskService.TargetServeAdder, caseProvider, taskServer)
+
+	history, err := runhistory.New(log, cfg.History)
+	if err != nil {
+		if !optionalHistory {
+			return nil, err
+		}
+		log.Error(nil, "%s, the run is not saved into the history", err)
+		history = runhistory.Disabled()
+	}
+	runManager := runmanager.New(ctx, log, runner, history, msgPush)
+
+	webService := web.New(log, cfg.WebService, apiManager, caseProvider, protoProvider, taskServer, targetProcess,
+		runner, runManager, history)
+
+	return &services{
+		caseProvider:  caseProvider,
+		captureServer: captureServer,
+		taskServer:    taskServer,
+		targetProcess: targetProcess,
+		webService:    webService,
+		runner:        runner,
+		history:       history,
+	}, nil
+}
+
+// newPluginRegistry returns the registry of the built-in plugins.
+func newPluginRegistry(cfg *Config, log logger.Logger) (pluginregistry.Registry, error) {
+	pluginRegistry, err := pluginregistry.New(log, cfg.PluginRegistry)
+	if err != nil {
+		return nil, err
+	}
+
+	storageProvider := storageprovider.New(log, cfg.Storage)
+	pluginRegistry.RegisterEnvPlugin(
+		envmysql.New(storageProvider, log),
+		envredis.New(storageProvider, log))
+	pluginRegistry.RegisterAssertPlugin(
+		mysql.New(storageProvider, log),
+		redis.New(storageProvider, log),
+		javascript.New(log, cfg.PluginRegistry.JS),
+		jsonpath.New(log),
+		jsonschema.New(log))
+	pluginRegistry.RegisterHookPlugin(
+		javascript.NewHook(log, cfg.PluginRegistry.JS))
+	return pluginRegistry, nil
+}
+
+// Startup start all services and block until they stop.
+func Startup(ctx *mbcontext.Context, cfg *Config, log logger.Logger, loader caseprovider.CaseLoader) error {
+	s, err := newServices(ctx, cfg, log, loader, false)
+	if err != nil {
+		return err
+	}
 
 	log.Info(nil, "* start to start captureServer")
-	if err = captureServer.Start(ctx); err != nil {
+	if err = s.captureServer.Start(ctx); err != nil {
 		return err
 	}
 
 	log.Info(nil, "* start to start webService")
-	if err = webService.Start(ctx); err != nil {
+	if err = s.webService.Start(ctx); err != nil {
 		return err
 	}
 
 	log.Info(nil, "* start to start targetProcess")
-	if err = targetProcess.Start(ctx); err != nil {
+	if err = s.targetProcess.Start(ctx); err != nil {
 		return err
 	}
 
 	ctx.Wait()
-	return nil
+	return s.history.Close()
+}
+
+// RunCases start the services without the web service, execute every case,
+// then stop the target process and wait for all services to exit.
+// When the run is interrupted the history and the report of the executed cases are saved before returning the error.
+func RunCases(ctx *mbcontext.Context, cfg *Config, log logger.Logger, loader caseprovider.CaseLoader) (*cirunner.Summary, error) {
+	s, err := newServices(ctx, cfg, log, loader, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// stop all services whatever the result is.
+	defer func() {
+		ctx.CancelFunc()
+		ctx.Wait()
+		if err := s.history.Close(); err != nil {
+			log.Error(nil, "close the run history failed: %s", err)
+		}
+	}()
+
+	log.Info(nil, "* start to start captureServer")
+	if err = s.captureServer.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	log.Info(nil, "* start to start targetProcess")
+	if err = s.targetProcess.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	sel := cirunner.Selection{Filter: cfg.Runner.Filter}
+	summary, runErr := s.runner.Run(ctx, sel, nil)
+	if summary == nil {
+		return nil, runErr
+	}
+
+	status := runmanager.StatusPassed
+	switch {
+	case runErr != nil:
+		status = runmanager.StatusInterrupted
+	case !summary.Success():
+		status = runmanager.StatusFailed
+	}
+	if err := s.history.Save(runhistory.NewRun("", sel, string(status), summary)); err != nil {
+		log.Error(nil, "save the run into the history failed: %s", err)
+	}
+
+	r := report.New("middlebaby", summary.StartTime, summary.Duration, summary.Results)
+	if err := report.Save(cfg.TaskService.Report, r); err != nil {
+		if runErr != nil {
+			log.Error(nil, "save the report failed: %s", err)
+			return nil, runErr
+		}
+		return nil, err
+	}
+	return summary, runErr
+}
+
+// Validate lint the case files and the mock files without starting the services.
+func Validate(cfg *Config, log logger.Logger) ([]*casevalidator.Diagnostic, error) {
+	pluginRegistry, err := newPluginRegistry(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	protoProvider, err := protomanager.New(log, cfg.ProtoManager)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := casevalidator.New(log, cfg.CaseProvider, protoProvider, pluginRegistry)
+	if err != nil {
+		return nil, err
+	}
+	return v.Validate(), nil
 }
@@ -0,0 +1,85 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package taskserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	extractBodyPrefix   = "body"
+	extractHeaderPrefix = "header."
+	extractStatusCode   = "statusCode"
+)
+
+// extract evaluate the extract expressions of the case against the response and save the values into vars.
+func extract(expressions map[string]string, resp *mbcase.Response, vars *task.Vars) error {
+	for name, expr := range expressions {
+		value, err := extractValue(expr, resp)
+		if err != nil {
+			return fmt.Errorf("extract variable [%s] error: %v", name, err)
+		}
+		vars.Set(name, value)
+	}
+	return nil
+}
+
+func extractValue(expr string, resp *mbcase.Response) (interface{}, error) {
+	switch {
+	case expr == extractStatusCode:
+		return resp.StatusCode, nil
+	case strings.HasPrefix(expr, extractHeaderPrefix):
+		name := strings.TrimPrefix(expr, extractHeaderPrefix)
+		for k, v := range resp.Header {
+			if strings.EqualFold(k, name) {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("header [%s] not found", name)
+	case expr == extractBodyPrefix:
+		return resp.Data, nil
+	case strings.HasPrefix(expr, extractBodyPrefix+"."):
+		path := strings.TrimPrefix(expr, extractBodyPrefix+".")
+		body, ok := resp.Data.(string)
+		if !ok {
+			b, err := json.Marshal(resp.Data)
+			if err != nil {
+				return nil, err
+			}
+			body = string(b)
+		}
+
+		result := gjson.Get(body, path)
+		if !result.Exists() {
+			return nil, fmt.Errorf("path [%s] not found in the response body", path)
+		}
+		// keep the literal of numbers, float64 would print large integers in scientific notation.
+		if result.Type == gjson.Number {
+			return json.Number(result.Raw), nil
+		}
+		return result.Value(), nil
+	default:
+		return nil, fmt.Errorf("unknown expression [%s], it should be body.<path>, header.<name> or statusCode", expr)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package taskserver
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+)
+
+func Test_extract(t *testing.T) {
+	resp := &mbcase.Response{
+		Header:     map[string]string{"X-Request-Id": "abc"},
+		StatusCode: 201,
+		Data:       `{"order":{"id":12345678901234,"items":[{"sku":"A1"}]}}`,
+	}
+
+	tests := []struct {
+		name        string
+		expressions map[string]string
+		want        map[string]interface{}
+		wantErr     bool
+	}{
+		{
+			name: "body path, header and status code",
+			expressions: map[string]string{
+				"orderId": "body.order.id",
+				"sku":     "body.order.items.0.sku",
+				"reqId":   "header.x-request-id",
+				"code":    "statusCode",
+			},
+			want: map[string]interface{}{
+				"orderId": json.Number("12345678901234"),
+				"sku":     "A1",
+				"reqId":   "abc",
+				"code":    201,
+			},
+		},
+		{
+			name:        "missing path",
+			expressions: map[string]string{"x": "body.order.none"},
+			wantErr:     true,
+		},
+		{
+			name:        "unknown expression",
+			expressions: map[string]string{"x": "cookie.id"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := task.NewVars()
+			if err := extract(tt.expressions, resp, vars); (err != nil) != tt.wantErr {
+				t.Fatalf("extract() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := vars.All(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extract() vars = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package taskserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+)
+
+// hookCommands group the commands of the hooks by type, the hooks of the interface run first.
+// the commands are not rendered, the hooks read the run variables directly.
+func hookCommands(itfHooks, caseHooks []*mbcase.Command) map[string][]string {
+	cmdType := make(map[string][]string)
+	for _, hooks := range [][]*mbcase.Command{itfHooks, caseHooks} {
+		for _, c := range hooks {
+			if c != nil {
+				cmdType[c.TypeName] = append(cmdType[c.TypeName], c.Commands...)
+			}
+		}
+	}
+	return cmdType
+}
+
+// beforeRequest pass the request through the beforeRequest hooks of the plugins in the order they are registered.
+func (t *taskService) beforeRequest(ctx context.Context, cmdType map[string][]string, req *mbcase.CaseRequest,
+	vars *task.Vars) (*mbcase.CaseRequest, error) {
+	for _, p := range t.pluginRegistry.HookPlugins() {
+		cmds := cmdType[p.GetTypeName()]
+		if len(cmds) == 0 {
+			continue
+		}
+
+		var err error
+		if req, err = p.BeforeRequest(ctx, cmds, req, vars); err != nil {
+			return nil, fmt.Errorf("beforeRequest hook failed: %v", err)
+		}
+	}
+	return req, nil
+}
+
+// afterResponse pass the response through the afterResponse hooks of the plugins in the order they are registered.
+func (t *taskService) afterResponse(ctx context.Context, cmdType map[string][]string, req *mbcase.CaseRequest,
+	resp *mbcase.Response, vars *task.Vars) (*mbcase.Response, error) {
+	for _, p := range t.pluginRegistry.HookPlugins() {
+		cmds := cmdType[p.GetTypeName()]
+		if len(cmds) == 0 {
+			continue
+		}
+
+		var err error
+		if resp, err = p.AfterResponse(ctx, cmds, req, resp, vars); err != nil {
+			return nil, fmt.Errorf("afterResponse hook failed: %v", err)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,88 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package taskserver
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/pluginregistry"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+// fakeHookPlugin appends its commands to the X-Hooks header and to the response body.
+type fakeHookPlugin struct {
+	pluginregistry.HookPlugin
+	typeName string
+}
+
+func (f *fakeHookPlugin) GetTypeName() string { return f.typeName }
+
+func (f *fakeHookPlugin) BeforeRequest(_ context.Context, commands []string, req *mbcase.CaseRequest,
+	_ *task.Vars) (*mbcase.CaseRequest, error) {
+	if commands[0] == "fail" {
+		return nil, fmt.Errorf("boom")
+	}
+	out := *req
+	out.Header = map[string]string{"X-Hooks": req.Header["X-Hooks"] + strings.Join(commands, ",") + ";"}
+	return &out, nil
+}
+
+func (f *fakeHookPlugin) AfterResponse(_ context.Context, commands []string, _ *mbcase.CaseRequest,
+	resp *mbcase.Response, _ *task.Vars) (*mbcase.Response, error) {
+	out := *resp
+	out.Data = fmt.Sprint(resp.Data) + strings.Join(commands, ",") + ";"
+	return &out, nil
+}
+
+func Test_hookCommands(t *testing.T) {
+	got := hookCommands(
+		[]*mbcase.Command{{TypeName: "js", Commands: []string{"itf"}}, nil},
+		[]*mbcase.Command{{TypeName: "js", Commands: []string{"case"}}, {TypeName: "lua", Commands: []string{"x"}}})
+	want := map[string][]string{"js": {"itf", "case"}, "lua": {"x"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("hookCommands() = %v, want %v", got, want)
+	}
+}
+
+func Test_taskService_hooks(t *testing.T) {
+	registry, _ := pluginregistry.New(logger.NewDefault("test"), pluginregistry.NewConfig())
+	registry.RegisterHookPlugin(&fakeHookPlugin{typeName: "a"}, &fakeHookPlugin{typeName: "b"}, &fakeHookPlugin{typeName: "c"})
+	ts := &taskService{cfg: NewConfig(), pluginRegistry: registry}
+	cmdType := map[string][]string{"b": {"b1", "b2"}, "a": {"a1"}}
+
+	req, err := ts.beforeRequest(context.Background(), cmdType, &mbcase.CaseRequest{}, task.NewVars())
+	if err != nil || req.Header["X-Hooks"] != "a1;b1,b2;" {
+		t.Errorf("beforeRequest() = %v, %v, want the hooks run in the registered order", req, err)
+	}
+
+	resp, err := ts.afterResponse(context.Background(), cmdType, req, &mbcase.Response{Data: "body;"}, task.NewVars())
+	if err != nil || resp.Data != "body;a1;b1,b2;" {
+		t.Errorf("afterResponse() = %v, %v, want the hooks run in the registered order", resp, err)
+	}
+
+	_, err = ts.beforeRequest(context.Background(), map[string][]string{"c": {"fail"}}, &mbcase.CaseRequest{}, task.NewVars())
+	if err == nil || err.Error() != "beforeRequest hook failed: boom" {
+		t.Errorf("beforeRequest() error = %v, want the hook error", err)
+	}
+}
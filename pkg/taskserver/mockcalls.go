@@ -0,0 +1,125 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package taskserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/alsritter/middlebaby/pkg/apimanager"
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+// verifyMockCalls check the calls received by the mocks against the mock call assertions of the case,
+// in the soft assert mode every assertion is checked.
+func verifyMockCalls(ctx context.Context, log logger.Logger, calls []interact.Call, asserts []mbcase.MockCallAssert, ordered bool) error {
+	var errs assert.Errors
+	for _, err := range mockCallErrors(log, calls, asserts, ordered) {
+		if errs = errs.Append(mockCallsSource, err); !assert.IsSoft(ctx) {
+			break
+		}
+	}
+	return errs.Err()
+}
+
+// the source of the mock call assert errors.
+const mockCallsSource = "mock calls assert"
+
+// mockCallErrors returns the failure of each mock call assertion.
+func mockCallErrors(log logger.Logger, calls []interact.Call, asserts []mbcase.MockCallAssert, ordered bool) []error {
+	var errs []error
+	// the index of the call matched by the previous assertion, used when the order matters.
+	last := -1
+	for _, a := range asserts {
+		var matched []int
+		for i := range calls {
+			if mockCallMatches(log, &calls[i].Request, &a) {
+				matched = append(matched, i)
+			}
+		}
+
+		if a.Times != nil {
+			if len(matched) != *a.Times {
+				errs = append(errs, fmt.Errorf("mock call [%s] is expected %d time(s), actually %d time(s)", mockCallName(&a), *a.Times, len(matched)))
+				continue
+			}
+		} else if len(matched) == 0 {
+			errs = append(errs, fmt.Errorf("mock call [%s] is expected but never received", mockCallName(&a)))
+			continue
+		}
+
+		if !ordered || len(matched) == 0 {
+			continue
+		}
+		next := -1
+		for _, i := range matched {
+			if i > last {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			errs = append(errs, fmt.Errorf("mock call [%s] is not received after the previous mock call", mockCallName(&a)))
+			continue
+		}
+		last = next
+	}
+	return errs
+}
+
+func mockCallMatches(log logger.Logger, req *interact.Request, a *mbcase.MockCallAssert) bool {
+	if !apimanager.MatchRoute(req, &interact.Request{Method: a.Method, Host: a.Host, Path: a.Path}) {
+		return false
+	}
+
+	for name, expected := range a.Header {
+		if !headerContains(req.Header, name, expected) {
+			return false
+		}
+	}
+
+	if a.Body != nil {
+		if err := assert.So(log, "mock call body assert", req.GetBodyString(), a.Body); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// the header keys of grpc metadata are lowercase, so the key is compared case-insensitively.
+func headerContains(header map[string][]string, name, expected string) bool {
+	for k, values := range header {
+		if !strings.EqualFold(k, name) {
+			continue
+		}
+		for _, v := range values {
+			if v == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func mockCallName(a *mbcase.MockCallAssert) string {
+	return strings.TrimSpace(strings.Join([]string{a.Method, a.Host + a.Path}, " "))
+}
@@ -0,0 +1,112 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package taskserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func Test_verifyMockCalls(t *testing.T) {
+	calls := []interact.Call{
+		{Request: interact.Request{Method: "GET", Path: "/users/1", Header: map[string][]string{"x-token": {"t1"}}}, Matched: true},
+		{Request: interact.Request{Method: "POST", Path: "/orders", Body: `{"userId":1,"sku":"A1"}`}, Matched: true},
+		{Request: interact.Request{Method: "POST", Path: "/orders", Body: `{"userId":1,"sku":"B2"}`}, Matched: true},
+	}
+	times := func(n int) *int { return &n }
+
+	tests := []struct {
+		name    string
+		asserts []mbcase.MockCallAssert
+		ordered bool
+		// wantErrs the number of failures in the soft assert mode.
+		wantErrs int
+	}{
+		{
+			name: "called with header and body",
+			asserts: []mbcase.MockCallAssert{
+				{Method: "GET", Path: "/users/{id}", Header: map[string]string{"X-Token": "t1"}},
+				{Path: "/orders", Body: map[string]interface{}{"sku": "B2"}, Times: times(1)},
+			},
+		},
+		{
+			name:     "call count",
+			asserts:  []mbcase.MockCallAssert{{Method: "POST", Path: "/orders", Times: times(1)}},
+			wantErrs: 1,
+		},
+		{
+			name:    "never called",
+			asserts: []mbcase.MockCallAssert{{Method: "DELETE", Path: "/orders", Times: times(0)}},
+		},
+		{
+			name:     "missing call",
+			asserts:  []mbcase.MockCallAssert{{Path: "/payments"}},
+			wantErrs: 1,
+		},
+		{
+			name: "in order",
+			asserts: []mbcase.MockCallAssert{
+				{Path: "/users/{id}"},
+				{Path: "/orders", Body: map[string]interface{}{"sku": "A1"}},
+			},
+			ordered: true,
+		},
+		{
+			name: "out of order",
+			asserts: []mbcase.MockCallAssert{
+				{Path: "/orders", Body: map[string]interface{}{"sku": "B2"}},
+				{Path: "/orders", Body: map[string]interface{}{"sku": "A1"}},
+			},
+			ordered:  true,
+			wantErrs: 1,
+		},
+		{
+			name: "several failures",
+			asserts: []mbcase.MockCallAssert{
+				{Path: "/payments"},
+				{Method: "GET", Path: "/users/{id}"},
+				{Method: "POST", Path: "/orders", Times: times(1)},
+			},
+			wantErrs: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.NewDefault("test")
+			err := verifyMockCalls(context.Background(), log, calls, tt.asserts, tt.ordered)
+			// the first failure stops the assertions.
+			wantHard := tt.wantErrs
+			if wantHard > 1 {
+				wantHard = 1
+			}
+			if errs, _ := err.(assert.Errors); len(errs) != wantHard {
+				t.Errorf("verifyMockCalls() error = %v, wantErrs %d", err, tt.wantErrs)
+			}
+
+			err = verifyMockCalls(assert.WithSoft(context.Background(), true), log, calls, tt.asserts, tt.ordered)
+			if errs, _ := err.(assert.Errors); len(errs) != tt.wantErrs {
+				t.Errorf("verifyMockCalls() soft error = %v, wantErrs %d", err, tt.wantErrs)
+			}
+		})
+	}
+}
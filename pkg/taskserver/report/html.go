@@ -0,0 +1,95 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package report
+
+import (
+	"html/template"
+	"io"
+	"time"
+)
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"json": prettyJSON,
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"time": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Name }}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #24292f; }
+h1 { font-size: 22px; }
+.summary span { margin-right: 16px; }
+.passed { color: #1a7f37; }
+.failed { color: #cf222e; }
+.skipped { color: #9a6700; }
+.suite { border: 1px solid #d0d7de; border-radius: 6px; margin: 16px 0; }
+.suite > h2 { font-size: 16px; margin: 0; padding: 8px 12px; background: #f6f8fa; border-bottom: 1px solid #d0d7de; }
+details { padding: 6px 12px; border-bottom: 1px solid #eaeef2; }
+summary { cursor: pointer; }
+pre { background: #f6f8fa; padding: 8px; overflow-x: auto; white-space: pre-wrap; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{ .Name }}</h1>
+<div class="summary">
+<span>{{ time .Timestamp }}</span>
+<span>total: {{ .Total }}</span>
+<span class="passed">passed: {{ .Passed }}</span>
+<span class="failed">failed: {{ .Failed }}</span>
+<span class="skipped">skipped: {{ .Skipped }}</span>
+<span class="skipped">quarantined: {{ .Quarantined }}</span>
+<span>duration: {{ duration .Duration }}</span>
+</div>
+{{- range .Suites }}
+<div class="suite">
+<h2>{{ .Name }} <small>({{ .Total }} cases, {{ .Failed }} failed, {{ duration .Duration }})</small></h2>
+{{- range .Cases }}
+{{- if .Skipped }}
+<details>
+<summary><span class="skipped">&#8856;</span> {{ .CaseName }} <small>skipped: {{ .SkipReason }}</small></summary>
+</details>
+{{- else }}
+<details{{ if not .Passed }} open{{ end }}>
+<summary>{{ if .Passed }}<span class="passed">&#10004;</span>{{ else }}<span class="failed">&#10008;</span>{{ end }} {{ .CaseName }} <small>{{ duration .Duration }}
+{{- if .PassedOnRetry }}, passed on retry after {{ .Attempts }} attempts{{ end }}
+{{- if .Quarantined }}, quarantined{{ end }}</small></summary>
+{{- if .FailedReason }}<h4 class="failed">failed reason</h4><pre>{{ .FailedReason }}</pre>{{ end }}
+{{- if .SetupError }}<h4>setup error</h4><pre>{{ .SetupError }}</pre>{{ end }}
+{{- if .TeardownError }}<h4>teardown error</h4><pre>{{ .TeardownError }}</pre>{{ end }}
+{{- if .Request }}<h4>request</h4><pre>{{ json .Request }}</pre>{{ end }}
+{{- if .Response }}<h4>actual response</h4><pre>{{ json .Response }}</pre>{{ end }}
+{{- if .MockCalls }}<h4>mock calls</h4><pre>{{ json .MockCalls }}</pre>{{ end }}
+</details>
+{{- end }}
+{{- end }}
+</div>
+{{- end }}
+</body>
+</html>
+`))
+
+// WriteHTML write the report as a self-contained HTML page.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,165 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/types/task"
+)
+
+// the JUnit XML schema understood by most CI systems.
+// reference: https://github.com/testmoapp/junitxml
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// WriteJUnit write the report as JUnit XML.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	out := junitTestSuites{
+		Name: r.Name,
+		Time: junitTime(r.Duration),
+	}
+
+	for _, s := range r.Suites {
+		suite := junitTestSuite{
+			Name:      s.Name,
+			Tests:     s.Total,
+			Time:      junitTime(s.Duration),
+			Timestamp: r.Timestamp.Format("2006-01-02T15:04:05"),
+		}
+
+		for _, c := range s.Cases {
+			tc := junitTestCase{
+				Name:      c.CaseName,
+				ClassName: c.ItfName,
+				Time:      junitTime(c.Duration),
+				SystemOut: caseDetail(c),
+			}
+
+			// assertion mismatches are failures, everything else prevents the case from being judged.
+			// a quarantined failure must not fail the build, it is reported as skipped.
+			if c.Skipped() || c.FailedQuarantined() {
+				tc.Skipped = &junitMessage{Message: c.SkipReason}
+				if c.FailedQuarantined() {
+					tc.Skipped.Message = "quarantined: " + firstLine(c.FailedReason)
+				}
+				suite.Skipped++
+			} else if !c.Passed() {
+				if c.AssertError != "" {
+					tc.Failure = &junitMessage{Message: firstLine(c.AssertError), Type: "AssertError", Contents: c.AssertError}
+					suite.Failures++
+				} else {
+					tc.Error = &junitMessage{Message: firstLine(c.FailedReason), Type: "Error", Contents: c.FailedReason}
+					suite.Errors++
+				}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		out.Tests += suite.Tests
+		out.Failures += suite.Failures
+		out.Errors += suite.Errors
+		out.Skipped += suite.Skipped
+		out.Suites = append(out.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// caseDetail the request, the actual response, the mock calls and the setup/teardown errors of a case.
+func caseDetail(c *task.RunTaskReply) string {
+	var b strings.Builder
+	if c.PassedOnRetry() {
+		b.WriteString(fmt.Sprintf("passed on retry after %d attempts\n", c.Attempts))
+	}
+	if c.Request != nil {
+		b.WriteString("request:\n" + prettyJSON(c.Request) + "\n")
+	}
+	if c.Response != nil {
+		b.WriteString("response:\n" + prettyJSON(c.Response) + "\n")
+	}
+	if len(c.MockCalls) > 0 {
+		b.WriteString("mock calls:\n" + prettyJSON(c.MockCalls) + "\n")
+	}
+	if c.SetupError != "" {
+		b.WriteString("setup error: " + c.SetupError + "\n")
+	}
+	if c.TeardownError != "" {
+		b.WriteString("teardown error: " + c.TeardownError + "\n")
+	}
+	return b.String()
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return s
+}
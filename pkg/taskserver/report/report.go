@@ -0,0 +1,172 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/types/task"
+)
+
+// Config defines the report output paths, an empty path disables the report.
+type Config struct {
+	JUnitFile string `yaml:"junit"`
+	JSONFile  string `yaml:"json"`
+	HTMLFile  string `yaml:"html"`
+}
+
+// NewConfig is used to init config with default values
+func NewConfig() *Config {
+	return &Config{}
+}
+
+// Report the results of a test run, the cases are grouped by interface.
+type Report struct {
+	Name      string        `json:"name"`
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration"`
+	Total     int           `json:"total"`
+	Passed    int           `json:"passed"`
+	Failed    int           `json:"failed"`
+	Skipped   int           `json:"skipped"`
+	// Quarantined the number of quarantined cases which failed, they are not counted as failed.
+	Quarantined int      `json:"quarantined"`
+	Suites      []*Suite `json:"suites"`
+}
+
+// Suite the results of all cases of an interface.
+type Suite struct {
+	Name     string               `json:"name"`
+	Duration time.Duration        `json:"duration"`
+	Total    int                  `json:"total"`
+	Failed   int                  `json:"failed"`
+	Skipped  int                  `json:"skipped"`
+	Cases    []*task.RunTaskReply `json:"cases"`
+}
+
+// New build a report from the case results, the results order of each interface is preserved.
+func New(name string, timestamp time.Time, duration time.Duration, results []*task.RunTaskReply) *Report {
+	r := &Report{
+		Name:      name,
+		Timestamp: timestamp,
+		Duration:  duration,
+	}
+
+	suites := make(map[string]*Suite)
+	for _, result := range results {
+		s, ok := suites[result.ItfName]
+		if !ok {
+			s = &Suite{Name: result.ItfName}
+			suites[result.ItfName] = s
+			r.Suites = append(r.Suites, s)
+		}
+
+		s.Cases = append(s.Cases, result)
+		s.Duration += result.Duration
+		s.Total++
+		r.Total++
+		switch {
+		case result.Skipped():
+			s.Skipped++
+			r.Skipped++
+		case result.Passed():
+			r.Passed++
+		case result.FailedQuarantined():
+			r.Quarantined++
+		default:
+			s.Failed++
+			r.Failed++
+		}
+	}
+
+	sort.SliceStable(r.Suites, func(i, j int) bool {
+		return r.Suites[i].Name < r.Suites[j].Name
+	})
+	return r
+}
+
+// WriteJSON write the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Save write the report into every file configured.
+func Save(cfg *Config, r *Report) error {
+	writers := []struct {
+		path  string
+		write func(io.Writer) error
+	}{
+		{cfg.JUnitFile, r.WriteJUnit},
+		{cfg.JSONFile, r.WriteJSON},
+		{cfg.HTMLFile, r.WriteHTML},
+	}
+
+	for _, w := range writers {
+		if w.path == "" {
+			continue
+		}
+		if err := writeFile(w.path, w.write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create report directory of [%s] error: [%v]", path, err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create report file [%s] error: [%v]", path, err)
+	}
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		return fmt.Errorf("write report file [%s] error: [%v]", path, err)
+	}
+	return nil
+}
+
+// prettyJSON marshal the value for human reading, it returns the raw string if the value is a string.
+func prettyJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,85 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+)
+
+func testResults() []*task.RunTaskReply {
+	return []*task.RunTaskReply{
+		{ItfName: "b", CaseName: "b1", Status: 1, Duration: time.Second},
+		{ItfName: "a", CaseName: "a1", Status: 0, FailedReason: "assert failed", AssertError: "assert failed",
+			Response: &mbcase.Response{StatusCode: 500, Data: "<oops>"}},
+		{ItfName: "a", CaseName: "a2", Status: 0, FailedReason: "setup command failed", SetupError: "bad sql"},
+		{ItfName: "b", CaseName: "b2", SkipReason: "flaky upstream"},
+		{ItfName: "b", CaseName: "b3", Status: 1, Attempts: 2},
+		{ItfName: "c", CaseName: "c1", Status: 0, FailedReason: "timeout", Quarantined: true},
+	}
+}
+
+func TestNew(t *testing.T) {
+	r := New("test", time.Now(), time.Second, testResults())
+	if r.Total != 6 || r.Passed != 2 || r.Failed != 2 || r.Skipped != 1 || r.Quarantined != 1 {
+		t.Fatalf("New() total = %d, passed = %d, failed = %d, skipped = %d, quarantined = %d",
+			r.Total, r.Passed, r.Failed, r.Skipped, r.Quarantined)
+	}
+	if len(r.Suites) != 3 || r.Suites[0].Name != "a" || r.Suites[0].Failed != 2 {
+		t.Errorf("New() suites are not grouped by interface: %+v", r.Suites)
+	}
+}
+
+func TestReport_WriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New("test", time.Now(), time.Second, testResults()).WriteJUnit(&buf); err != nil {
+		t.Fatalf("WriteJUnit() error = %v", err)
+	}
+
+	var out junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("WriteJUnit() output is not valid xml: %v", err)
+	}
+	if out.Tests != 6 || out.Failures != 1 || out.Errors != 1 || out.Skipped != 2 {
+		t.Errorf("WriteJUnit() tests = %d, failures = %d, errors = %d, skipped = %d",
+			out.Tests, out.Failures, out.Errors, out.Skipped)
+	}
+}
+
+func TestReport_WriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New("test", time.Now(), time.Second, testResults()).WriteHTML(&buf); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "&lt;oops&gt;") {
+		t.Errorf("WriteHTML() the actual response is not escaped")
+	}
+	if !strings.Contains(buf.String(), "skipped: flaky upstream") {
+		t.Errorf("WriteHTML() the skip reason is not rendered")
+	}
+	if !strings.Contains(buf.String(), "passed on retry after 2 attempts") ||
+		!strings.Contains(buf.String(), ", quarantined") {
+		t.Errorf("WriteHTML() the retried and the quarantined cases are not marked")
+	}
+}
@@ -26,22 +26,30 @@ import (
 	"net/http/httputil"
 	"net/textproto"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/alsritter/middlebaby/pkg/apimanager"
+	"github.com/alsritter/middlebaby/pkg/taskserver/snapshot"
+	"github.com/alsritter/middlebaby/pkg/types/interact"
 	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
 	"github.com/alsritter/middlebaby/pkg/util/assert"
 	"github.com/alsritter/middlebaby/pkg/util/grpcurl/ext/ggrpcurl"
+	"github.com/alsritter/middlebaby/pkg/util/render"
 )
 
-func (t *taskService) Run(ctx context.Context, itfName string, caseName string) (err error) {
-	t.apiProvider.LoadCaseEnv(itfName, caseName)
-	defer t.apiProvider.ClearCaseEnv()
+// Run execute the case and record the request, the actual response and errors of each stage into the record.
+func (t *taskService) Run(ctx context.Context, itfName string, caseName string, record *task.RunTaskReply) (err error) {
+	vars := task.VarsFromContext(ctx)
+	envID := t.apiProvider.LoadCaseEnv(itfName, caseName, vars.TemplateData())
+	defer t.apiProvider.ClearCaseEnv(envID)
 
 	var (
-		ass              = t.pluginRegistry.AssertPlugins()
 		envs             = t.pluginRegistry.EnvPlugins()
 		info             = t.caseProvider.GetItfInfoFromItfName(itfName)
+		itf              = t.caseProvider.GetItfFromItfName(itfName)
 		runCase          = t.caseProvider.GetAllCaseFromCaseName(itfName, caseName)
 		setupItfCmds     = t.caseProvider.GetItfSetupCommand(itfName)
 		setupCaseCmds    = t.caseProvider.GetCaseSetupCommand(itfName, caseName)
@@ -53,7 +61,7 @@ func (t *taskService) Run(ctx context.Context, itfName string, caseName string)
 		assertCmdType   = make(map[string][]mbcase.CommonAssert)
 	)
 
-	if info == nil || runCase == nil {
+	if info == nil || itf == nil || runCase == nil {
 		return fmt.Errorf("cannot find case [%s]-[%s]", itfName, caseName)
 	}
 
@@ -75,7 +83,12 @@ func (t *taskService) Run(ctx context.Context, itfName string, caseName string)
 
 	// before run command
 	for _, e := range envs {
-		if err := e.Run(setupCmdType[e.GetTypeName()]); err != nil {
+		cmds, err := render.Strings(setupCmdType[e.GetTypeName()], vars.TemplateData())
+		if err == nil {
+			err = e.Run(ctx, cmds)
+		}
+		if err != nil {
+			record.SetupError = err.Error()
 			return fmt.Errorf("setup command failed: %v", err)
 		}
 	}
@@ -83,44 +96,174 @@ func (t *taskService) Run(ctx context.Context, itfName string, caseName string)
 	// after run command
 	defer func() {
 		if !t.cfg.CloseTearDown {
+			// the teardown still cleans up when the case has timed out or has been canceled.
+			tearDownCtx, cancel := t.tearDownContext()
+			defer cancel()
 			for _, e := range envs {
-				if tearDownError := e.Run(teardownCmdType[e.GetTypeName()]); tearDownError != nil {
+				// rendered at the end so that teardown can use the variables extracted by this case.
+				cmds, tearDownError := render.Strings(teardownCmdType[e.GetTypeName()], vars.TemplateData())
+				if tearDownError == nil {
+					tearDownError = e.Run(tearDownCtx, cmds)
+				}
+				if tearDownError != nil {
+					record.TeardownError = tearDownError.Error()
 					t.Error(nil, "teardown command failed: %v", tearDownError)
 				}
 			}
 		}
 	}()
 
-	ar, err := t.runRequest(info, runCase)
+	if runCase, err = renderRequest(runCase, vars.TemplateData()); err != nil {
+		return err
+	}
+
+	runCase = withCorrelationID(runCase, envID)
+	if runCase.Request, err = t.beforeRequest(ctx, hookCommands(itf.BeforeRequest, runCase.BeforeRequest),
+		runCase.Request, vars); err != nil {
+		return err
+	}
+
+	record.Request = runCase.Request
+	ar, err := t.runRequest(ctx, info, runCase)
+	record.MockCalls = t.apiProvider.GetCalls(envID)
 	if err != nil {
 		return err
 	}
+	// the response of the target is recorded, the hooks only change what is extracted and asserted.
+	record.Response = ar
+
+	if ar, err = t.afterResponse(ctx, hookCommands(itf.AfterResponse, runCase.AfterResponse),
+		runCase.Request, ar, vars); err != nil {
+		return err
+	}
+
+	if err := extract(runCase.Extract, ar, vars); err != nil {
+		return err
+	}
 
 	for _, oa := range runCase.Assert.OtherAsserts {
+		if oa.Actual, err = render.String(oa.Actual, vars.TemplateData()); err != nil {
+			return err
+		}
 		assertCmdType[oa.TypeName] = append(assertCmdType[oa.TypeName], oa)
 	}
 
+	ctx = assert.WithSoft(ctx, t.softAssert(runCase.Assert))
+	if errs := t.assertCase(ctx, itfName, runCase, ar, record.MockCalls, assertCmdType); len(errs) > 0 {
+		record.AssertError = errs.Error()
+		record.AssertErrors = errs
+		return errs
+	}
+	return
+}
+
+// softAssert whether every assertion of the case is evaluated, the case overrides the config.
+func (t *taskService) softAssert(a *mbcase.Assert) bool {
+	if a.Soft != nil {
+		return *a.Soft
+	}
+	return t.cfg.SoftAssert
+}
+
+// assertCase run the assertions of the response, the snapshot, the mock calls and the other asserts of the case,
+// they stop at the first failure unless the ctx is in the soft assert mode.
+func (t *taskService) assertCase(ctx context.Context, itfName string, c *mbcase.CaseTask, ar *mbcase.Response,
+	calls []interact.Call, assertCmdType map[string][]mbcase.CommonAssert) assert.Errors {
+	var (
+		a    = c.Assert
+		errs assert.Errors
+	)
+	// check records the failures of an assertion and returns whether the next assertions are evaluated.
+	check := func(source string, err error) bool {
+		errs = errs.Append(source, err)
+		return len(errs) == 0 || assert.IsSoft(ctx)
+	}
+
+	if !check("response assert", t.imposterAssert(ctx, a, ar.Header, ar.StatusCode, ar.Data)) {
+		return errs
+	}
+
+	if !check(snapshotSource, t.snapshotAssert(itfName, c.Name, a, ar)) {
+		return errs
+	}
+
+	if !check(mockCallsSource, verifyMockCalls(ctx, t, calls, a.MockCalls, a.MockCallsOrdered)) {
+		return errs
+	}
+
 	// other assert
-	for _, a := range ass {
-		if err = a.Assert(ar, assertCmdType[a.GetTypeName()]); err != nil {
-			return err
+	for _, p := range t.pluginRegistry.AssertPlugins() {
+		if !check(p.GetTypeName()+" assert", p.Assert(ctx, ar, assertCmdType[p.GetTypeName()])) {
+			return errs
 		}
 	}
-	return
+	return errs
+}
+
+// tearDownContext returns a ctx which is independent of the case, it is canceled after the configured timeout.
+func (t *taskService) tearDownContext() (context.Context, context.CancelFunc) {
+	if t.cfg.Timeout > 0 {
+		return context.WithTimeout(context.Background(), time.Duration(t.cfg.Timeout)*time.Millisecond)
+	}
+	return context.WithCancel(context.Background())
 }
 
-func (t *taskService) runRequest(info *mbcase.TaskInfo, runCase *mbcase.CaseTask) (*mbcase.Response, error) {
+// renderRequest returns a copy of the case whose request has been rendered with the run variables.
+func renderRequest(c *mbcase.CaseTask, data interface{}) (*mbcase.CaseTask, error) {
+	if c.Request == nil || !render.HasAction(c.Request) {
+		return c, nil
+	}
+
+	out := *c
+	out.Request = &mbcase.CaseRequest{}
+	if err := render.Into(c.Request, out.Request, data); err != nil {
+		return nil, fmt.Errorf("render case request error: %v", err)
+	}
+	return &out, nil
+}
+
+// withCorrelationID returns a copy of the case whose request carries the environment id,
+// so that the mocked dependencies of concurrent cases are isolated from each other.
+func withCorrelationID(c *mbcase.CaseTask, envID string) *mbcase.CaseTask {
+	out := *c
+	req := mbcase.CaseRequest{}
+	if c.Request != nil {
+		req = *c.Request
+	}
+
+	header := make(map[string]string, len(req.Header)+1)
+	for k, v := range req.Header {
+		header[k] = v
+	}
+	header[apimanager.CorrelationHeader] = envID
+	req.Header = header
+	out.Request = &req
+	return &out
+}
+
+// requestTimeout bounds the request to the target when the case has no timeout,
+// so that a hanging target cannot block a worker forever.
+var requestTimeout = 30 * time.Second
+
+func (t *taskService) runRequest(ctx context.Context, info *mbcase.TaskInfo, runCase *mbcase.CaseTask) (*mbcase.Response, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
 	// request assert
 	if info.Protocol == mbcase.ProtocolHTTP {
-		return t.httpRequest(info, runCase)
+		return t.httpRequest(ctx, info, runCase)
 	} else {
-		return t.grpcRequest(info, runCase)
+		return t.grpcRequest(ctx, info, runCase)
 	}
 }
 
-func (t *taskService) httpRequest(info *mbcase.TaskInfo, ct *mbcase.CaseTask) (*mbcase.Response, error) {
+func (t *taskService) httpRequest(ctx context.Context, info *mbcase.TaskInfo, ct *mbcase.CaseTask) (*mbcase.Response, error) {
 	// request
 	responseHeader, statusCode, responseBody, err := t.httpClient(
+		ctx,
 		info.ServicePath,
 		info.ServiceMethod,
 		ct.Request.Query,
@@ -143,10 +286,6 @@ func (t *taskService) httpRequest(info *mbcase.TaskInfo, ct *mbcase.CaseTask) (*
 		responseKeyVal[k] = responseHeader.Get(k)
 	}
 
-	if err := t.imposterAssert(ct.Assert, responseKeyVal, statusCode, responseBody); err != nil {
-		return nil, err
-	}
-
 	return &mbcase.Response{
 		Header:     responseKeyVal,
 		Data:       responseBody,
@@ -154,7 +293,7 @@ func (t *taskService) httpRequest(info *mbcase.TaskInfo, ct *mbcase.CaseTask) (*
 	}, nil
 }
 
-func (t *taskService) grpcRequest(info *mbcase.TaskInfo, ct *mbcase.CaseTask) (*mbcase.Response, error) {
+func (t *taskService) grpcRequest(ctx context.Context, info *mbcase.TaskInfo, ct *mbcase.CaseTask) (*mbcase.Response, error) {
 	var addHeaders []string
 	for k, v := range ct.Request.Header {
 		addHeaders = append(addHeaders, k+":"+v)
@@ -177,10 +316,13 @@ func (t *taskService) grpcRequest(info *mbcase.TaskInfo, ct *mbcase.CaseTask) (*
 		ServiceMethod: info.ServicePath,
 	}
 
-	responseMD, trailerMD, responseBody, _, err := ggrpcurl.NewInvokeGRpc(&dto).Invoke()
+	responseMD, trailerMD, responseBody, _, err := ggrpcurl.NewInvokeGRpc(&dto).Invoke(ctx)
 	if err != nil {
 		t.Error(nil, "grpc request failed, casename: [%s], error:[%v]", ct.Name, err)
 	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("failed to execute the request: [%s] err: [%v]", info.ServicePath, ctx.Err())
+	}
 
 	// assert
 	t.Trace(map[string]interface{}{
@@ -194,10 +336,6 @@ func (t *taskService) grpcRequest(info *mbcase.TaskInfo, ct *mbcase.CaseTask) (*
 		responseKeyVal[k] = textproto.MIMEHeader(responseMD).Get(k)
 	}
 
-	if err := t.imposterAssert(ct.Assert, responseKeyVal, http.StatusOK, responseBody); err != nil {
-		return nil, err
-	}
-
 	return &mbcase.Response{
 		Header:     responseKeyVal,
 		Data:       responseBody,
@@ -205,23 +343,79 @@ func (t *taskService) grpcRequest(info *mbcase.TaskInfo, ct *mbcase.CaseTask) (*
 	}, nil
 }
 
-func (t *taskService) imposterAssert(a *mbcase.Assert, headerKeyVal map[string]string, statusCode int, responseBody string) error {
-	if a.Response.StatusCode != 0 {
-		if err := assert.So(t, "response status code data assert", statusCode, a.Response.StatusCode); err != nil {
-			return err
-		}
+const snapshotSource = "snapshot assert"
+
+// snapshotAssert compare the response data with the snapshot of the case, the first run writes the snapshot.
+func (t *taskService) snapshotAssert(itfName, caseName string, a *mbcase.Assert, ar *mbcase.Response) error {
+	if !a.Response.Snapshot {
+		return nil
 	}
 
-	if err := assert.So(t, "response header data assert", headerKeyVal, a.Response.Header); err != nil {
+	caseFile := t.caseFile(itfName)
+	if caseFile == "" {
+		return fmt.Errorf("cannot find the case file of [%s]", itfName)
+	}
+
+	ignore := append(append([]string{}, t.cfg.SnapshotIgnore...), a.Response.SnapshotIgnore...)
+	actual, err := snapshot.Normalize(ar.Data, ignore)
+	if err != nil {
 		return err
 	}
-	if err := assert.So(t, "response body data assert", responseBody, a.Response.Data); err != nil {
+
+	file := snapshot.Path(caseFile)
+	written, changes, err := t.snapshots.Match(file, itfName, caseName, actual)
+	if err != nil {
 		return err
 	}
+	if written {
+		t.Info(map[string]interface{}{"InterfaceName": itfName, "CaseName": caseName}, "snapshot written [%s]", file)
+	}
+	if len(changes) > 0 {
+		return &assert.AssertError{
+			Type:    snapshotSource,
+			Err:     fmt.Errorf("the response data does not match the snapshot [%s], run with --update-snapshots to update it", file),
+			Changes: changes,
+		}
+	}
 	return nil
 }
 
-func (t *taskService) httpClient(reqUrl, method string, query url.Values, header map[string]string, reqBody interface{}) (http.Header, int, string, error) {
+// caseFile returns the path of the case file of the interface.
+func (t *taskService) caseFile(itfName string) string {
+	for _, itf := range t.caseProvider.GetAllItfWithFileInfo() {
+		if itf.ServiceName == itfName {
+			return filepath.Join(itf.Dirpath, itf.Filename)
+		}
+	}
+	return ""
+}
+
+func (t *taskService) imposterAssert(ctx context.Context, a *mbcase.Assert, headerKeyVal map[string]string, statusCode int, responseBody interface{}) error {
+	// a nil expected value is not asserted.
+	var expectedStatusCode interface{}
+	if a.Response.StatusCode != 0 {
+		expectedStatusCode = a.Response.StatusCode
+	}
+
+	var errs assert.Errors
+	for _, c := range []struct {
+		assertType       string
+		actual, expected interface{}
+	}{
+		{"response status code data assert", statusCode, expectedStatusCode},
+		{"response header data assert", headerKeyVal, a.Response.Header},
+		{"response body data assert", responseBody, a.Response.Data},
+	} {
+		err := assert.SoContext(ctx, t, c.assertType, c.actual, c.expected)
+		if errs = errs.Append(c.assertType, err); len(errs) > 0 && !assert.IsSoft(ctx) {
+			break
+		}
+	}
+	return errs.Err()
+}
+
+// httpClient send the request, the request is canceled when the ctx is done.
+func (t *taskService) httpClient(ctx context.Context, reqUrl, method string, query url.Values, header map[string]string, reqBody interface{}) (http.Header, int, string, error) {
 	parseUrl, err := url.Parse(reqUrl)
 	if err != nil {
 		return nil, 0, "", fmt.Errorf("format request address error, url:[%s] err:[%v]", reqUrl, err)
@@ -239,16 +433,14 @@ func (t *taskService) httpClient(reqUrl, method string, query url.Values, header
 	}
 	reqBodyReader := strings.NewReader(reqBodyStr)
 
-	request, err := http.NewRequest(method, parseUrl.String(), reqBodyReader)
+	request, err := http.NewRequestWithContext(ctx, method, parseUrl.String(), reqBodyReader)
 	if err != nil {
 		return nil, 0, "", fmt.Errorf("create request failed, url:[%s] error:[%v]", reqUrl, err)
 	}
 	for key, val := range header {
 		request.Header.Add(key, val)
 	}
-	client := http.Client{
-		Timeout: time.Second * 30,
-	}
+	client := http.Client{}
 
 	trace := &httptrace.ClientTrace{
 		GotConn: func(connInfo httptrace.GotConnInfo) {
@@ -0,0 +1,125 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package taskserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/taskserver/snapshot"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func Test_taskService_httpClient_Canceled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ts := &taskService{Logger: logger.NewDefault("test"), cfg: NewConfig()}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	if _, _, _, err := ts.httpClient(ctx, srv.URL, http.MethodGet, nil, nil, nil); err == nil {
+		t.Fatal("httpClient() error = nil, want the deadline error")
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Errorf("httpClient() returned after %s, want it to follow the ctx", elapsed)
+	}
+}
+
+func Test_taskService_runRequest_NoTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	defer func(timeout time.Duration) { requestTimeout = timeout }(requestTimeout)
+	requestTimeout = 50 * time.Millisecond
+
+	ts := &taskService{Logger: logger.NewDefault("test"), cfg: NewConfig()}
+	info := &mbcase.TaskInfo{Protocol: mbcase.ProtocolHTTP, ServiceMethod: http.MethodGet, ServicePath: srv.URL}
+	c := &mbcase.CaseTask{Request: &mbcase.CaseRequest{}, Assert: &mbcase.Assert{}}
+
+	// a case without timeout still gives up on a hanging target.
+	begin := time.Now()
+	if _, err := ts.runRequest(context.Background(), info, c); err == nil {
+		t.Fatal("runRequest() error = nil, want the request timeout error")
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Errorf("runRequest() returned after %s, want it bounded by the request timeout", elapsed)
+	}
+}
+
+func Test_taskService_snapshotAssert(t *testing.T) {
+	dir := t.TempDir()
+	cfg := NewConfig()
+	cfg.SnapshotIgnore = []string{"/createdAt"}
+	ts := &taskService{
+		Logger:       logger.NewDefault("test"),
+		cfg:          cfg,
+		caseProvider: &fakeCaseProvider{itf: &mbcase.ItfTask{TaskInfo: &mbcase.TaskInfo{ServiceName: "getUser"}}, dir: dir},
+		snapshots:    snapshot.NewStore(false),
+	}
+	a := &mbcase.Assert{Response: mbcase.Response{Snapshot: true, SnapshotIgnore: []string{"/id"}}}
+
+	if err := ts.snapshotAssert("getUser", "ok", &mbcase.Assert{}, &mbcase.Response{Data: `{}`}); err != nil {
+		t.Errorf("snapshotAssert() error = %v, want no snapshot assert", err)
+	}
+
+	first := &mbcase.Response{Data: `{"id": 1, "createdAt": "2022-09-11", "name": "John"}`}
+	if err := ts.snapshotAssert("getUser", "ok", a, first); err != nil {
+		t.Fatalf("snapshotAssert() error = %v, want the snapshot written", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshot.Dir, "user.case.json.snap.json")); err != nil {
+		t.Fatalf("the snapshot file is not written: %v", err)
+	}
+
+	// the ignored values are not compared.
+	same := &mbcase.Response{Data: `{"id": 2, "createdAt": "2022-10-01", "name": "John"}`}
+	if err := ts.snapshotAssert("getUser", "ok", a, same); err != nil {
+		t.Errorf("snapshotAssert() error = %v, want a match", err)
+	}
+
+	err := ts.snapshotAssert("getUser", "ok", a, &mbcase.Response{Data: `{"id": 1, "name": "Jane"}`})
+	var assertErr *assert.AssertError
+	if !errors.As(err, &assertErr) || len(assertErr.Changes) != 1 || assertErr.Changes[0].Path != "/name" ||
+		!strings.Contains(err.Error(), "--update-snapshots") {
+		t.Errorf("snapshotAssert() error = %v, want the change of /name", err)
+	}
+}
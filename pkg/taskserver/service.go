@@ -19,11 +19,16 @@ package taskserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/alsritter/middlebaby/pkg/apimanager"
 	"github.com/alsritter/middlebaby/pkg/pluginregistry"
 	"github.com/alsritter/middlebaby/pkg/protomanager"
+	"github.com/alsritter/middlebaby/pkg/taskserver/report"
+	"github.com/alsritter/middlebaby/pkg/taskserver/snapshot"
 
 	"github.com/spf13/pflag"
 
@@ -33,13 +38,32 @@ import (
 )
 
 type Config struct {
-	CloseTearDown    bool   `yaml:"closeTearDown"`
-	TargetServeAdder string `yaml:"targetServeAdder"`
+	CloseTearDown    bool           `yaml:"closeTearDown"`
+	TargetServeAdder string         `yaml:"targetServeAdder"`
+	Report           *report.Config `yaml:"report"`
+	// Retries the default number of retries of the failed cases, the interfaces and the cases can override it.
+	Retries int `yaml:"retries"`
+	// RetryDelay the default milliseconds to wait before each retry.
+	RetryDelay int64 `yaml:"retryDelay"`
+	// Quarantine the cases which are run and reported but whose failure does not fail the run,
+	// "<serviceName>" quarantines every case of the interface, "<serviceName>/<case name>" a single case.
+	Quarantine []string `yaml:"quarantine"`
+	// Timeout the default milliseconds an attempt of a case may take, 0 means no timeout.
+	Timeout int64 `yaml:"timeout"`
+	// SoftAssert evaluate every assertion of a case and report all the failures instead of stopping at the first one.
+	SoftAssert bool `yaml:"softAssert"`
+	// SnapshotIgnore the JSON pointers of the values left out of every response snapshot. (e.g. timestamps and IDs)
+	SnapshotIgnore []string `yaml:"snapshotIgnore"`
+	// UpdateSnapshots rewrite the response snapshots which do not match instead of failing the cases,
+	// it is set by the --update-snapshots flag of the run command.
+	UpdateSnapshots bool `yaml:"-"`
 }
 
 func NewConfig() *Config {
 	return &Config{
 		CloseTearDown: false,
+		Report:        report.NewConfig(),
+		Timeout:       30000,
 	}
 }
 
@@ -48,9 +72,37 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("target Serve Adder cannot be empty")
 	}
 
+	if c.Report == nil {
+		return fmt.Errorf("report config cannot be nil")
+	}
+
+	if c.Retries < 0 || c.RetryDelay < 0 {
+		return fmt.Errorf("retries and retry delay cannot be negative")
+	}
+
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+
+	for _, p := range c.SnapshotIgnore {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("invalid snapshot ignore path [%s], it must be a JSON pointer like /data/id", p)
+		}
+	}
+
 	return nil
 }
 
+// IsQuarantined whether the case is in the quarantine list.
+func (c *Config) IsQuarantined(itfName, caseName string) bool {
+	for _, q := range c.Quarantine {
+		if q == itfName || q == itfName+"/"+caseName {
+			return true
+		}
+	}
+	return false
+}
+
 // RegisterFlagsWithPrefix is used to register flags
 func (c *Config) RegisterFlagsWithPrefix(prefix string, f *pflag.FlagSet) {}
 
@@ -66,6 +118,7 @@ type taskService struct {
 	apiProvider    apimanager.Provider
 	protoProvider  protomanager.Provider
 	pluginRegistry pluginregistry.Registry
+	snapshots      *snapshot.Store
 }
 
 // New return a TaskService
@@ -81,30 +134,116 @@ func New(log logger.Logger, cfg *Config,
 		protoProvider:  protoProvider,
 		apiProvider:    apiProvider,
 		pluginRegistry: pluginRegistry,
+		snapshots:      snapshot.NewStore(cfg.UpdateSnapshots),
 		Logger:         log.NewLogger("task"),
 	}
 }
 
 // RunSingleTaskCase implements task.TaskServer
+// A failed case is run again according to its retry policy, the reply is the one of the last attempt.
+// Each attempt is canceled when it exceeds the timeout of the case or when the ctx is done.
 func (t *taskService) RunSingleTaskCase(ctx context.Context, itfName, caseName string) (task.RunTaskReply, error) {
-	if err := t.Run(ctx, itfName, caseName); err != nil {
-		t.Error(map[string]interface{}{
-			"InterfaceName": itfName,
-			"CaseName":      caseName,
-		}, err.Error())
-
-		return task.RunTaskReply{
-			Status:       0,
-			FailedReason: err.Error(),
-		}, nil
-	}
-
-	t.Info(map[string]interface{}{
-		"InterfaceName": itfName,
-		"CaseName":      caseName,
-	}, "case assert successful")
-	return task.RunTaskReply{
-		Status:       1,
-		FailedReason: "",
-	}, nil
+	var (
+		begin          = time.Now()
+		retries, delay = t.retryPolicy(itfName, caseName)
+		timeout        = t.timeoutPolicy(itfName, caseName)
+		fields         = map[string]interface{}{"InterfaceName": itfName, "CaseName": caseName}
+	)
+
+	reply := retry(ctx, retries, delay, func(attempt int) task.RunTaskReply {
+		reply := task.RunTaskReply{ItfName: itfName, CaseName: caseName, Attempts: attempt}
+		if err := t.runWithTimeout(ctx, timeout, itfName, caseName, &reply); err != nil {
+			reply.FailedReason = err.Error()
+			if attempt <= retries {
+				t.Warn(fields, "attempt %d of %d failed: %s", attempt, retries+1, err)
+			}
+			return reply
+		}
+		reply.Status = 1
+		return reply
+	})
+	reply.Duration = time.Since(begin)
+
+	switch {
+	case reply.Passed():
+		t.Info(fields, "case assert successful")
+	case t.cfg.IsQuarantined(itfName, caseName):
+		reply.Quarantined = true
+		t.Warn(fields, "quarantined case failed: %s", reply.FailedReason)
+	default:
+		t.Error(fields, reply.FailedReason)
+	}
+	return reply, nil
+}
+
+// retryPolicy returns the number of retries and the delay between the attempts of the case,
+// the case overrides its interface which overrides the config.
+func (t *taskService) retryPolicy(itfName, caseName string) (int, time.Duration) {
+	retries, delay := t.cfg.Retries, t.cfg.RetryDelay
+	if itf := t.caseProvider.GetItfFromItfName(itfName); itf != nil {
+		if itf.Retries != nil {
+			retries = *itf.Retries
+		}
+		if itf.RetryDelay != nil {
+			delay = *itf.RetryDelay
+		}
+	}
+	if c := t.caseProvider.GetAllCaseFromCaseName(itfName, caseName); c != nil {
+		if c.Retries != nil {
+			retries = *c.Retries
+		}
+		if c.RetryDelay != nil {
+			delay = *c.RetryDelay
+		}
+	}
+	return retries, time.Duration(delay) * time.Millisecond
+}
+
+// runWithTimeout run the case with a ctx canceled after the timeout, a timeout of 0 only follows the ctx.
+func (t *taskService) runWithTimeout(ctx context.Context, timeout time.Duration, itfName, caseName string, record *task.RunTaskReply) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := t.Run(ctx, itfName, caseName, record)
+	switch {
+	case err == nil:
+		return nil
+	case timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("the case timed out after %s: %v", timeout, err)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("the case was canceled: %v", err)
+	}
+	return err
+}
+
+// timeoutPolicy returns the timeout of each attempt of the case,
+// the case overrides its interface which overrides the config.
+func (t *taskService) timeoutPolicy(itfName, caseName string) time.Duration {
+	timeout := t.cfg.Timeout
+	if itf := t.caseProvider.GetItfFromItfName(itfName); itf != nil && itf.Timeout != nil {
+		timeout = *itf.Timeout
+	}
+	if c := t.caseProvider.GetAllCaseFromCaseName(itfName, caseName); c != nil && c.Timeout != nil {
+		timeout = *c.Timeout
+	}
+	return time.Duration(timeout) * time.Millisecond
+}
+
+// retry run the attempt until it passes or the retries are exhausted, the attempts start from 1.
+func retry(ctx context.Context, retries int, delay time.Duration, run func(attempt int) task.RunTaskReply) task.RunTaskReply {
+	for attempt := 1; ; attempt++ {
+		reply := run(attempt)
+		if reply.Passed() || attempt > retries {
+			return reply
+		}
+
+		select {
+		case <-ctx.Done():
+			return reply
+		case <-time.After(delay):
+		}
+	}
 }
@@ -0,0 +1,172 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package taskserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/caseprovider"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/types/task"
+)
+
+type fakeCaseProvider struct {
+	caseprovider.Provider
+	itf *mbcase.ItfTask
+	dir string
+}
+
+func (f *fakeCaseProvider) GetAllItfWithFileInfo() []*mbcase.ItfTaskWithFileInfo {
+	return []*mbcase.ItfTaskWithFileInfo{{Dirpath: f.dir, Filename: "user.case.json", ItfTask: f.itf}}
+}
+
+func (f *fakeCaseProvider) GetItfFromItfName(serviceName string) *mbcase.ItfTask {
+	if f.itf.ServiceName == serviceName {
+		return f.itf
+	}
+	return nil
+}
+
+func (f *fakeCaseProvider) GetAllCaseFromCaseName(serviceName, caseName string) *mbcase.CaseTask {
+	for _, c := range f.itf.Cases {
+		if f.itf.ServiceName == serviceName && c.Name == caseName {
+			return c
+		}
+	}
+	return nil
+}
+
+func Test_taskService_retryPolicy(t *testing.T) {
+	one, two := 1, 2
+	delay := int64(50)
+	itf := &mbcase.ItfTask{
+		TaskInfo: &mbcase.TaskInfo{ServiceName: "a"},
+		Retries:  &two,
+		Cases: []*mbcase.CaseTask{
+			{Name: "inherited"},
+			{Name: "overridden", Retries: &one, RetryDelay: &delay},
+		},
+	}
+	cfg := NewConfig()
+	cfg.Retries, cfg.RetryDelay = 3, 10
+	ts := &taskService{cfg: cfg, caseProvider: &fakeCaseProvider{itf: itf}}
+
+	tests := []struct {
+		itfName, caseName string
+		wantRetries       int
+		wantDelay         time.Duration
+	}{
+		{"a", "inherited", 2, 10 * time.Millisecond},
+		{"a", "overridden", 1, 50 * time.Millisecond},
+		{"b", "unknown", 3, 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		retries, delay := ts.retryPolicy(tt.itfName, tt.caseName)
+		if retries != tt.wantRetries || delay != tt.wantDelay {
+			t.Errorf("retryPolicy(%s, %s) = %d, %s, want %d, %s",
+				tt.itfName, tt.caseName, retries, delay, tt.wantRetries, tt.wantDelay)
+		}
+	}
+}
+
+func Test_taskService_timeoutPolicy(t *testing.T) {
+	itfTimeout, caseTimeout, noTimeout := int64(2000), int64(500), int64(0)
+	itf := &mbcase.ItfTask{
+		TaskInfo: &mbcase.TaskInfo{ServiceName: "a"},
+		Timeout:  &itfTimeout,
+		Cases: []*mbcase.CaseTask{
+			{Name: "inherited"},
+			{Name: "overridden", Timeout: &caseTimeout},
+			{Name: "disabled", Timeout: &noTimeout},
+		},
+	}
+	ts := &taskService{cfg: NewConfig(), caseProvider: &fakeCaseProvider{itf: itf}}
+
+	tests := []struct {
+		itfName, caseName string
+		want              time.Duration
+	}{
+		{"a", "inherited", 2 * time.Second},
+		{"a", "overridden", 500 * time.Millisecond},
+		{"a", "disabled", 0},
+		{"b", "unknown", 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := ts.timeoutPolicy(tt.itfName, tt.caseName); got != tt.want {
+			t.Errorf("timeoutPolicy(%s, %s) = %s, want %s", tt.itfName, tt.caseName, got, tt.want)
+		}
+	}
+}
+
+func Test_retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		passAt       int
+		cancel       bool
+		wantAttempts int
+		wantPassed   bool
+	}{
+		{name: "passed at once", retries: 2, passAt: 1, wantAttempts: 1, wantPassed: true},
+		{name: "passed on retry", retries: 2, passAt: 3, wantAttempts: 3, wantPassed: true},
+		{name: "retries exhausted", retries: 1, passAt: 3, wantAttempts: 2},
+		{name: "no retry", retries: 0, passAt: 2, wantAttempts: 1},
+		{name: "canceled", retries: 5, passAt: 6, cancel: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			var attempts int
+			reply := retry(ctx, tt.retries, time.Millisecond, func(attempt int) task.RunTaskReply {
+				attempts++
+				if attempt >= tt.passAt {
+					return task.RunTaskReply{Status: 1, Attempts: attempt}
+				}
+				return task.RunTaskReply{Attempts: attempt}
+			})
+			if attempts != tt.wantAttempts || reply.Attempts != tt.wantAttempts || reply.Passed() != tt.wantPassed {
+				t.Errorf("retry() attempts = %d, reply = %+v", attempts, reply)
+			}
+		})
+	}
+}
+
+func TestConfig_IsQuarantined(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Quarantine = []string{"a", "b/b1"}
+	for _, tt := range []struct {
+		itfName, caseName string
+		want              bool
+	}{
+		{"a", "a1", true},
+		{"b", "b1", true},
+		{"b", "b2", false},
+		{"ab", "b1", false},
+	} {
+		if got := cfg.IsQuarantined(tt.itfName, tt.caseName); got != tt.want {
+			t.Errorf("IsQuarantined(%s, %s) = %v, want %v", tt.itfName, tt.caseName, got, tt.want)
+		}
+	}
+}
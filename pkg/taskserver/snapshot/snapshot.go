@@ -0,0 +1,164 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+)
+
+// Dir the directory next to the case files holding their snapshots.
+const Dir = "__snapshots__"
+
+// Path returns the snapshot file of a case file.
+func Path(caseFile string) string {
+	return filepath.Join(filepath.Dir(caseFile), Dir, filepath.Base(caseFile)+".snap.json")
+}
+
+// snapshots the snapshots of a case file. key: serviceName, case name.
+type snapshots map[string]map[string]interface{}
+
+// Store reads and writes the snapshot files, the cases of a file share it so that the accesses are serialized.
+type Store struct {
+	lock   sync.Mutex
+	update bool
+}
+
+// NewStore returns a store, update whether the existing snapshots are rewritten instead of compared.
+func NewStore(update bool) *Store {
+	return &Store{update: update}
+}
+
+// Match compare the actual value with the snapshot of the case in the file and returns the changes from the snapshot,
+// the snapshot is written (written is true) when the case has none yet or when the store updates the snapshots.
+func (s *Store) Match(file, itfName, caseName string, actual interface{}) (written bool, changes []*assert.Change, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	snaps, err := read(file)
+	if err != nil {
+		return false, nil, err
+	}
+
+	expected, exists := snaps[itfName][caseName]
+	if exists {
+		if changes = assert.Diff(expected, actual); len(changes) == 0 || !s.update {
+			return false, changes, nil
+		}
+	}
+
+	if snaps[itfName] == nil {
+		snaps[itfName] = make(map[string]interface{})
+	}
+	snaps[itfName][caseName] = actual
+	return true, nil, write(file, snaps)
+}
+
+func read(file string) (snapshots, error) {
+	snaps := make(snapshots)
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return snaps, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read the snapshot file [%s] failed: %v", file, err)
+	}
+
+	if err := json.Unmarshal(b, &snaps); err != nil {
+		return nil, fmt.Errorf("the snapshot file [%s] is not valid: %v", file, err)
+	}
+	return snaps, nil
+}
+
+func write(file string, snaps snapshots) error {
+	// the snapshots are reviewed in the pull requests, they are indented with sorted keys.
+	b, err := json.MarshalIndent(snaps, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return fmt.Errorf("create the snapshot directory failed: %v", err)
+	}
+	if err := ioutil.WriteFile(file, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("write the snapshot file [%s] failed: %v", file, err)
+	}
+	return nil
+}
+
+// Normalize returns the value as a JSON value (a string holding a JSON document is decoded) without the ignored paths.
+// the paths are JSON pointers (e.g. /data/createdAt) whose "*" token matches every key or index (e.g. /data/items/*/id).
+func Normalize(v interface{}, ignore []string) (interface{}, error) {
+	out := assert.JSONValue(v)
+	for _, p := range ignore {
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("invalid snapshot ignore path [%s], it must be a JSON pointer like /data/id", p)
+		}
+
+		tokens := strings.Split(p[1:], "/")
+		for i, token := range tokens {
+			tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		}
+		out = remove(out, tokens)
+	}
+	return out, nil
+}
+
+// remove returns the value without the values matching the tokens.
+func remove(v interface{}, tokens []string) interface{} {
+	if len(tokens) == 0 {
+		return v
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, child := range value {
+			if token != "*" && token != k {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(value, k)
+			} else {
+				value[k] = remove(child, rest)
+			}
+		}
+		return value
+	case []interface{}:
+		out := make([]interface{}, 0, len(value))
+		for i, child := range value {
+			if token == "*" || token == strconv.Itoa(i) {
+				if len(rest) == 0 {
+					continue
+				}
+				child = remove(child, rest)
+			}
+			out = append(out, child)
+		}
+		return out
+	}
+	return v
+}
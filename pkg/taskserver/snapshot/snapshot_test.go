@@ -0,0 +1,138 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package snapshot
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+)
+
+func TestPath(t *testing.T) {
+	got := Path(filepath.Join("tests", "cases", "user.case.json"))
+	want := filepath.Join("tests", "cases", Dir, "user.case.json.snap.json")
+	if got != want {
+		t.Errorf("Path() = %s, want %s", got, want)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	body := `{"id": 7, "createdAt": "2022-09-11", "a/b": 1, "items": [{"id": 1, "name": "x"}, {"id": 2, "name": "y"}]}`
+	tests := []struct {
+		name    string
+		ignore  []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no ignored path",
+			want: body,
+		},
+		{
+			name:   "keys",
+			ignore: []string{"/id", "/createdAt", "/missing/key"},
+			want:   `{"a/b": 1, "items": [{"id": 1, "name": "x"}, {"id": 2, "name": "y"}]}`,
+		},
+		{
+			name:   "wildcard",
+			ignore: []string{"/items/*/id"},
+			want:   `{"id": 7, "createdAt": "2022-09-11", "a/b": 1, "items": [{"name": "x"}, {"name": "y"}]}`,
+		},
+		{
+			name:   "array index and escaped token",
+			ignore: []string{"/items/0", "/a~1b"},
+			want:   `{"id": 7, "createdAt": "2022-09-11", "items": [{"id": 2, "name": "y"}]}`,
+		},
+		{
+			name:    "not a pointer",
+			ignore:  []string{"items.id"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(body, tt.ignore)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, assert.JSONValue(tt.want)) {
+				t.Errorf("Normalize() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_Match(t *testing.T) {
+	file := filepath.Join(t.TempDir(), Dir, "user.case.json.snap.json")
+	first := assert.JSONValue(`{"name": "John", "age": 55}`)
+	second := assert.JSONValue(`{"name": "John", "age": 56}`)
+
+	s := NewStore(false)
+	if written, changes, err := s.Match(file, "getUser", "ok", first); err != nil || !written || changes != nil {
+		t.Fatalf("Match() = %v, %v, %v, want the snapshot written", written, changes, err)
+	}
+	if written, changes, err := s.Match(file, "getUser", "ok", first); err != nil || written || len(changes) != 0 {
+		t.Errorf("Match() = %v, %v, %v, want a match", written, changes, err)
+	}
+
+	written, changes, err := s.Match(file, "getUser", "ok", second)
+	if err != nil || written || len(changes) != 1 || changes[0].Path != "/age" {
+		t.Errorf("Match() = %v, %v, %v, want the change of /age", written, changes, err)
+	}
+
+	// another case of the file keeps the snapshot of the first one.
+	if written, _, err := s.Match(file, "getUser", "other", second); err != nil || !written {
+		t.Errorf("Match() = %v, %v, want the snapshot of the other case written", written, err)
+	}
+
+	if written, changes, err := NewStore(true).Match(file, "getUser", "ok", second); err != nil || !written || changes != nil {
+		t.Errorf("Match() = %v, %v, %v, want the snapshot updated", written, changes, err)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "getUser": {
+    "ok": {
+      "age": 56,
+      "name": "John"
+    },
+    "other": {
+      "age": 56,
+      "name": "John"
+    }
+  }
+}
+`
+	if string(b) != want {
+		t.Errorf("the snapshot file = %s, want %s", b, want)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Match(file, "getUser", "ok", first); err == nil || !strings.Contains(err.Error(), "is not valid") {
+		t.Errorf("Match() error = %v, want the invalid file error", err)
+	}
+}
@@ -19,6 +19,7 @@ package interact
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/rand"
 	"net/textproto"
 	"reflect"
@@ -27,6 +28,7 @@ import (
 
 	"github.com/alsritter/middlebaby/pkg/util"
 	"github.com/alsritter/middlebaby/pkg/util/common"
+	"github.com/alsritter/middlebaby/pkg/util/matcher"
 	"github.com/gogo/protobuf/proto"
 )
 
@@ -43,6 +45,27 @@ const (
 type ImposterMockCase struct {
 	Request  Request  `json:"request" yaml:"request"`
 	Response Response `json:"response" yaml:"response"`
+	// Priority the mocks with a higher priority are matched first,
+	// the mocks with the same priority are matched in the case -> interface -> global order.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
+
+	// Scenario the state machine the mock belongs to, the mocks of a scenario share its state.
+	Scenario string `json:"scenario,omitempty" yaml:"scenario,omitempty"`
+	// RequiredState the mock only matches when the scenario is in this state, empty matches any state.
+	RequiredState string `json:"requiredState,omitempty" yaml:"requiredState,omitempty"`
+	// NewState the state of the scenario after the mock has been matched, empty keeps the state.
+	NewState string `json:"newState,omitempty" yaml:"newState,omitempty"`
+}
+
+// ScenarioStarted the initial state of every scenario.
+const ScenarioStarted = "Started"
+
+// Call a request received by the mock services while a case is running.
+type Call struct {
+	Request Request `json:"request"`
+	// Matched whether a mock has been found for the request.
+	Matched bool      `json:"matched"`
+	Time    time.Time `json:"time"`
 }
 
 // Delay returns delay for response that user can specify in imposter config
@@ -59,6 +82,35 @@ type Request struct {
 	Header   map[string][]string `json:"header" yaml:"header"`
 	Query    map[string][]string `json:"query" yaml:"query"`
 	Body     interface{}         `json:"body" yaml:"body"`
+	// Matchers the additional conditions of a mock request.
+	Matchers *RequestMatchers `json:"matchers,omitempty" yaml:"matchers,omitempty"`
+}
+
+// RequestMatchers the matchers of the request fields, key: header or query name.
+type RequestMatchers struct {
+	Header map[string]*matcher.Matcher `json:"header,omitempty" yaml:"header,omitempty"`
+	Query  map[string]*matcher.Matcher `json:"query,omitempty" yaml:"query,omitempty"`
+	Body   []*matcher.Matcher          `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// Validate check the matchers, e.g. the regular expressions and the JSON schemas.
+func (r *RequestMatchers) Validate() error {
+	for name, mt := range r.Header {
+		if err := mt.Validate(); err != nil {
+			return fmt.Errorf("header [%s]: %v", name, err)
+		}
+	}
+	for name, mt := range r.Query {
+		if err := mt.Validate(); err != nil {
+			return fmt.Errorf("query [%s]: %v", name, err)
+		}
+	}
+	for _, mt := range r.Body {
+		if err := mt.Validate(); err != nil {
+			return fmt.Errorf("body %s: %v", mt.JSONPath, err)
+		}
+	}
+	return nil
 }
 
 func (r *Request) GetBodyString() string {
@@ -85,6 +137,10 @@ type Response struct {
 	Body    interface{}         `json:"body" yaml:"body"`
 	Trailer map[string][]string `json:"trailer" yaml:"trailer"`
 	Delay   *ResponseDelay      `json:"delay" yaml:"delay"`
+	// Template whether the header values and the body are rendered as Go templates with the incoming request.
+	Template bool `json:"template" yaml:"template"`
+	// Fault the failure simulated instead of (or while) sending the response.
+	Fault *ResponseFault `json:"fault,omitempty" yaml:"fault,omitempty"`
 }
 
 func (r *Response) GetByteData() ([]byte, error) {
@@ -129,6 +185,51 @@ func (d *ResponseDelay) GetDelay() time.Duration {
 	return time.Duration(d.Delay+offset) * time.Millisecond
 }
 
+// FaultType defines the failures a mock can simulate.
+type FaultType string
+
+// defines a set of known faults
+const (
+	// FaultError respond the fault status, 503 for HTTP and UNAVAILABLE for gRPC by default.
+	FaultError FaultType = "error"
+	// FaultConnectionReset reset the connection (TCP RST) without responding.
+	FaultConnectionReset FaultType = "connectionReset"
+	// FaultEmptyReply close the connection without responding (HTTP), reset the stream (gRPC).
+	FaultEmptyReply FaultType = "emptyReply"
+	// FaultTruncatedBody send the header and a part of the body, then drop the connection. (HTTP only)
+	FaultTruncatedBody FaultType = "truncatedBody"
+	// FaultThrottle send the body at the bandwidth of the fault.
+	FaultThrottle FaultType = "throttle"
+	// FaultStreamReset reset the stream (RST_STREAM). (gRPC only)
+	FaultStreamReset FaultType = "streamReset"
+	// FaultDeadlineExceeded wait until the deadline of the call and respond DEADLINE_EXCEEDED. (gRPC only)
+	FaultDeadlineExceeded FaultType = "deadlineExceeded"
+)
+
+// ResponseFault represent a failure of the mocked dependency.
+type ResponseFault struct {
+	Type FaultType `json:"type" yaml:"type"`
+	// Percentage the probability (0-100) of the fault on each call, 0 means always.
+	Percentage float64 `json:"percentage" yaml:"percentage"`
+	// Status the status (HTTP status or gRPC code) responded by the error fault.
+	Status int `json:"status" yaml:"status"`
+	// TruncateAt the number of body bytes sent by the truncated body fault, 0 means half of the body.
+	TruncateAt int `json:"truncateAt" yaml:"truncateAt"`
+	// BytesPerSecond the bandwidth of the throttle fault.
+	BytesPerSecond int `json:"bytesPerSecond" yaml:"bytesPerSecond"`
+}
+
+// Hit whether the fault happens on this call.
+func (f *ResponseFault) Hit() bool {
+	if f == nil {
+		return false
+	}
+	if f.Percentage <= 0 || f.Percentage >= 100 {
+		return true
+	}
+	return rand.Float64()*100 < f.Percentage
+}
+
 // NewDefaultResponse is used to create default response
 func NewDefaultResponse(request *Request) *Response {
 	var code int
@@ -48,16 +48,30 @@ type TaskInfo struct {
 	ServicePath string `json:"servicePath" yaml:"servicePath"`
 
 	// if grpc, need protofile path
-	ServiceProtoFile string `json:"serviceProtoFile" yaml:"servicePath"`
+	ServiceProtoFile string `json:"serviceProtoFile" yaml:"serviceProtoFile"`
 }
 
 // ItfTask interface level.
 type ItfTask struct {
-	*TaskInfo
-	SetUp    []*Command                   `json:"setup" yaml:"setUp"`
+	*TaskInfo `yaml:",inline"`
+	// Serial the cases of this interface cannot run concurrently with any other case. (e.g. they share database state)
+	Serial bool `json:"serial" yaml:"serial"`
+	// Tags the tags of every case of the interface, they are used by the filter expressions of the runs.
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Retries the default number of retries of the cases, see CaseTask.Retries.
+	Retries *int `json:"retries,omitempty" yaml:"retries,omitempty"`
+	// RetryDelay the default retry delay of the cases, see CaseTask.RetryDelay.
+	RetryDelay *int64 `json:"retryDelay,omitempty" yaml:"retryDelay,omitempty"`
+	// Timeout the default timeout of the cases, see CaseTask.Timeout.
+	Timeout  *int64                       `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	SetUp    []*Command                   `json:"setup" yaml:"setup"`
 	Mocks    []*interact.ImposterMockCase `json:"mocks" yaml:"mocks"`
 	TearDown []*Command                   `json:"teardown" yaml:"teardown"`
-	Cases    []*CaseTask                  `json:"cases" yaml:"cases"`
+	// BeforeRequest the hooks run before the request of every case, before the hooks of the case.
+	BeforeRequest []*Command `json:"beforeRequest,omitempty" yaml:"beforeRequest,omitempty"`
+	// AfterResponse the hooks run after the response of every case, before the hooks of the case.
+	AfterResponse []*Command  `json:"afterResponse,omitempty" yaml:"afterResponse,omitempty"`
+	Cases         []*CaseTask `json:"cases" yaml:"cases"`
 }
 
 // CaseTask case level
@@ -69,6 +83,40 @@ type CaseTask struct {
 	Request     *CaseRequest                 `json:"request" yaml:"request"`
 	Assert      *Assert                      `json:"assert" yaml:"assert"`
 	TearDown    []*Command                   `json:"teardown" yaml:"teardown"`
+	// BeforeRequest the hooks which may modify the request before it is sent. (e.g. sign it)
+	BeforeRequest []*Command `json:"beforeRequest,omitempty" yaml:"beforeRequest,omitempty"`
+	// AfterResponse the hooks which may modify the response before it is extracted and asserted. (e.g. decode a token)
+	AfterResponse []*Command `json:"afterResponse,omitempty" yaml:"afterResponse,omitempty"`
+	// Extract save values of the response into the run variables. key: variable name, value: expression.
+	// e.g. "body.data.id" (gjson path of the response body), "header.X-Token", "statusCode"
+	Extract map[string]string `json:"extract" yaml:"extract"`
+	// Tags the tags of the case in addition to the tags of its interface.
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Skip the reason the case is not run, the case is reported as skipped.
+	Skip string `json:"skip,omitempty" yaml:"skip,omitempty"`
+	// Only when any case is marked, only the marked cases are run.
+	Only bool `json:"only,omitempty" yaml:"only,omitempty"`
+	// Retries the number of times the case is run again after a failure,
+	// every attempt runs the setup and teardown commands.
+	Retries *int `json:"retries,omitempty" yaml:"retries,omitempty"`
+	// RetryDelay the milliseconds to wait before each retry.
+	RetryDelay *int64 `json:"retryDelay,omitempty" yaml:"retryDelay,omitempty"`
+	// Timeout the milliseconds an attempt of the case (setup, request and asserts) may take, 0 means no timeout.
+	Timeout *int64 `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Parameters the case is expanded into one case per row when it is loaded,
+	// "${<parameter>}" in the case is replaced by the value of the row.
+	Parameters *CaseParameters `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// CaseParameters the parameter table of a data-driven case.
+type CaseParameters struct {
+	// Rows the inline rows, key: parameter name.
+	Rows []map[string]interface{} `json:"rows" yaml:"rows"`
+	// File the rows are read from a CSV file (the first line is the header), or from a JSON or YAML file
+	// (a list of objects). The path is relative to the case file.
+	File string `json:"file" yaml:"file"`
+	// Name the parameter whose value names the expanded cases, the row number by default.
+	Name string `json:"name" yaml:"name"`
 }
 
 // CaseRequest case request data.
@@ -111,11 +159,33 @@ type Response struct {
 	Header     map[string]string `json:"header" yaml:"header"`
 	Data       interface{}       `json:"data" yaml:"data"`
 	StatusCode int               `json:"statusCode" yaml:"statusCode"`
+	// Snapshot assert the response data against the snapshot stored next to the case file,
+	// the first run writes it. (only for the expected response)
+	Snapshot bool `json:"snapshot,omitempty" yaml:"snapshot,omitempty"`
+	// SnapshotIgnore the JSON pointers of the values left out of the snapshot in addition to task.snapshotIgnore,
+	// e.g. "/data/createdAt", "*" matches every key or index: "/data/items/*/id".
+	SnapshotIgnore []string `json:"snapshotIgnore,omitempty" yaml:"snapshotIgnore,omitempty"`
 }
 
 type Assert struct {
-	Response     Response       `json:"response" yaml:"response"`
-	OtherAsserts []CommonAssert `json:"otherAsserts" yaml:"otherAsserts"`
+	Response     Response         `json:"response" yaml:"response"`
+	OtherAsserts []CommonAssert   `json:"otherAsserts" yaml:"otherAsserts"`
+	MockCalls    []MockCallAssert `json:"mockCalls" yaml:"mockCalls"`
+	// MockCallsOrdered whether the mock calls must be received in the declared order.
+	MockCallsOrdered bool `json:"mockCallsOrdered" yaml:"mockCallsOrdered"`
+	// Soft whether every assertion is evaluated and all the failures are reported, overrides task.softAssert.
+	Soft *bool `json:"soft,omitempty" yaml:"soft,omitempty"`
+}
+
+// MockCallAssert verify the requests the target sent to a mocked dependency.
+type MockCallAssert struct {
+	Method string `json:"method" yaml:"method"` // empty matches any method.
+	Host   string `json:"host" yaml:"host"`
+	Path   string `json:"path" yaml:"path"` // the same path pattern as the mocks, e.g. /users/{id}.
+	// Times the exact number of calls, nil means at least once.
+	Times  *int              `json:"times" yaml:"times"`
+	Header map[string]string `json:"header" yaml:"header"`
+	Body   interface{}       `json:"body" yaml:"body"`
 }
 
 func (a *Assert) ResponseDataString() string {
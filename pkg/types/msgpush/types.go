@@ -4,6 +4,8 @@ type MsgType string
 
 const (
 	Capture MsgType = "capture"
+	// Run the progress of a batch run.
+	Run MsgType = "run"
 )
 
 // WsMessage ...
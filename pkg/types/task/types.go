@@ -1,6 +1,55 @@
 package task
 
+import (
+	"time"
+
+	"github.com/alsritter/middlebaby/pkg/types/interact"
+	"github.com/alsritter/middlebaby/pkg/types/mbcase"
+	"github.com/alsritter/middlebaby/pkg/util/assert"
+)
+
 type RunTaskReply struct {
 	Status       int32  `yaml:"status" json:"status"`
 	FailedReason string `yaml:"failedReason" json:"failedReason"`
+
+	ItfName  string        `yaml:"itfName" json:"itfName"`
+	CaseName string        `yaml:"caseName" json:"caseName"`
+	Duration time.Duration `yaml:"duration" json:"duration"`
+
+	Request  *mbcase.CaseRequest `yaml:"request" json:"request"`
+	Response *mbcase.Response    `yaml:"response" json:"response"` // the actual response of the target.
+	// the requests the target sent to the mocked dependencies.
+	MockCalls []interact.Call `yaml:"mockCalls" json:"mockCalls"`
+
+	AssertError   string `yaml:"assertError" json:"assertError"`
+	SetupError    string `yaml:"setupError" json:"setupError"`
+	TeardownError string `yaml:"teardownError" json:"teardownError"`
+	// AssertErrors the failed assertions, all of them in the soft assert mode.
+	AssertErrors assert.Errors `yaml:"assertErrors,omitempty" json:"assertErrors,omitempty"`
+	// SkipReason the reason the case has not been run.
+	SkipReason string `yaml:"skipReason,omitempty" json:"skipReason,omitempty"`
+	// Attempts the number of times the case has been run, more than 1 when it has been retried.
+	Attempts int `yaml:"attempts,omitempty" json:"attempts,omitempty"`
+	// Quarantined the case is quarantined, its failure does not fail the run.
+	Quarantined bool `yaml:"quarantined,omitempty" json:"quarantined,omitempty"`
+}
+
+// Passed whether the case passed.
+func (r *RunTaskReply) Passed() bool {
+	return r.Status == 1
+}
+
+// PassedOnRetry whether the case passed after having failed.
+func (r *RunTaskReply) PassedOnRetry() bool {
+	return r.Passed() && r.Attempts > 1
+}
+
+// FailedQuarantined whether the case failed but is quarantined.
+func (r *RunTaskReply) FailedQuarantined() bool {
+	return !r.Passed() && !r.Skipped() && r.Quarantined
+}
+
+// Skipped whether the case has been skipped.
+func (r *RunTaskReply) Skipped() bool {
+	return r.SkipReason != ""
 }
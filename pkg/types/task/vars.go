@@ -0,0 +1,60 @@
+package task
+
+import (
+	"context"
+	"sync"
+)
+
+type varsKey struct{}
+
+// Vars the variables shared by the cases of a run, cases write them by "extract" and read them by "{{ .vars.name }}".
+type Vars struct {
+	lock sync.RWMutex
+	m    map[string]interface{}
+}
+
+func NewVars() *Vars {
+	return &Vars{m: make(map[string]interface{})}
+}
+
+func (v *Vars) Set(name string, value interface{}) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.m[name] = value
+}
+
+func (v *Vars) Get(name string) (interface{}, bool) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	value, ok := v.m[name]
+	return value, ok
+}
+
+// All returns a copy of all variables.
+func (v *Vars) All() map[string]interface{} {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	out := make(map[string]interface{}, len(v.m))
+	for k, value := range v.m {
+		out[k] = value
+	}
+	return out
+}
+
+// TemplateData the data used to render case and mock templates.
+func (v *Vars) TemplateData() map[string]interface{} {
+	return map[string]interface{}{"vars": v.All()}
+}
+
+// WithVars returns a context carrying the variables of the run.
+func WithVars(ctx context.Context, vars *Vars) context.Context {
+	return context.WithValue(ctx, varsKey{}, vars)
+}
+
+// VarsFromContext returns the variables of the run, a single case run gets its own empty variables.
+func VarsFromContext(ctx context.Context) *Vars {
+	if vars, ok := ctx.Value(varsKey{}).(*Vars); ok {
+		return vars
+	}
+	return NewVars()
+}
@@ -19,11 +19,13 @@ package assert
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 
+	"github.com/alsritter/middlebaby/pkg/util/common"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
 )
 
@@ -32,6 +34,39 @@ func So(log logger.Logger, assertType string, actual interface{}, expected inter
 	return NewAssert(log, assertType, actual, expected).assert()
 }
 
+// SoAll verify every field of the expected value instead of stopping at the first mismatch,
+// the mismatches are returned as Errors.
+func SoAll(log logger.Logger, assertType string, actual interface{}, expected interface{}) error {
+	a := NewAssert(log, assertType, actual, expected)
+	a.soft = true
+	if err := a.assert(); err != nil {
+		return err
+	}
+	return a.errs.Err()
+}
+
+// SoContext So or SoAll according to the assert mode of the ctx.
+func SoContext(ctx context.Context, log logger.Logger, assertType string, actual interface{}, expected interface{}) error {
+	if IsSoft(ctx) {
+		return SoAll(log, assertType, actual, expected)
+	}
+	return So(log, assertType, actual, expected)
+}
+
+type softKey struct{}
+
+// WithSoft returns a context carrying the assert mode, in the soft mode every expectation is evaluated and all
+// the failures are returned together.
+func WithSoft(ctx context.Context, soft bool) context.Context {
+	return context.WithValue(ctx, softKey{}, soft)
+}
+
+// IsSoft whether the assertions run in the soft mode.
+func IsSoft(ctx context.Context) bool {
+	soft, _ := ctx.Value(softKey{}).(bool)
+	return soft
+}
+
 var (
 	ErrorTypeNotEqual    = errors.New("type inconsistency")
 	ErrorMapKeyInvalided = errors.New("key is invalid")
@@ -41,39 +76,124 @@ var (
 
 // cases assert error types
 type AssertError struct {
-	Type      string
-	Err       error
-	FieldName string
-	Actual    interface{}
-	Expected  interface{}
+	Type      string      `json:"source"` // the assertion the error comes from.
+	Err       error       `json:"-"`
+	FieldName string      `json:"field,omitempty"`
+	Actual    interface{} `json:"-"`
+	Expected  interface{} `json:"-"`
+	// Path the JSON pointer of the wrong field in the asserted value.
+	Path string `json:"path,omitempty"`
+	// Changes the differences from the expected value to the actual value, they replace the whole values
+	// which are unreadable for large bodies.
+	Changes []*Change `json:"changes,omitempty"`
+}
+
+// MarshalJSON the cause of the error is marshaled as its message.
+func (e *AssertError) MarshalJSON() ([]byte, error) {
+	type plain AssertError
+	out := struct {
+		*plain
+		Reason string `json:"reason,omitempty"`
+	}{plain: (*plain)(e)}
+	if e.Err != nil {
+		out.Reason = e.Err.Error()
+	}
+	return json.Marshal(out)
 }
 
 func (e *AssertError) Error() string {
+	return e.Render(false)
+}
+
+// Render returns the message of the error followed by the diff of the expected and the actual value,
+// colour adds ANSI colours for terminals.
+func (e *AssertError) Render(colour bool) string {
 	bf := bytes.Buffer{}
 	bf.WriteString("[" + e.Type + "]")
 	if e.Err != nil {
 		bf.WriteString(" error: " + e.Err.Error())
 	}
-
-	bf.WriteString(fmt.Sprintf(`
-		expected return value: [%v] 
-		actual return value: [%v]
-	`, e.Expected, e.Actual))
 	if e.FieldName != "" {
 		bf.WriteString(fmt.Sprintf(" wrong field: [%s]", e.FieldName))
 	}
+
+	// the errors which are not a mismatch (e.g. a failed query) have no diff.
+	if changes := e.Diff(); len(changes) > 0 {
+		bf.WriteString("\n--- expected\n+++ actual\n")
+		bf.WriteString(RenderDiff(changes, colour))
+	}
 	return bf.String()
 }
 
+// Diff returns the changes of the error, the whole values are compared when they have not been set.
+func (e *AssertError) Diff() []*Change {
+	if e.Changes != nil || (e.Expected == nil && e.Actual == nil) {
+		return e.Changes
+	}
+	return []*Change{{Type: ChangeChanged, Path: e.Path, From: e.Expected, To: e.Actual}}
+}
+
 func NewAssertError(assertType string, err error, actual interface{}, expected interface{}, fieldName string) *AssertError {
 	return &AssertError{Type: assertType, Err: err, Actual: actual, Expected: expected, FieldName: fieldName}
 }
 
+// Errors the failures of the assertions evaluated in the soft mode.
+type Errors []*AssertError
+
+func (e Errors) Error() string {
+	return e.Render(false)
+}
+
+// Render returns the messages and the diffs of the errors, colour adds ANSI colours for terminals.
+func (e Errors) Render(colour bool) string {
+	if len(e) == 1 {
+		return e[0].Render(colour)
+	}
+
+	bf := bytes.Buffer{}
+	bf.WriteString(fmt.Sprintf("%d assertions failed:", len(e)))
+	for i, err := range e {
+		bf.WriteString(fmt.Sprintf("\n%d. %s", i+1, err.Render(colour)))
+	}
+	return bf.String()
+}
+
+// Err returns nil when there is no failure, so that an empty Errors is not a non-nil error.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Append add the failures of err, an error which is not an assert error is recorded with the source.
+func (e Errors) Append(source string, err error) Errors {
+	var (
+		assertErr  *AssertError
+		assertErrs Errors
+	)
+	switch {
+	case err == nil:
+		return e
+	case errors.As(err, &assertErrs):
+		return append(e, assertErrs...)
+	case errors.As(err, &assertErr):
+		return append(e, assertErr)
+	}
+	return append(e, NewAssertError(source, err, nil, nil, ""))
+}
+
 type Assert struct {
 	assertType string
 	actual     interface{}
 	expected   interface{}
 
+	// soft collect the mismatches into errs instead of returning the first one.
+	soft bool
+	errs Errors
+	// strict the objects cannot hold keys which are not expected, see common.StrictKey.
+	strict bool
+
 	log logger.Logger
 }
 
@@ -84,7 +204,7 @@ func NewAssert(log logger.Logger, assertType string, actual interface{}, expecte
 // an entry function for an assertion
 func (a *Assert) assert() error {
 	a.before()
-	return a.so(a.assertType, a.actual, a.expected)
+	return a.so(a.assertType, "", a.actual, a.expected)
 }
 
 // convert the expected and actual values to JSON
@@ -137,8 +257,31 @@ func (a *Assert) getFloat64Value(v reflect.Value) (bool, float64) {
 	return false, 0
 }
 
+// fail returns the error, or records it and returns nil in the soft mode so that the comparison goes on.
+func (a *Assert) fail(err *AssertError) error {
+	if a.soft {
+		a.errs = append(a.errs, err)
+		return nil
+	}
+	return err
+}
+
+// tailChanges returns the elements of the longer list beyond the length of the shorter one,
+// the extra actual elements are added and the missing expected elements are removed.
+func (*Assert) tailChanges(pointer string, actualRv, expectedRv reflect.Value) []*Change {
+	var changes []*Change
+	for i := expectedRv.Len(); i < actualRv.Len(); i++ {
+		changes = append(changes, &Change{Type: ChangeAdded, Path: fmt.Sprintf("%s/%d", pointer, i), To: normalize(actualRv.Index(i).Interface())})
+	}
+	for i := actualRv.Len(); i < expectedRv.Len(); i++ {
+		changes = append(changes, &Change{Type: ChangeRemoved, Path: fmt.Sprintf("%s/%d", pointer, i), From: normalize(expectedRv.Index(i).Interface())})
+	}
+	return changes
+}
+
 // split all the values of a field and compare the values set in the expected value, focusing on whether the expected value corresponds to the actual return value
-func (a *Assert) so(fieldName string, actual interface{}, expected interface{}) error {
+// the pointer is the JSON pointer of the field.
+func (a *Assert) so(fieldName, pointer string, actual interface{}, expected interface{}) error {
 	// when the corresponding Expected is nil, it is considered that the actual data does not need to be judged, and it is directly considered to be matched
 	if expected == nil {
 		return nil
@@ -147,8 +290,14 @@ func (a *Assert) so(fieldName string, actual interface{}, expected interface{})
 	actualRv := reflect.ValueOf(actual)
 	expectedRv := reflect.ValueOf(expected)
 
-	retErrFun := func(err error) error {
-		return NewAssertError(a.assertType, err, actual, expected, fieldName)
+	retErrFun := func(err error, changes ...*Change) error {
+		e := NewAssertError(a.assertType, err, actual, expected, fieldName)
+		e.Path = pointer
+		e.Changes = changes
+		if e.Changes == nil {
+			e.Changes = []*Change{{Type: ChangeChanged, Path: pointer, From: normalize(expected), To: normalize(actual)}}
+		}
+		return a.fail(e)
 	}
 
 	if IsRegExpPattern(expected) {
@@ -160,6 +309,10 @@ func (a *Assert) so(fieldName string, actual interface{}, expected interface{})
 		return nil
 	}
 
+	if ok, err := a.modifier(pointer, actual, expected, retErrFun); ok {
+		return err
+	}
+
 	ok1, actualFloat := a.getFloat64Value(actualRv)
 	ok2, expectedFloat := a.getFloat64Value(expectedRv)
 	if ok1 && ok2 {
@@ -202,19 +355,47 @@ func (a *Assert) so(fieldName string, actual interface{}, expected interface{})
 			// get all field.
 			name := expectedRv.Type().Field(i).Name
 			// recursion
-			if err := a.so(a.appendFieldName(fieldName, name), actualRv.FieldByName(name).Interface(), expectedRv.FieldByName(name).Interface()); err != nil {
+			if err := a.so(a.appendFieldName(fieldName, name), pointer+"/"+escapePointer(name), actualRv.FieldByName(name).Interface(), expectedRv.FieldByName(name).Interface()); err != nil {
 				return err
 			}
 		}
 	case reflect.Map:
+		defer func(strict bool) { a.strict = strict }(a.strict)
+		a.strict = strictMode(expectedRv, a.strict)
+		if a.strict {
+			for _, keyVal := range unexpectedKeys(actualRv, expectedRv) {
+				keyPointer := pointer + "/" + escapePointer(fmt.Sprintf("%v", keyVal.Interface()))
+				keyName := a.appendFieldName(fieldName, fmt.Sprintf("%v", keyVal.Interface()))
+				e := NewAssertError(a.assertType, ErrorUnexpectedKey, actualRv.MapIndex(keyVal).Interface(), nil, keyName)
+				e.Path = keyPointer
+				e.Changes = []*Change{{Type: ChangeAdded, Path: keyPointer, To: normalize(e.Actual)}}
+				if err := a.fail(e); err != nil {
+					return err
+				}
+			}
+		}
+
 		expectKeys := expectedRv.MapKeys()
 		for _, keyVal := range expectKeys {
+			if fmt.Sprintf("%v", keyVal.Interface()) == common.StrictKey {
+				continue
+			}
+			keyPointer := pointer + "/" + escapePointer(fmt.Sprintf("%v", keyVal.Interface()))
 			// most functions and methods never return an invalid Value.
 			if !actualRv.MapIndex(keyVal).IsValid() {
-				fieldName = fmt.Sprintf("%v", keyVal.Interface())
-				return retErrFun(ErrorMapKeyInvalided)
+				if isAbsentMarker(expectedRv.MapIndex(keyVal).Interface()) {
+					continue
+				}
+				keyName := a.appendFieldName(fieldName, fmt.Sprintf("%v", keyVal.Interface()))
+				e := NewAssertError(a.assertType, ErrorMapKeyInvalided, nil, expectedRv.MapIndex(keyVal).Interface(), keyName)
+				e.Path = keyPointer
+				e.Changes = []*Change{{Type: ChangeRemoved, Path: keyPointer, From: normalize(e.Expected)}}
+				if err := a.fail(e); err != nil {
+					return err
+				}
+				continue
 			}
-			if err := a.so(a.appendFieldName(fieldName, keyVal.String()), actualRv.MapIndex(keyVal).Interface(), expectedRv.MapIndex(keyVal).Interface()); err != nil {
+			if err := a.so(a.appendFieldName(fieldName, keyVal.String()), keyPointer, actualRv.MapIndex(keyVal).Interface(), expectedRv.MapIndex(keyVal).Interface()); err != nil {
 				return err
 			}
 		}
@@ -222,10 +403,10 @@ func (a *Assert) so(fieldName string, actual interface{}, expected interface{})
 		actualLen := actualRv.Len()
 		expectedLen := expectedRv.Len()
 		if actualLen != expectedLen {
-			return retErrFun(fmt.Errorf("%v %d != %d", ErrorLengthNotEqual, actualLen, expectedLen))
+			return retErrFun(fmt.Errorf("%v %d != %d", ErrorLengthNotEqual, actualLen, expectedLen), a.tailChanges(pointer, actualRv, expectedRv)...)
 		}
 		for i := 0; i < actualLen; i++ {
-			if err := a.so(a.appendFieldName(fieldName, fmt.Sprintf("[%d]", i)), actualRv.Index(i).Interface(), expectedRv.Index(i).Interface()); err != nil {
+			if err := a.so(a.appendFieldName(fieldName, fmt.Sprintf("[%d]", i)), fmt.Sprintf("%s/%d", pointer, i), actualRv.Index(i).Interface(), expectedRv.Index(i).Interface()); err != nil {
 				return err
 			}
 		}
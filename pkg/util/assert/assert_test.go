@@ -0,0 +1,113 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package assert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func TestSoAll(t *testing.T) {
+	actual := `{"code":1,"data":{"name":"a","tags":["x","y"],"score":3}}`
+	tests := []struct {
+		name       string
+		expected   interface{}
+		wantFields []string
+	}{
+		{
+			name:     "matched",
+			expected: map[string]interface{}{"code": 1, "data": map[string]interface{}{"name": "a"}},
+		},
+		{
+			name: "several mismatches",
+			expected: map[string]interface{}{
+				"code": 0,
+				"data": map[string]interface{}{"name": "b", "tags": []interface{}{"x", "z"}, "missing": 1},
+			},
+			wantFields: []string{"body.code", "body.data.missing", "body.data.name", "body.data.tags.[1]"},
+		},
+		{
+			name:       "length mismatch",
+			expected:   map[string]interface{}{"data": map[string]interface{}{"tags": []interface{}{"x"}}},
+			wantFields: []string{"body.data.tags"},
+		},
+	}
+	log := logger.NewDefault("test")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SoAll(log, "body", actual, tt.expected)
+			var fields []string
+			if errs, ok := err.(Errors); ok {
+				for _, e := range errs {
+					fields = append(fields, e.FieldName)
+				}
+			}
+			// the keys of a map are not visited in order, the field names start with the assert type.
+			sort.Strings(fields)
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("SoAll() fields = %v, want %v (error: %v)", fields, tt.wantFields, err)
+			}
+
+			// the hard mode stops at the first mismatch.
+			err = SoContext(context.Background(), log, "body", actual, tt.expected)
+			if (err != nil) != (len(tt.wantFields) > 0) {
+				t.Errorf("SoContext() error = %v, want %d failures", err, len(tt.wantFields))
+			}
+			var assertErr *AssertError
+			if err != nil && !errors.As(err, &assertErr) {
+				t.Errorf("SoContext() error = %T, want *AssertError", err)
+			}
+		})
+	}
+}
+
+func TestErrors_Append(t *testing.T) {
+	var errs Errors
+	errs = errs.Append("a", nil)
+	errs = errs.Append("a", NewAssertError("a", nil, 1, 2, "x"))
+	errs = errs.Append("b", Errors{NewAssertError("b", nil, 3, 4, "y"), NewAssertError("b", nil, 5, 6, "z")})
+	errs = errs.Append("c", errors.New("no result is found"))
+	if len(errs) != 4 {
+		t.Fatalf("Append() = %d errors, want 4", len(errs))
+	}
+	if errs[3].Type != "c" || errs[3].Err == nil {
+		t.Errorf("Append() of a plain error = %+v", errs[3])
+	}
+	if msg := errs.Error(); !strings.HasPrefix(msg, "4 assertions failed:") {
+		t.Errorf("Error() = %s", msg)
+	}
+	if Errors(nil).Err() != nil {
+		t.Errorf("Err() of no failure is not nil")
+	}
+
+	b, err := json.Marshal(errs[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"source":"c","reason":"no result is found"}`
+	if string(b) != want {
+		t.Errorf("MarshalJSON() = %s, want %s", b, want)
+	}
+}
@@ -0,0 +1,200 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package assert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeType defines the kinds of difference.
+type ChangeType string
+
+// defines a set of known differences
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeRemoved ChangeType = "removed"
+	ChangeChanged ChangeType = "changed"
+)
+
+// Change a difference between two values, of an assertion: from the expected value to the actual value.
+type Change struct {
+	Type ChangeType `json:"type"`
+	// Path the JSON pointer of the value, e.g. "/data/items/0/name", "/header/Content-Type".
+	Path string      `json:"path"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// Diff returns the differences between two values, they are compared as JSON values:
+// a string or bytes holding a JSON document is decoded.
+func Diff(from, to interface{}) []*Change {
+	var changes []*Change
+	diffValues("", JSONValue(from), JSONValue(to), &changes)
+	return changes
+}
+
+// JSONValue returns the value as a generic JSON value (maps, slices, float64...),
+// a string or bytes holding a JSON document is decoded.
+func JSONValue(v interface{}) interface{} {
+	return normalize(decodeJSON(v))
+}
+
+// decodeJSON returns the JSON value held by a string or bytes, other values are returned as is.
+func decodeJSON(v interface{}) interface{} {
+	switch b := v.(type) {
+	case []byte:
+		var out interface{}
+		if err := json.Unmarshal(b, &out); err == nil {
+			return out
+		}
+		return string(b)
+	case string:
+		var out interface{}
+		if err := json.Unmarshal([]byte(b), &out); err == nil {
+			return out
+		}
+	}
+	return v
+}
+
+// normalize returns the value as a generic JSON value.
+func normalize(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func diffValues(path string, from, to interface{}, changes *[]*Change) {
+	switch f := from.(type) {
+	case map[string]interface{}:
+		if t, ok := to.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(f)+len(t))
+			for k := range f {
+				keys = append(keys, k)
+			}
+			for k := range t {
+				if _, ok := f[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				p := path + "/" + escapePointer(k)
+				fv, inFrom := f[k]
+				tv, inTo := t[k]
+				switch {
+				case !inFrom:
+					*changes = append(*changes, &Change{Type: ChangeAdded, Path: p, To: tv})
+				case !inTo:
+					*changes = append(*changes, &Change{Type: ChangeRemoved, Path: p, From: fv})
+				default:
+					diffValues(p, fv, tv, changes)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if t, ok := to.([]interface{}); ok {
+			for i := 0; i < len(f) || i < len(t); i++ {
+				p := fmt.Sprintf("%s/%d", path, i)
+				switch {
+				case i >= len(f):
+					*changes = append(*changes, &Change{Type: ChangeAdded, Path: p, To: t[i]})
+				case i >= len(t):
+					*changes = append(*changes, &Change{Type: ChangeRemoved, Path: p, From: f[i]})
+				default:
+					diffValues(p, f[i], t[i], changes)
+				}
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, &Change{Type: ChangeChanged, Path: path, From: from, To: to})
+	}
+}
+
+// escapePointer escape a JSON pointer token (RFC 6901).
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// the ANSI colours of the diff rendering.
+const (
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+	colorReset = "\x1b[0m"
+)
+
+// RenderDiff returns a unified text rendering of the changes, a hunk per changed path whose "-" lines hold the
+// from (expected) value and "+" lines the to (actual) value. colour adds ANSI colours for terminals.
+func RenderDiff(changes []*Change, colour bool) string {
+	paint := func(color, s string) string {
+		if !colour {
+			return s
+		}
+		return color + s + colorReset
+	}
+
+	bf := bytes.Buffer{}
+	for _, c := range changes {
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+		bf.WriteString(paint(colorCyan, "@@ "+path+" @@") + "\n")
+		if c.Type != ChangeAdded {
+			for _, line := range renderValue(c.From) {
+				bf.WriteString(paint(colorRed, "-"+line) + "\n")
+			}
+		}
+		if c.Type != ChangeRemoved {
+			for _, line := range renderValue(c.To) {
+				bf.WriteString(paint(colorGreen, "+"+line) + "\n")
+			}
+		}
+	}
+	return strings.TrimSuffix(bf.String(), "\n")
+}
+
+// renderValue returns the lines of the indented JSON representation of the value.
+func renderValue(v interface{}) []string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return []string{fmt.Sprintf("%v", v)}
+	}
+	return strings.Split(string(b), "\n")
+}
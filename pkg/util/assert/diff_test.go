@@ -0,0 +1,99 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package assert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func TestDiff(t *testing.T) {
+	from := `{"id": 1, "user": {"name": "a", "roles": ["admin"]}, "a~b": true}`
+	to := []byte(`{"id": "1", "user": {"name": "a", "roles": ["admin", "dev"], "age": 18}}`)
+
+	var got []string
+	for _, c := range Diff(from, to) {
+		got = append(got, string(c.Type)+" "+c.Path)
+	}
+	want := "removed /a~0b,changed /id,added /user/age,added /user/roles/1"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Diff() = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	if changes := Diff("plain", "plain"); len(changes) != 0 {
+		t.Errorf("Diff() of equal texts = %+v", changes)
+	}
+}
+
+func TestRenderDiff(t *testing.T) {
+	changes := []*Change{
+		{Type: ChangeChanged, Path: "/name", From: "a", To: "b"},
+		{Type: ChangeRemoved, Path: "/tags/1", From: map[string]interface{}{"id": 1.0}},
+		{Type: ChangeAdded, Path: "/age", To: 18},
+	}
+	want := `@@ /name @@
+-"a"
++"b"
+@@ /tags/1 @@
+-{
+-  "id": 1
+-}
+@@ /age @@
++18`
+	if got := RenderDiff(changes, false); got != want {
+		t.Errorf("RenderDiff() = \n%s\nwant\n%s", got, want)
+	}
+
+	if got := RenderDiff(changes[:1], true); got != "\x1b[36m@@ /name @@\x1b[0m\n\x1b[31m-\"a\"\x1b[0m\n\x1b[32m+\"b\"\x1b[0m" {
+		t.Errorf("RenderDiff() with colour = %q", got)
+	}
+}
+
+func TestAssertError_Diff(t *testing.T) {
+	actual := `{"code": 1, "data": {"items": [{"id": 1}, {"id": 2}, {"id": 3}]}}`
+	expected := map[string]interface{}{
+		"code": 0,
+		"data": map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": 1}}},
+		"msg":  "ok",
+	}
+
+	err := SoAll(logger.NewDefault("test"), "body", actual, expected)
+	errs, _ := err.(Errors)
+	var got []string
+	for _, e := range errs {
+		for _, c := range e.Diff() {
+			got = append(got, string(c.Type)+" "+c.Path)
+		}
+	}
+	// the keys of a map are not visited in order.
+	want := map[string]bool{"changed /code": true, "added /data/items/1": true, "added /data/items/2": true, "removed /msg": true}
+	if len(got) != len(want) {
+		t.Fatalf("Diff() = %v, want %v", got, want)
+	}
+	for _, g := range got {
+		if !want[g] {
+			t.Errorf("Diff() = %v, want %v", got, want)
+		}
+	}
+
+	if msg := err.Error(); !strings.Contains(msg, "--- expected\n+++ actual\n@@ /code @@\n-0\n+1") {
+		t.Errorf("Error() = %s", msg)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package assert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alsritter/middlebaby/pkg/util/common"
+	"github.com/alsritter/middlebaby/pkg/util/matcher"
+)
+
+var (
+	ErrorUnexpectedKey = errors.New("key is not expected")
+	ErrorNotAbsent     = errors.New("key is expected to be absent")
+	ErrorEmpty         = errors.New("value is empty")
+)
+
+// modifier match the actual value against an expectation modifier (see common.TypePrefix and the others),
+// it returns false when the expected value is not a modifier.
+func (a *Assert) modifier(pointer string, actual, expected interface{}, fail func(error, ...*Change) error) (bool, error) {
+	failIf := func(err error) error {
+		if err != nil {
+			return fail(err)
+		}
+		return nil
+	}
+
+	switch e := expected.(type) {
+	case string:
+		switch {
+		case e == common.NotEmptyMarker:
+			return true, failIf(checkNotEmpty(actual))
+		case e == common.AbsentMarker:
+			// the missing keys are accepted by the map comparison, so the key is present.
+			return true, fail(ErrorNotAbsent, &Change{Type: ChangeAdded, Path: pointer, To: normalize(actual)})
+		case strings.HasPrefix(e, common.TypePrefix):
+			return true, failIf(checkType(strings.TrimPrefix(e, common.TypePrefix), actual))
+		case strings.HasPrefix(e, common.CloseToPrefix):
+			return true, failIf(a.checkCloseTo(strings.TrimPrefix(e, common.CloseToPrefix), actual))
+		}
+	case map[string]interface{}:
+		if len(e) != 1 {
+			return false, nil
+		}
+		if items, ok := e[common.UnorderedKey]; ok {
+			return true, a.matchItems(pointer, actual, items, false, fail)
+		}
+		if items, ok := e[common.ContainsKey]; ok {
+			return true, a.matchItems(pointer, actual, items, true, fail)
+		}
+	}
+	return false, nil
+}
+
+// isAbsentMarker whether the expected value requires the key to be absent.
+func isAbsentMarker(expected interface{}) bool {
+	s, ok := expected.(string)
+	return ok && s == common.AbsentMarker
+}
+
+// strictMode returns whether the expected object is compared in the strict mode, the objects inherit the mode of
+// their parent unless they set common.StrictKey.
+func strictMode(expectedRv reflect.Value, inherited bool) bool {
+	if expectedRv.Type().Key().Kind() != reflect.String {
+		return inherited
+	}
+	v := expectedRv.MapIndex(reflect.ValueOf(common.StrictKey).Convert(expectedRv.Type().Key()))
+	if !v.IsValid() {
+		return inherited
+	}
+	strict, _ := v.Interface().(bool)
+	return strict
+}
+
+// unexpectedKeys returns the keys of the actual object which are not expected, sorted.
+func unexpectedKeys(actualRv, expectedRv reflect.Value) []reflect.Value {
+	expected := make(map[string]bool, expectedRv.Len())
+	for _, k := range expectedRv.MapKeys() {
+		expected[fmt.Sprintf("%v", k.Interface())] = true
+	}
+
+	var keys []reflect.Value
+	for _, k := range actualRv.MapKeys() {
+		if !expected[fmt.Sprintf("%v", k.Interface())] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprintf("%v", keys[i].Interface()) < fmt.Sprintf("%v", keys[j].Interface())
+	})
+	return keys
+}
+
+func checkNotEmpty(actual interface{}) error {
+	if actual == nil {
+		return ErrorEmpty
+	}
+	rv := reflect.ValueOf(actual)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		if rv.Len() == 0 {
+			return ErrorEmpty
+		}
+	}
+	return nil
+}
+
+func checkType(typeName string, actual interface{}) error {
+	var got string
+	if actual == nil {
+		got = "null"
+	} else {
+		switch reflect.ValueOf(actual).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			got = "number"
+		case reflect.String:
+			got = "string"
+		case reflect.Bool:
+			got = "boolean"
+		case reflect.Map, reflect.Struct:
+			got = "object"
+		case reflect.Slice, reflect.Array:
+			got = "array"
+		default:
+			got = reflect.TypeOf(actual).String()
+		}
+	}
+
+	switch typeName {
+	case "number", "string", "boolean", "object", "array", "null":
+	default:
+		return fmt.Errorf("unknown type [%s] of %s, expected one of number, string, boolean, object, array or null",
+			typeName, common.TypePrefix)
+	}
+	if got != typeName {
+		return fmt.Errorf("%v %s != %s", ErrorTypeNotEqual, got, typeName)
+	}
+	return nil
+}
+
+// checkCloseTo the args are "<value>,<tolerance>".
+func (a *Assert) checkCloseTo(args string, actual interface{}) error {
+	parts := strings.Split(args, ",")
+	if len(parts) != 2 {
+		return fmt.Errorf("%s expects \"<value>,<tolerance>\", got [%s]", common.CloseToPrefix, args)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return fmt.Errorf("%s value [%s] is not a number", common.CloseToPrefix, parts[0])
+	}
+	tolerance, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return fmt.Errorf("%s tolerance [%s] is not a number", common.CloseToPrefix, parts[1])
+	}
+
+	ok, actualFloat := a.getFloat64Value(reflect.ValueOf(actual))
+	if !ok {
+		return fmt.Errorf("%v %T is not a number", ErrorTypeNotEqual, actual)
+	}
+	if math.Abs(actualFloat-value) > tolerance {
+		return fmt.Errorf("%v is not within %v of %v", actualFloat, tolerance, value)
+	}
+	return nil
+}
+
+// matchItems match the elements of the actual list with the expected elements in any order, every expected
+// element needs its own actual element. With contains the actual list may hold other elements.
+func (a *Assert) matchItems(pointer string, actual, items interface{}, contains bool, fail func(error, ...*Change) error) error {
+	expectedItems, ok := items.([]interface{})
+	if !ok {
+		return fail(fmt.Errorf("%s and %s expect a list", common.UnorderedKey, common.ContainsKey))
+	}
+
+	actualRv := reflect.ValueOf(actual)
+	if actual == nil || (actualRv.Kind() != reflect.Slice && actualRv.Kind() != reflect.Array) {
+		return fail(fmt.Errorf("%v %T is not a list", ErrorTypeNotEqual, actual))
+	}
+	if !contains && actualRv.Len() != len(expectedItems) {
+		return fail(fmt.Errorf("%v %d != %d", ErrorLengthNotEqual, actualRv.Len(), len(expectedItems)))
+	}
+
+	// matches[i][j] whether the actual element i matches the expected element j.
+	matches := make([][]bool, actualRv.Len())
+	for i := range matches {
+		matches[i] = make([]bool, len(expectedItems))
+		for j, e := range expectedItems {
+			matches[i][j] = a.matches(actualRv.Index(i).Interface(), e)
+		}
+	}
+
+	var changes []*Change
+	for j, i := range matcher.AssignItems(matches, len(expectedItems)) {
+		if i < 0 {
+			changes = append(changes, &Change{Type: ChangeRemoved, Path: pointer, From: normalize(expectedItems[j])})
+		}
+	}
+	if len(changes) > 0 {
+		return fail(fmt.Errorf("%d expected element(s) not found in the list", len(changes)), changes...)
+	}
+	return nil
+}
+
+// matches whether the actual value matches the expected value, the mismatches are not reported.
+func (a *Assert) matches(actual, expected interface{}) bool {
+	sub := &Assert{assertType: a.assertType, log: a.log, strict: a.strict}
+	return sub.so("", "", actual, expected) == nil
+}
@@ -0,0 +1,97 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package assert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alsritter/middlebaby/pkg/util/logger"
+)
+
+func TestSo_Modifiers(t *testing.T) {
+	actual := `{
+		"id": 7, "price": 9.995, "name": "alice", "note": "", "deleted": null,
+		"tags": ["b", "a", "c"],
+		"items": [{"id": 1, "qty": 2}, {"id": 2, "qty": 1}],
+		"owner": {"id": 1, "name": "bob"}
+	}`
+	tests := []struct {
+		name     string
+		expected interface{}
+		wantErr  bool
+	}{
+		{"type number", map[string]interface{}{"id": "@type:number"}, false},
+		{"type mismatch", map[string]interface{}{"name": "@type:number"}, true},
+		{"type null", map[string]interface{}{"deleted": "@type:null", "owner": "@type:object", "tags": "@type:array"}, false},
+		{"unknown type", map[string]interface{}{"id": "@type:integer"}, true},
+		{"close to", map[string]interface{}{"price": "@closeTo:10,0.01"}, false},
+		{"not close to", map[string]interface{}{"price": "@closeTo:10,0.001"}, true},
+		{"close to a text", map[string]interface{}{"name": "@closeTo:10,0.01"}, true},
+		{"not empty", map[string]interface{}{"name": "@notEmpty", "tags": "@notEmpty"}, false},
+		{"empty", map[string]interface{}{"note": "@notEmpty"}, true},
+		{"null is empty", map[string]interface{}{"deleted": "@notEmpty"}, true},
+		{"absent", map[string]interface{}{"password": "@absent"}, false},
+		{"present", map[string]interface{}{"name": "@absent"}, true},
+		{"unordered", map[string]interface{}{"tags": map[string]interface{}{"@unordered": []interface{}{"a", "b", "c"}}}, false},
+		{"unordered with an extra element", map[string]interface{}{"tags": map[string]interface{}{"@unordered": []interface{}{"a", "b"}}}, true},
+		{"unordered with a duplicate", map[string]interface{}{"tags": map[string]interface{}{"@unordered": []interface{}{"a", "a", "c"}}}, true},
+		{"contains", map[string]interface{}{"items": map[string]interface{}{"@contains": []interface{}{
+			map[string]interface{}{"id": 2}}}}, false},
+		{"contains a missing element", map[string]interface{}{"items": map[string]interface{}{"@contains": []interface{}{
+			map[string]interface{}{"id": 3}}}}, true},
+		{"contains needs distinct elements", map[string]interface{}{"items": map[string]interface{}{"@contains": []interface{}{
+			map[string]interface{}{"qty": "@type:number"}, map[string]interface{}{"id": 1}, map[string]interface{}{"id": 1}}}}, true},
+		{"contains with regexp", map[string]interface{}{"tags": map[string]interface{}{"@contains": []interface{}{"@regExp:^[ab]$", "c"}}}, false},
+		{"contains of an object", map[string]interface{}{"owner": map[string]interface{}{"@contains": []interface{}{1}}}, true},
+		{"strict", map[string]interface{}{"owner": map[string]interface{}{"@strict": true, "id": 1, "name": "bob"}}, false},
+		{"strict with an unexpected key", map[string]interface{}{"owner": map[string]interface{}{"@strict": true, "id": 1}}, true},
+		{"strict is inherited", map[string]interface{}{"@strict": true, "id": 7, "price": nil, "name": nil, "note": nil,
+			"deleted": nil, "tags": nil, "items": nil, "owner": map[string]interface{}{"id": 1}}, true},
+		{"strict is overridden", map[string]interface{}{"@strict": true, "id": 7, "price": nil, "name": nil, "note": nil,
+			"deleted": nil, "tags": nil, "items": nil, "owner": map[string]interface{}{"@strict": false, "id": 1}}, false},
+		{"strict in the elements", map[string]interface{}{"items": map[string]interface{}{"@contains": []interface{}{
+			map[string]interface{}{"@strict": true, "id": 1}}}}, true},
+	}
+	log := logger.NewDefault("test")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := So(log, "body", actual, tt.expected); (err != nil) != tt.wantErr {
+				t.Errorf("So() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSoAll_Strict(t *testing.T) {
+	actual := `{"id": 1, "name": "a", "extra": true, "more": 2}`
+	expected := map[string]interface{}{"@strict": true, "id": 2, "name": "a"}
+
+	err := SoAll(logger.NewDefault("test"), "body", actual, expected)
+	errs, _ := err.(Errors)
+	var got []string
+	for _, e := range errs {
+		for _, c := range e.Diff() {
+			got = append(got, string(c.Type)+" "+c.Path)
+		}
+	}
+	want := "added /extra,added /more,changed /id"
+	if joined := strings.Join(got, ","); joined != want {
+		t.Errorf("SoAll() changes = %s, want %s", joined, want)
+	}
+}
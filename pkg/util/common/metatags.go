@@ -23,3 +23,21 @@ const (
 	MultiFilePrefix  = "@multiFile:"
 	FileFieldPrefix  = "field:"
 )
+
+// the modifiers of the expected values of the assertions.
+const (
+	// TypePrefix "@type:number" the value has the JSON type: number, string, boolean, object, array or null.
+	TypePrefix = "@type:"
+	// CloseToPrefix "@closeTo:3.14,0.01" the number is within the tolerance of the value.
+	CloseToPrefix = "@closeTo:"
+	// NotEmptyMarker the value is not null, nor an empty string, list or object.
+	NotEmptyMarker = "@notEmpty"
+	// AbsentMarker the key must not be present.
+	AbsentMarker = "@absent"
+	// UnorderedKey {"@unordered": [...]} the list holds exactly these elements in any order.
+	UnorderedKey = "@unordered"
+	// ContainsKey {"@contains": [...]} the list holds at least these elements in any order.
+	ContainsKey = "@contains"
+	// StrictKey {"@strict": true, ...} the object and its nested objects cannot hold keys which are not expected.
+	StrictKey = "@strict"
+)
@@ -342,8 +342,16 @@ func (p *Proxy) httpProxy(ctx *Context, rw http.ResponseWriter) {
 		CopyHeader(rw.Header(), resp.Header)
 		rw.WriteHeader(resp.StatusCode)
 		buf := bufPool.Get().([]byte)
-		_, _ = io.CopyBuffer(rw, resp.Body, buf)
+		_, err = io.CopyBuffer(rw, resp.Body, buf)
 		bufPool.Put(buf)
+		if err != nil {
+			// like httputil.ReverseProxy, drop the connection so that the client never gets a complete response.
+			p.delegate.ErrorLog(fmt.Errorf("%s - copy response body failed: %s", ctx.Req.URL, err))
+			if f, ok := rw.(http.Flusher); ok {
+				f.Flush()
+			}
+			panic(http.ErrAbortHandler)
+		}
 	})
 }
 
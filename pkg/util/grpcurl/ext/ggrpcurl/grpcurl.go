@@ -121,7 +121,8 @@ func NewInvokeGRpc(dto *GGrpCurlDTO) *InvokeGRpc {
 // * trailer
 // * outWrite
 // * Status
-func (i *InvokeGRpc) Invoke() (metadata.MD, metadata.MD, string, *status.Status, error) {
+// the call is canceled when the ctx is done.
+func (i *InvokeGRpc) Invoke(ctx context.Context) (metadata.MD, metadata.MD, string, *status.Status, error) {
 
 	target := i.serviceAddr
 	symbol := i.serviceMethod
@@ -130,10 +131,11 @@ func (i *InvokeGRpc) Invoke() (metadata.MD, metadata.MD, string, *status.Status,
 		verbosityLevel = 2
 	}
 
-	ctx := context.Background()
 	if i.maxTime > 0 {
 		timeout := time.Duration(i.maxTime * float64(time.Second))
-		ctx, _ = context.WithTimeout(ctx, timeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
 	dial := i.dial(ctx, target)
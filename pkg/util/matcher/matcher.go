@@ -0,0 +1,348 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package matcher evaluates the operators used to match the fields of a request (e.g. contains, oneOf, jsonSchema).
+package matcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/tidwall/gjson"
+)
+
+// Matcher the conditions a value must satisfy, all the conditions set must be satisfied.
+type Matcher struct {
+	// JSONPath select the value of a JSON document (gjson path, e.g. "order.items.0.sku"), body only.
+	JSONPath string `json:"jsonPath,omitempty" yaml:"jsonPath,omitempty"`
+
+	Equals   interface{}   `json:"equals,omitempty" yaml:"equals,omitempty"`
+	Contains string        `json:"contains,omitempty" yaml:"contains,omitempty"`
+	Matches  string        `json:"matches,omitempty" yaml:"matches,omitempty"` // regular expression
+	OneOf    []interface{} `json:"oneOf,omitempty" yaml:"oneOf,omitempty"`
+	// Absent the value must not exist.
+	Absent bool `json:"absent,omitempty" yaml:"absent,omitempty"`
+	// CaseInsensitive apply to equals, contains and oneOf.
+	CaseInsensitive bool `json:"caseInsensitive,omitempty" yaml:"caseInsensitive,omitempty"`
+
+	Gt  *float64 `json:"gt,omitempty" yaml:"gt,omitempty"`
+	Gte *float64 `json:"gte,omitempty" yaml:"gte,omitempty"`
+	Lt  *float64 `json:"lt,omitempty" yaml:"lt,omitempty"`
+	Lte *float64 `json:"lte,omitempty" yaml:"lte,omitempty"`
+
+	// PartialJSON the value contains these fields, the arrays are compared regardless of the order.
+	PartialJSON interface{} `json:"partialJson,omitempty" yaml:"partialJson,omitempty"`
+	// JSONSchema the value is valid against the schema.
+	JSONSchema interface{} `json:"jsonSchema,omitempty" yaml:"jsonSchema,omitempty"`
+}
+
+// Validate check the conditions which can be checked before matching (the regular expression and the JSON schema).
+func (m *Matcher) Validate() error {
+	if m.Matches != "" {
+		if _, err := regexp.Compile(m.Matches); err != nil {
+			return fmt.Errorf("invalid regular expression [%s]: %v", m.Matches, err)
+		}
+	}
+	if m.JSONSchema != nil {
+		if _, err := CompileSchema(m.JSONSchema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchDocument match the matcher against a document, the value is selected by JSONPath if it is set.
+func (m *Matcher) MatchDocument(doc string) error {
+	if m.JSONPath == "" {
+		var v interface{}
+		if err := json.Unmarshal([]byte(doc), &v); err != nil {
+			v = doc
+		}
+		return m.Match(v, true)
+	}
+
+	result := gjson.Get(doc, m.JSONPath)
+	return m.Match(result.Value(), result.Exists())
+}
+
+// Match the value against every condition, exists tells whether the value is present.
+func (m *Matcher) Match(v interface{}, exists bool) error {
+	if m.Absent {
+		if exists {
+			return fmt.Errorf("expected absent, actual [%v]", v)
+		}
+		return nil
+	}
+	if !exists {
+		return fmt.Errorf("expected present, actual absent")
+	}
+
+	if m.Equals != nil && !m.equal(v, m.Equals) {
+		return fmt.Errorf("expected equal to [%v], actual [%v]", m.Equals, v)
+	}
+
+	if m.Contains != "" && !m.contains(toString(v), m.Contains) {
+		return fmt.Errorf("expected to contain [%s], actual [%v]", m.Contains, v)
+	}
+
+	if m.Matches != "" {
+		matched, err := regexp.MatchString(m.Matches, toString(v))
+		if err != nil {
+			return fmt.Errorf("invalid regular expression [%s]: %v", m.Matches, err)
+		}
+		if !matched {
+			return fmt.Errorf("expected to match [%s], actual [%v]", m.Matches, v)
+		}
+	}
+
+	if len(m.OneOf) > 0 && !m.oneOf(v) {
+		return fmt.Errorf("expected one of %v, actual [%v]", m.OneOf, v)
+	}
+
+	if err := m.inRange(v); err != nil {
+		return err
+	}
+
+	if m.PartialJSON != nil && !ContainsJSON(v, normalize(m.PartialJSON)) {
+		return fmt.Errorf("expected to contain the JSON %s, actual %s", toString(m.PartialJSON), toString(v))
+	}
+
+	if m.JSONSchema != nil {
+		if err := ValidateSchema(m.JSONSchema, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Matcher) equal(actual, expected interface{}) bool {
+	expected = normalize(expected)
+	if a, ok := actual.(string); ok {
+		// the headers and the queries are strings, compare them to the literal of the expected value.
+		e := toString(expected)
+		if m.CaseInsensitive {
+			return strings.EqualFold(a, e)
+		}
+		return a == e
+	}
+	return reflect.DeepEqual(normalize(actual), expected)
+}
+
+func (m *Matcher) contains(s, substr string) bool {
+	if m.CaseInsensitive {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+	}
+	return strings.Contains(s, substr)
+}
+
+func (m *Matcher) oneOf(v interface{}) bool {
+	for _, candidate := range m.OneOf {
+		if m.equal(v, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Matcher) inRange(v interface{}) error {
+	if m.Gt == nil && m.Gte == nil && m.Lt == nil && m.Lte == nil {
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(toString(v), 64)
+	if err != nil {
+		return fmt.Errorf("expected a number, actual [%v]", v)
+	}
+
+	switch {
+	case m.Gt != nil && !(f > *m.Gt):
+		return fmt.Errorf("expected greater than %v, actual %v", *m.Gt, f)
+	case m.Gte != nil && !(f >= *m.Gte):
+		return fmt.Errorf("expected greater than or equal to %v, actual %v", *m.Gte, f)
+	case m.Lt != nil && !(f < *m.Lt):
+		return fmt.Errorf("expected less than %v, actual %v", *m.Lt, f)
+	case m.Lte != nil && !(f <= *m.Lte):
+		return fmt.Errorf("expected less than or equal to %v, actual %v", *m.Lte, f)
+	}
+	return nil
+}
+
+// ContainsJSON whether the actual value contains the expected one: objects may have more fields,
+// arrays must have an element matching each expected element regardless of the order.
+func ContainsJSON(actual, expected interface{}) bool {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, ev := range e {
+			av, ok := a[k]
+			if !ok || !ContainsJSON(av, ev) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) < len(e) {
+			return false
+		}
+		// matches[i][j] whether the actual element i contains the expected element j.
+		matches := make([][]bool, len(a))
+		for i, av := range a {
+			matches[i] = make([]bool, len(e))
+			for j, ev := range e {
+				matches[i][j] = ContainsJSON(av, ev)
+			}
+		}
+		for _, i := range AssignItems(matches, len(e)) {
+			if i < 0 {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(actual, expected)
+	}
+}
+
+// AssignItems returns the actual element assigned to each expected element (-1 when none is left),
+// it finds a maximum matching by augmenting paths so that an element does not take the match of another one.
+func AssignItems(matches [][]bool, expectedLen int) []int {
+	owner := make([]int, len(matches)) // the expected element assigned to each actual element.
+	for i := range owner {
+		owner[i] = -1
+	}
+
+	var augment func(j int, seen []bool) bool
+	augment = func(j int, seen []bool) bool {
+		for i := range matches {
+			if !matches[i][j] || seen[i] {
+				continue
+			}
+			seen[i] = true
+			if owner[i] < 0 || augment(owner[i], seen) {
+				owner[i] = j
+				return true
+			}
+		}
+		return false
+	}
+
+	assigned := make([]int, expectedLen)
+	for j := range assigned {
+		assigned[j] = -1
+		augment(j, make([]bool, len(matches)))
+	}
+	for i, j := range owner {
+		if j >= 0 {
+			assigned[j] = i
+		}
+	}
+	return assigned
+}
+
+// schemas the compiled inline schemas, key: the JSON of the schema.
+var schemas sync.Map
+
+// ValidateSchema validate the value against the JSON schema.
+func ValidateSchema(schema, v interface{}) error {
+	s, err := CompileSchema(schema)
+	if err != nil {
+		return err
+	}
+	return validateSchema(s, v)
+}
+
+// CompileSchema compile the inline JSON schema, the schemas are compiled once and then reused.
+func CompileSchema(schema interface{}) (*jsonschema.Schema, error) {
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := schemas.Load(string(b)); ok {
+		return s.(*jsonschema.Schema), nil
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", bytes.NewReader(b)); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %v", err)
+	}
+	s, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %v", err)
+	}
+	schemas.Store(string(b), s)
+	return s, nil
+}
+
+// ValidateSchemaFile validate the value against the JSON schema file,
+// the references ($ref) of the schema are resolved relative to the file.
+func ValidateSchemaFile(path string, v interface{}) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	s, err := jsonschema.NewCompiler().Compile(abs)
+	if err != nil {
+		return fmt.Errorf("invalid JSON schema %s: %v", path, err)
+	}
+	return validateSchema(s, v)
+}
+
+func validateSchema(s *jsonschema.Schema, v interface{}) error {
+	if err := s.Validate(normalize(v)); err != nil {
+		return fmt.Errorf("the value does not match the JSON schema: %v", err)
+	}
+	return nil
+}
+
+// normalize convert the value into the types produced by encoding/json (e.g. numbers are float64).
+func normalize(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func toString(v interface{}) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vv
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(vv)
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", vv)
+	}
+}
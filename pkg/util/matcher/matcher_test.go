@@ -0,0 +1,158 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package matcher
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func float(f float64) *float64 { return &f }
+
+func TestMatcher_Match(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		value   interface{}
+		exists  bool
+		wantErr bool
+	}{
+		{name: "equals", matcher: Matcher{Equals: "abc"}, value: "abc", exists: true},
+		{name: "equals number literal", matcher: Matcher{Equals: 10}, value: "10", exists: true},
+		{name: "equals case insensitive", matcher: Matcher{Equals: "ABC", CaseInsensitive: true}, value: "abc", exists: true},
+		{name: "not equals", matcher: Matcher{Equals: "ABC"}, value: "abc", exists: true, wantErr: true},
+		{name: "contains", matcher: Matcher{Contains: "Bear"}, value: "Bearer token", exists: true},
+		{name: "not contains", matcher: Matcher{Contains: "Basic"}, value: "Bearer token", exists: true, wantErr: true},
+		{name: "matches", matcher: Matcher{Matches: `^\d+$`}, value: "123", exists: true},
+		{name: "absent", matcher: Matcher{Absent: true}, exists: false},
+		{name: "not absent", matcher: Matcher{Absent: true}, value: "1", exists: true, wantErr: true},
+		{name: "missing", matcher: Matcher{Contains: "a"}, exists: false, wantErr: true},
+		{name: "one of", matcher: Matcher{OneOf: []interface{}{"a", "b"}}, value: "b", exists: true},
+		{name: "not one of", matcher: Matcher{OneOf: []interface{}{"a", "b"}}, value: "c", exists: true, wantErr: true},
+		{name: "range", matcher: Matcher{Gte: float(1), Lt: float(10)}, value: "9.5", exists: true},
+		{name: "out of range", matcher: Matcher{Gt: float(1)}, value: float64(1), exists: true, wantErr: true},
+		{name: "not a number", matcher: Matcher{Gt: float(1)}, value: "x", exists: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.matcher.Match(tt.value, tt.exists); (err != nil) != tt.wantErr {
+				t.Errorf("Matcher.Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatcher_MatchDocument(t *testing.T) {
+	doc := `{"order":{"sku":"A1","amount":20,"items":[{"id":1},{"id":2,"tag":"x"}]}}`
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{name: "json path equals", matcher: Matcher{JSONPath: "order.sku", Equals: "A1"}},
+		{name: "json path number", matcher: Matcher{JSONPath: "order.amount", Equals: 20}},
+		{name: "json path absent", matcher: Matcher{JSONPath: "order.coupon", Absent: true}},
+		{name: "partial json unordered", matcher: Matcher{PartialJSON: map[string]interface{}{
+			"order": map[string]interface{}{"items": []interface{}{map[string]interface{}{"tag": "x"}, map[string]interface{}{"id": 1}}},
+		}}},
+		{name: "partial json mismatch", matcher: Matcher{PartialJSON: map[string]interface{}{
+			"order": map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": 3}}},
+		}}, wantErr: true},
+		{name: "json schema", matcher: Matcher{JSONPath: "order", JSONSchema: map[string]interface{}{
+			"type":     "object",
+			"required": []string{"sku", "amount"},
+			"properties": map[string]interface{}{
+				"amount": map[string]interface{}{"type": "number", "minimum": 1},
+			},
+		}}},
+		{name: "json schema mismatch", matcher: Matcher{JSONSchema: map[string]interface{}{
+			"required": []string{"user"},
+		}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.matcher.MatchDocument(doc); (err != nil) != tt.wantErr {
+				t.Errorf("Matcher.MatchDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestContainsJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   string
+		expected string
+		want     bool
+	}{
+		{name: "object", actual: `{"a":1,"b":2}`, expected: `{"a":1}`, want: true},
+		{name: "unordered", actual: `[1,2,3]`, expected: `[3,1]`, want: true},
+		// the first expected element also matches the first actual element, which is the only match of the second one.
+		{name: "not greedy", actual: `[{"a":1,"b":2},{"a":1}]`, expected: `[{"a":1},{"a":1,"b":2}]`, want: true},
+		{name: "element used twice", actual: `[{"a":1,"b":2},{"b":3}]`, expected: `[{"a":1},{"a":1,"b":2}]`, want: false},
+		{name: "too short", actual: `[1]`, expected: `[1,1]`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actual, expected interface{}
+			if err := json.Unmarshal([]byte(tt.actual), &actual); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatal(err)
+			}
+			if got := ContainsJSON(actual, expected); got != tt.want {
+				t.Errorf("ContainsJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		wantErr bool
+	}{
+		{name: "valid", matcher: Matcher{Matches: `^\d+$`, JSONSchema: map[string]interface{}{"type": "object"}}},
+		{name: "invalid regular expression", matcher: Matcher{Matches: `(`}, wantErr: true},
+		{name: "invalid json schema", matcher: Matcher{JSONSchema: map[string]interface{}{"type": 1}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.matcher.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Matcher.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileSchema_Cache(t *testing.T) {
+	schema := map[string]interface{}{"type": "object", "required": []string{"id"}}
+	first, err := CompileSchema(schema)
+	if err != nil {
+		t.Fatalf("CompileSchema() error = %v", err)
+	}
+	second, err := CompileSchema(map[string]interface{}{"required": []string{"id"}, "type": "object"})
+	if err != nil {
+		t.Fatalf("CompileSchema() error = %v", err)
+	}
+	if first != second {
+		t.Errorf("CompileSchema() compiled the same schema twice")
+	}
+}
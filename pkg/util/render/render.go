@@ -0,0 +1,157 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package render substitutes Go templates (e.g. "{{ .vars.orderId }}") in case and mock definitions.
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const actionPrefix = "{{"
+
+// funcs the helpers available in every template.
+var funcs = template.FuncMap{
+	"uuid": func() string {
+		return uuid.New().String()
+	},
+	// now returns the current time, e.g. {{ now.Unix }} or {{ now.Format "2006-01-02" }}.
+	"now": time.Now,
+	// randInt returns a random integer in [min, max).
+	"randInt": func(min, max int) int {
+		if max <= min {
+			return min
+		}
+		return min + rand.Intn(max-min)
+	},
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"base64Decode": func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		return string(b), err
+	},
+}
+
+// String render the text as a template, the text is returned as is if it contains no action.
+func String(text string, data interface{}) (string, error) {
+	if !strings.Contains(text, actionPrefix) {
+		return text, nil
+	}
+
+	tpl, err := template.New("").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse template [%s] error: [%v]", text, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render template [%s] error: [%v]", text, err)
+	}
+	return buf.String(), nil
+}
+
+// Strings render every text.
+func Strings(texts []string, data interface{}) ([]string, error) {
+	out := make([]string, 0, len(texts))
+	for _, text := range texts {
+		s, err := String(text, data)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Value render every string (including map keys) nested in a JSON-like value.
+func Value(v interface{}, data interface{}) (interface{}, error) {
+	switch vv := v.(type) {
+	case string:
+		return String(vv, data)
+	case []interface{}:
+		out := make([]interface{}, 0, len(vv))
+		for _, item := range vv {
+			r, err := Value(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, r)
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(vv))
+		for k, item := range vv {
+			rk, err := String(k, data)
+			if err != nil {
+				return nil, err
+			}
+			r, err := Value(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[rk] = r
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// Into render src into dst through its JSON representation, dst should be a pointer.
+func Into(src interface{}, dst interface{}, data interface{}) error {
+	b, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return err
+	}
+
+	rendered, err := Value(generic, data)
+	if err != nil {
+		return err
+	}
+
+	if b, err = json.Marshal(rendered); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+// HasAction whether any string nested in the value contains a template action.
+func HasAction(v interface{}) bool {
+	if s, ok := v.(string); ok {
+		return strings.Contains(s, actionPrefix)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(b, []byte(actionPrefix))
+}
@@ -0,0 +1,182 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package tagexpr evaluates the tag filter expressions of the cases, e.g. "smoke && !slow".
+package tagexpr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Expr a parsed filter expression.
+// Grammar: expr = and { "||" and } ; and = unary { "&&" unary } ; unary = "!" unary | "(" expr ")" | tag
+type Expr struct {
+	text string
+	eval func(tags map[string]struct{}) bool
+}
+
+// Parse parse the expression, an empty expression matches every tag set.
+func Parse(text string) (*Expr, error) {
+	e := &Expr{text: text}
+	p := &parser{tokens: tokenize(text)}
+	if len(p.tokens) == 0 {
+		e.eval = func(map[string]struct{}) bool { return true }
+		return e, nil
+	}
+
+	eval, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag expression [%s]: %v", text, err)
+	}
+	e.eval = eval
+	return e, nil
+}
+
+// Match whether the tags satisfy the expression.
+func (e *Expr) Match(tags []string) bool {
+	set := make(map[string]struct{}, len(tags))
+	for _, t := range tags {
+		set[t] = struct{}{}
+	}
+	return e.eval(set)
+}
+
+func (e *Expr) String() string {
+	return e.text
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) or() (func(map[string]struct{}) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(tags map[string]struct{}) bool { return l(tags) || right(tags) }
+	}
+	return left, nil
+}
+
+func (p *parser) and() (func(map[string]struct{}) bool, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(tags map[string]struct{}) bool { return l(tags) && right(tags) }
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (func(map[string]struct{}) bool, error) {
+	token := p.peek()
+	p.pos++
+	switch token {
+	case "":
+		return nil, fmt.Errorf("unexpected end")
+	case "!":
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(tags map[string]struct{}) bool { return !operand(tags) }, nil
+	case "(":
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	case ")", "&&", "||":
+		return nil, fmt.Errorf("unexpected %q", token)
+	default:
+		return func(tags map[string]struct{}) bool {
+			_, ok := tags[token]
+			return ok
+		}, nil
+	}
+}
+
+// ValidTag whether the tag can be written in an expression.
+func ValidTag(tag string) bool {
+	tokens := tokenize(tag)
+	return len(tokens) == 1 && tokens[0] == tag
+}
+
+// tokenize split the operators and the tags, a tag is any run of characters other than spaces and operators.
+func tokenize(text string) []string {
+	var (
+		tokens []string
+		tag    strings.Builder
+	)
+	flush := func() {
+		if tag.Len() > 0 {
+			tokens = append(tokens, tag.String())
+			tag.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			flush()
+		case c == '!' || c == '(' || c == ')':
+			flush()
+			tokens = append(tokens, string(c))
+		case (c == '&' || c == '|') && i+1 < len(text) && text[i+1] == c:
+			flush()
+			tokens = append(tokens, string([]byte{c, c}))
+			i++
+		default:
+			tag.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,76 @@
+/*
+ Copyright (C) 2022 alsritter
+
+ This program is free software: you can redistribute it and/or modify
+ it under the terms of the GNU Affero General Public License as
+ published by the Free Software Foundation, either version 3 of the
+ License, or (at your option) any later version.
+
+ This program is distributed in the hope that it will be useful,
+ but WITHOUT ANY WARRANTY; without even the implied warranty of
+ MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ GNU Affero General Public License for more details.
+
+ You should have received a copy of the GNU Affero General Public License
+ along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package tagexpr
+
+import "testing"
+
+func TestExpr_Match(t *testing.T) {
+	tests := []struct {
+		expr    string
+		tags    []string
+		want    bool
+		wantErr bool
+	}{
+		{expr: "", tags: nil, want: true},
+		{expr: "smoke", tags: []string{"smoke"}, want: true},
+		{expr: "smoke", tags: []string{"slow"}, want: false},
+		{expr: "smoke && !slow", tags: []string{"smoke"}, want: true},
+		{expr: "smoke && !slow", tags: []string{"smoke", "slow"}, want: false},
+		{expr: "smoke || regression", tags: []string{"regression"}, want: true},
+		{expr: "!(smoke || slow)", tags: []string{"api"}, want: true},
+		{expr: "a || b && c", tags: []string{"a"}, want: true},
+		{expr: "(a || b) && c", tags: []string{"a"}, want: false},
+		{expr: "team:payments&&!flaky", tags: []string{"team:payments"}, want: true},
+		{expr: "smoke &&", wantErr: true},
+		{expr: "(smoke", wantErr: true},
+		{expr: "smoke slow", wantErr: true},
+		{expr: "|| smoke", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := e.Match(tt.tags); got != tt.want {
+				t.Errorf("Match(%v) = %v, want %v", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidTag(t *testing.T) {
+	tests := map[string]bool{
+		"smoke":      true,
+		"team:user":  true,
+		"a&b":        true,
+		"":           false,
+		"smoke test": false,
+		"!slow":      false,
+		"a||b":       false,
+		"(smoke)":    false,
+	}
+	for tag, want := range tests {
+		if got := ValidTag(tag); got != want {
+			t.Errorf("ValidTag(%q) = %v, want %v", tag, got, want)
+		}
+	}
+}
@@ -18,17 +18,25 @@
 package v1
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/alsritter/middlebaby/pkg/apimanager"
 	"github.com/alsritter/middlebaby/pkg/caseprovider"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/cirunner"
 	"github.com/alsritter/middlebaby/pkg/protomanager"
+	"github.com/alsritter/middlebaby/pkg/runhistory"
+	"github.com/alsritter/middlebaby/pkg/runmanager"
 	"github.com/alsritter/middlebaby/pkg/targetprocess"
 	"github.com/alsritter/middlebaby/pkg/taskserver"
+	"github.com/alsritter/middlebaby/pkg/types/task"
 	"github.com/alsritter/middlebaby/pkg/util/logger"
+	"github.com/alsritter/middlebaby/pkg/util/tagexpr"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/handlers"
 )
@@ -86,6 +94,9 @@ type API struct {
 	protoManager protomanager.Provider
 	taskService  taskserver.Provider
 	target       targetprocess.Provider
+	runner       cirunner.Provider
+	runManager   runmanager.Provider
+	history      runhistory.Provider
 }
 
 // TODO: here cannot need all services providers.
@@ -96,6 +107,9 @@ func NewAPI(
 	protoManager protomanager.Provider,
 	taskService taskserver.Provider,
 	target targetprocess.Provider,
+	runner cirunner.Provider,
+	runManager runmanager.Provider,
+	history runhistory.Provider,
 ) *API {
 	return &API{
 		Logger:       log.NewLogger("v1"),
@@ -104,9 +118,20 @@ func NewAPI(
 		protoManager: protoManager,
 		taskService:  taskService,
 		target:       target,
+		runner:       runner,
+		runManager:   runManager,
+		history:      history,
 	}
 }
 
+type paramsKey struct{}
+
+// param returns the value of the path parameter of the route, e.g. ":id".
+func param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(gin.Params)
+	return params.ByName(name)
+}
+
 func (a *API) Register(r *gin.Engine) {
 	wrap := func(f apiFunc) gin.HandlerFunc {
 		hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -129,13 +154,24 @@ func (a *API) Register(r *gin.Engine) {
 		})
 
 		return func(c *gin.Context) {
-			handlers.CompressHandler(hf).ServeHTTP(c.Writer, c.Request)
+			r := c.Request.WithContext(context.WithValue(c.Request.Context(), paramsKey{}, c.Params))
+			handlers.CompressHandler(hf).ServeHTTP(c.Writer, r)
 		}
 	}
 	v1 := r.Group("/v1")
 	{
 		v1.GET("/getCaseList", wrap(a.getCaseList))
 		v1.POST("/runSingleCase", wrap(a.runSingleCase))
+		v1.POST("/runCases", wrap(a.runCases))
+		v1.POST("/runs", wrap(a.startRun))
+		v1.GET("/runs/:id", wrap(a.getRun))
+		v1.DELETE("/runs/:id", wrap(a.cancelRun))
+		v1.GET("/history/runs", wrap(a.listHistoryRuns))
+		v1.GET("/history/runs/:id", wrap(a.getHistoryRun))
+		v1.GET("/history/case", wrap(a.getCaseHistory))
+		v1.GET("/history/diff", wrap(a.diffCase))
+		v1.GET("/getScenarios", wrap(a.getScenarios))
+		v1.POST("/resetScenarios", wrap(a.resetScenarios))
 	}
 }
 
@@ -154,14 +190,161 @@ func (a *API) runSingleCase(r *http.Request) (result apiFuncResult) {
 		return apiFuncResult{nil, &apiError{errorBadData, errors.New("caseName is required")}, nil}
 	}
 
+	startTime := time.Now()
 	res, err := a.taskService.RunSingleTaskCase(r.Context(), itfName, caseName)
 	if err != nil {
 		return apiFuncResult{nil, &apiError{errorBadData, err}, nil}
 	}
+	// the client has gone away, the interrupted case is neither reported nor recorded.
+	if err := r.Context().Err(); err != nil {
+		return apiFuncResult{nil, &apiError{errorCanceled, err}, nil}
+	}
+
+	status := runmanager.StatusPassed
+	if !res.Passed() {
+		status = runmanager.StatusFailed
+	}
+	sel := cirunner.Selection{ItfName: itfName, CaseName: caseName}
+	summary := cirunner.NewSummary(startTime, []*task.RunTaskReply{&res})
+	if err := a.history.Save(runhistory.NewRun("", sel, string(status), summary)); err != nil {
+		a.Error(nil, "save the run into the history failed: [%v]", err)
+	}
 
 	return apiFuncResult{res, nil, nil}
 }
 
+// runCases the filter is a tag expression (e.g. "smoke && !slow"), every case is run when it is empty.
+func (a *API) runCases(r *http.Request) (result apiFuncResult) {
+	if _, err := tagexpr.Parse(r.FormValue("filter")); err != nil {
+		return apiFuncResult{nil, &apiError{errorBadData, err}, nil}
+	}
+
+	summary, err := a.runner.Run(r.Context(), cirunner.Selection{Filter: r.FormValue("filter")}, nil)
+	if err != nil {
+		return apiFuncResult{nil, &apiError{errorExec, err}, nil}
+	}
+
+	return apiFuncResult{summary, nil, nil}
+}
+
+// startRun start a background run of an interface (itfName), a case file (file), the cases matching a
+// tag filter expression (filter), or every case, the progress is pushed to the websocket clients.
+func (a *API) startRun(r *http.Request) (result apiFuncResult) {
+	run, err := a.runManager.StartRun(cirunner.Selection{
+		ItfName:  r.FormValue("itfName"),
+		CaseName: r.FormValue("caseName"),
+		File:     r.FormValue("file"),
+		Filter:   r.FormValue("filter"),
+	})
+	if err != nil {
+		return apiFuncResult{nil, &apiError{errorBadData, err}, nil}
+	}
+	return apiFuncResult{run, nil, nil}
+}
+
+func (a *API) getRun(r *http.Request) (result apiFuncResult) {
+	run, err := a.runManager.GetRun(param(r, "id"))
+	if err != nil {
+		return apiFuncResult{nil, &apiError{errorNotFound, err}, nil}
+	}
+	return apiFuncResult{run, nil, nil}
+}
+
+func (a *API) cancelRun(r *http.Request) (result apiFuncResult) {
+	if err := a.runManager.CancelRun(param(r, "id")); err != nil {
+		if errors.Is(err, runmanager.ErrRunNotFound) {
+			return apiFuncResult{nil, &apiError{errorNotFound, err}, nil}
+		}
+		return apiFuncResult{nil, &apiError{errorBadData, err}, nil}
+	}
+	return apiFuncResult{nil, nil, nil}
+}
+
+// listHistoryRuns the latest runs first, limit is optional.
+func (a *API) listHistoryRuns(r *http.Request) (result apiFuncResult) {
+	limit, err := limitValue(r)
+	if err != nil {
+		return apiFuncResult{nil, &apiError{errorBadData, err}, nil}
+	}
+	runs, err := a.history.ListRuns(limit)
+	if err != nil {
+		return historyError(err)
+	}
+	return apiFuncResult{runs, nil, nil}
+}
+
+func (a *API) getHistoryRun(r *http.Request) (result apiFuncResult) {
+	run, err := a.history.GetRun(param(r, "id"))
+	if err != nil {
+		return historyError(err)
+	}
+	return apiFuncResult{run, nil, nil}
+}
+
+// getCaseHistory the outcomes of a case in the latest runs, limit is optional.
+func (a *API) getCaseHistory(r *http.Request) (result apiFuncResult) {
+	itfName, caseName := r.FormValue("itfName"), r.FormValue("caseName")
+	if itfName == "" || caseName == "" {
+		return apiFuncResult{nil, &apiError{errorBadData, errors.New("itfName and caseName are required")}, nil}
+	}
+	limit, err := limitValue(r)
+	if err != nil {
+		return apiFuncResult{nil, &apiError{errorBadData, err}, nil}
+	}
+
+	h, err := a.history.GetCaseHistory(itfName, caseName, limit)
+	if err != nil {
+		return historyError(err)
+	}
+	return apiFuncResult{h, nil, nil}
+}
+
+// diffCase compare the actual response of a case between the from run and the to run.
+func (a *API) diffCase(r *http.Request) (result apiFuncResult) {
+	itfName, caseName := r.FormValue("itfName"), r.FormValue("caseName")
+	from, to := r.FormValue("from"), r.FormValue("to")
+	if itfName == "" || caseName == "" || from == "" || to == "" {
+		return apiFuncResult{nil, &apiError{errorBadData, errors.New("itfName, caseName, from and to are required")}, nil}
+	}
+
+	d, err := a.history.DiffCase(itfName, caseName, from, to)
+	if err != nil {
+		return historyError(err)
+	}
+	return apiFuncResult{d, nil, nil}
+}
+
+func limitValue(r *http.Request) (int, error) {
+	if r.FormValue("limit") == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit [%s]", r.FormValue("limit"))
+	}
+	return limit, nil
+}
+
+func historyError(err error) apiFuncResult {
+	switch {
+	case errors.Is(err, runhistory.ErrNotFound):
+		return apiFuncResult{nil, &apiError{errorNotFound, err}, nil}
+	case errors.Is(err, runhistory.ErrDisabled):
+		return apiFuncResult{nil, &apiError{errorUnavailable, err}, nil}
+	}
+	return apiFuncResult{nil, &apiError{errorInternal, err}, nil}
+}
+
+func (a *API) getScenarios(r *http.Request) (result apiFuncResult) {
+	return apiFuncResult{a.apiProvider.GetScenarios(), nil, nil}
+}
+
+// resetScenarios the envId and scenario are optional, all of them are reset when they are empty.
+func (a *API) resetScenarios(r *http.Request) (result apiFuncResult) {
+	a.apiProvider.ResetScenarios(r.FormValue("envId"), r.FormValue("scenario"))
+	return apiFuncResult{nil, nil, nil}
+}
+
 func (api *API) respond(w http.ResponseWriter, data interface{}) {
 	statusMessage := statusSuccess
 	b, err := json.Marshal(&response{
@@ -201,7 +384,7 @@ func (api *API) respondError(w http.ResponseWriter, apiErr *apiError, data inter
 		code = http.StatusBadRequest
 	case errorExec:
 		code = http.StatusUnprocessableEntity
-	case errorCanceled, errorTimeout:
+	case errorCanceled, errorTimeout, errorUnavailable:
 		code = http.StatusServiceUnavailable
 	case errorInternal:
 		code = http.StatusInternalServerError
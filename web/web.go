@@ -25,7 +25,10 @@ import (
 
 	"github.com/alsritter/middlebaby/pkg/apimanager"
 	"github.com/alsritter/middlebaby/pkg/caseprovider"
+	"github.com/alsritter/middlebaby/pkg/pluginregistry/cirunner"
 	"github.com/alsritter/middlebaby/pkg/protomanager"
+	"github.com/alsritter/middlebaby/pkg/runhistory"
+	"github.com/alsritter/middlebaby/pkg/runmanager"
 	"github.com/alsritter/middlebaby/pkg/targetprocess"
 	"github.com/alsritter/middlebaby/pkg/taskserver"
 	"github.com/alsritter/middlebaby/pkg/util"
@@ -70,6 +73,9 @@ type WebService struct {
 	protoManager protomanager.Provider
 	taskService  taskserver.Provider
 	target       targetprocess.Provider
+	runner       cirunner.Provider
+	runManager   runmanager.Provider
+	history      runhistory.Provider
 }
 
 func New(log logger.Logger,
@@ -78,7 +84,10 @@ func New(log logger.Logger,
 	caseProvider caseprovider.Provider,
 	protoManager protomanager.Provider,
 	taskService taskserver.Provider,
-	target targetprocess.Provider) Provider {
+	target targetprocess.Provider,
+	runner cirunner.Provider,
+	runManager runmanager.Provider,
+	history runhistory.Provider) Provider {
 
 	l := log.NewLogger("web")
 	return &WebService{
@@ -89,7 +98,10 @@ func New(log logger.Logger,
 		protoManager: protoManager,
 		taskService:  taskService,
 		target:       target,
-		api_v1:       v1.NewAPI(l, apiProvider, caseProvider, protoManager, taskService, target),
+		runner:       runner,
+		runManager:   runManager,
+		history:      history,
+		api_v1:       v1.NewAPI(l, apiProvider, caseProvider, protoManager, taskService, target, runner, runManager, history),
 	}
 }
 
@@ -100,7 +112,7 @@ func (w *WebService) Start(ctx *mbcontext.Context) error {
 	s := &http.Server{
 		Addr: fmt.Sprintf(":%d", w.cfg.WebServicePort),
 		Handler: handlers.CORS(
-			handlers.AllowedMethods([]string{"GET", "POST", "PUT"}),
+			handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE"}),
 			handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Type", "Content-Language", "Origin"}),
 			handlers.AllowedOrigins([]string{"*"}),
 		)(r),